@@ -96,7 +96,7 @@ var RootCmd = &cobra.Command{
 						URL: URL,
 					}
 				}
-				html, err := svc.Fetch(req)
+				html, err := svc.Fetch(context.Background(), req)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "error: %v\n", err)
 					os.Exit(1)
@@ -85,6 +85,10 @@ Selector represents a CSS selector for data extraction within the given block. P
 
 Extractor contains the logic on how to extract some results from the selector that is provided to this Field.
 
+Documents other than HTML are processed according to their content type. Selectors are JSONPath expressions for JSON documents (e.g. "$.items[*].title"), XPath expressions for XML feeds (e.g. "//item/title") and regular expressions matched against the text of PDF documents. The common part of selectors selects blocks of the document the same way as for HTML pages. Target of the field ("css", "jsonpath", "xpath" or "pdf") restricts the field to documents of the corresponding kind:
+  {"name":"price","selector":"$.products[*].price","attrs":["text"],"target":"jsonpath"}
+Images and other binary documents provide response metadata pseudo attributes only.
+
 Paginator
 
 Paginator is used to scrape multiple pages.
@@ -132,19 +136,47 @@ The following Output formats are available: CSV, MS Excel, JSON, XML
 //    returns no further URLs. Set this value to 0 to indicate an unlimited number
 //    of pages to be scraped.(defaults to 1)
 //
+//    MAX_SITEMAP_URLS: The maximum number of start URLs taken from the sitemap by
+//    payloads with sitemap source. limit from Payload sitemap overrides it.
+//    Set this value to 0 to take all the URLs. (defaults to 1000)
+//
 //    FETCH_DELAY: FetchDelay should be used for a scraper to throttle the crawling
 //    speed to avoid hitting the web servers too frequently.
-//    FetchDelay specifies sleep time for multiple requests for the same domain.
-//    It is equal to FetchDelay * random value between 500 and 1500 msec. (defaults to 500)
+//    FetchDelay specifies sleep time in milliseconds for multiple requests for the same domain.
+//    Every domain is throttled separately so requests to different domains proceed in parallel.
+//    Crawl-delay and Request-rate directives from robots.txt take precedence over it.
+//    fetchDelay from Payload overrides it. (defaults to 500)
 //
 //    RANDOMIZE_FETCH_DELAY:  RandomizeFetchDelay setting decreases the chance of a
 //    crawler being blocked. This way a random delay ranging from 0.5 * FetchDelay
 //    to 1.5 * FetchDelay seconds is used between consecutive requests to the same
 //    domain. If FetchDelay is zero this option has no effect. (defaults to true)
 //
-//    IGNORE_FETCH_DELAY: Ignores fetchDelay setting intended for debug purpose.
+//    IGNORE_FETCH_DELAY: Ignores fetchDelay and Crawl-delay settings intended for debug purpose.
 //    Please set it to false in Production
 //
+//    IGNORE_ROBOTSTXT: Skips check of robots.txt permissions. Otherwise every URL
+//    including start URL, paginator and details links is checked against robots.txt
+//    of its host. Disallowed URLs are not fetched and listed in the task summary.
+//    (defaults to false)
+//
+//    ROBOTSTXT_AGENT: Agent name used for matching robots.txt rules (defaults to "Dataflow Kit")
+//
+//    HTTP_CACHE: Caches robots.txt files in the storage. Cached files are revalidated
+//    with If-None-Match/If-Modified-Since requests once they expire. (defaults to false)
+//
+//    HTTP_CACHE_TTL: Time in seconds cached responses without Cache-Control or Expires
+//    headers are served without revalidation. (defaults to 0)
+//
+//    RETRY_HTTP_CODES: HTTP status codes of failed requests to be retried up to
+//    retryTimes specified in Payload. Network errors are always retried.
+//    Failed pages are rescheduled at the end of the crawl. (defaults to 500,502,503,504,408)
+//
+//    RETRY_DELAY: Base delay in milliseconds for exponential backoff between
+//    retries. A random jitter is added to every delay. (defaults to 500)
+//
+//    RETRY_MAX_DELAY: Maximum delay in milliseconds between retries. (defaults to 30000)
+//
 //Output settings
 //    FORMAT: Format represents output format (CSV, JSON, XML)(defaults to "json")
 //
@@ -46,11 +46,19 @@ var (
 	mongoHost     string
 
 	maxPages            int
+	maxSitemapURLs      int
 	paginateResults     bool
 	fetchDelay          int
 	randomizeFetchDelay bool
 	ignoreFetchDelay    bool
 	ignoreRobotstxt     bool
+	robotstxtAgent      string
+	httpCache           bool
+	httpCacheTTL        int
+
+	retryHTTPCodes []string
+	retryDelay     int
+	retryMaxDelay  int
 
 	payloadWorkersNum int
 	payloadPoolSize   int
@@ -130,12 +138,20 @@ func init() {
 	RootCmd.Flags().StringVarP(&mongoHost, "MONGO", "", "127.0.0.1", "MongoDB host address")
 
 	RootCmd.Flags().IntVarP(&maxPages, "MAX_PAGES", "", 10, "The maximum number of pages to scrape")
+	RootCmd.Flags().IntVarP(&maxSitemapURLs, "MAX_SITEMAP_URLS", "", 1000, "The maximum number of start URLs taken from the sitemap. Set it to 0 to take all the URLs.")
 	RootCmd.Flags().BoolVarP(&paginateResults, "PAGINATE_RESULTS", "", false, "Paginated results are returned. Single list of combined results from every block on all pages is returned by default.")
-	RootCmd.Flags().IntVarP(&fetchDelay, "FETCH_DELAY", "", 500, "Specifies sleep time in milliseconds for multiple requests for the same domain.")
+	RootCmd.Flags().IntVarP(&fetchDelay, "FETCH_DELAY", "", 500, "Specifies sleep time in milliseconds for multiple requests for the same domain. Crawl-delay from robots.txt takes precedence over it.")
 	RootCmd.Flags().BoolVarP(&ignoreRobotstxt, "IGNORE_ROBOTSTXT", "", false, "Skips check of robots.txt permissions")
+	RootCmd.Flags().StringVarP(&robotstxtAgent, "ROBOTSTXT_AGENT", "", "Dataflow Kit", "Agent name used for matching robots.txt rules")
+	RootCmd.Flags().BoolVar(&httpCache, "HTTP_CACHE", false, "Caches robots.txt files in the storage and revalidates them with conditional requests")
+	RootCmd.Flags().IntVar(&httpCacheTTL, "HTTP_CACHE_TTL", 0, "Time in seconds cached responses without Cache-Control or Expires headers are served without revalidation")
 	RootCmd.Flags().BoolVarP(&randomizeFetchDelay, "RANDOMIZE_FETCH_DELAY", "", true, "RandomizeFetchDelay setting decreases the chance of a crawler being blocked. This way a random delay ranging from 0.5 * FetchDelay to 1.5 * FetchDelay seconds is used between consecutive requests to the same domain. If FetchDelay is zero this option has no effect.")
 	RootCmd.Flags().BoolVarP(&ignoreFetchDelay, "IGNORE_FETCH_DELAY", "", false, "Ignores fetchDelay setting intended for debug purpose. Please set it to false in Production")
 
+	RootCmd.Flags().StringSliceVar(&retryHTTPCodes, "RETRY_HTTP_CODES", []string{"500", "502", "503", "504", "408"}, "HTTP status codes of failed requests to be retried. Network errors are always retried.")
+	RootCmd.Flags().IntVar(&retryDelay, "RETRY_DELAY", 500, "Base delay in milliseconds for exponential backoff between retries.")
+	RootCmd.Flags().IntVar(&retryMaxDelay, "RETRY_MAX_DELAY", 30000, "Maximum delay in milliseconds between retries.")
+
 	RootCmd.Flags().IntVar(&payloadPoolSize, "PAYLOAD_POOL_SIZE", 100, "The size of payload pool")
 	RootCmd.Flags().IntVar(&payloadWorkersNum, "PAYLOAD_WORKERS_NUM", 50, "The number of block workers")
 
@@ -174,11 +190,19 @@ func init() {
 	viper.BindPFlag("DISKV_BASE_DIR", RootCmd.Flags().Lookup("DISKV_BASE_DIR"))
 	viper.BindPFlag("MONGO", RootCmd.Flags().Lookup("MONGO"))
 	viper.BindPFlag("MAX_PAGES", RootCmd.Flags().Lookup("MAX_PAGES"))
+	viper.BindPFlag("MAX_SITEMAP_URLS", RootCmd.Flags().Lookup("MAX_SITEMAP_URLS"))
 	viper.BindPFlag("PAGINATE_RESULTS", RootCmd.Flags().Lookup("PAGINATE_RESULTS")) //not used
 	viper.BindPFlag("FETCH_DELAY", RootCmd.Flags().Lookup("FETCH_DELAY"))
-	viper.BindPFlag("RANDOMIZE_FETCH_DELAY", RootCmd.Flags().Lookup("RANDOMIZE_FETCH_DELAY")) //not used
+	viper.BindPFlag("RANDOMIZE_FETCH_DELAY", RootCmd.Flags().Lookup("RANDOMIZE_FETCH_DELAY"))
 	viper.BindPFlag("IGNORE_FETCH_DELAY", RootCmd.Flags().Lookup("IGNORE_FETCH_DELAY"))
-	viper.BindPFlag("IGNORE_ROBOTSTXT", RootCmd.Flags().Lookup("IGNORE_ROBOTSTXT")) //not used
+	viper.BindPFlag("IGNORE_ROBOTSTXT", RootCmd.Flags().Lookup("IGNORE_ROBOTSTXT"))
+	viper.BindPFlag("ROBOTSTXT_AGENT", RootCmd.Flags().Lookup("ROBOTSTXT_AGENT"))
+	viper.BindPFlag("HTTP_CACHE", RootCmd.Flags().Lookup("HTTP_CACHE"))
+	viper.BindPFlag("HTTP_CACHE_TTL", RootCmd.Flags().Lookup("HTTP_CACHE_TTL"))
+
+	viper.BindPFlag("RETRY_HTTP_CODES", RootCmd.Flags().Lookup("RETRY_HTTP_CODES"))
+	viper.BindPFlag("RETRY_DELAY", RootCmd.Flags().Lookup("RETRY_DELAY"))
+	viper.BindPFlag("RETRY_MAX_DELAY", RootCmd.Flags().Lookup("RETRY_MAX_DELAY"))
 
 	viper.BindPFlag("PAYLOAD_POOL_SIZE", RootCmd.Flags().Lookup("PAYLOAD_POOL_SIZE"))
 	viper.BindPFlag("PAYLOAD_WORKERS_NUM", RootCmd.Flags().Lookup("PAYLOAD_WORKERS_NUM"))
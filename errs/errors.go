@@ -8,7 +8,10 @@
 
 package errs
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Error represents a handler error. It provides methods for a HTTP status
 // code and embeds the built-in error interface.
@@ -87,6 +90,11 @@ func (e ParseError) Error() string {
 	return fmt.Sprintf("%s : %s", e.URL, e.Err.Error())
 }
 
+// Unwrap returns underlying error.
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
 type NotError struct {
 	Message string
 }
@@ -94,3 +102,75 @@ type NotError struct {
 func (e NotError) Error() string {
 	return e.Message
 }
+
+// TooLarge error is returned if the fetched document exceeds maximum body size.
+type TooLarge struct {
+	URL   string
+	Limit int64
+}
+
+func (e TooLarge) Error() string {
+	return fmt.Sprintf("%s : response body exceeds %d bytes", e.URL, e.Limit)
+}
+
+func (e TooLarge) Status() int {
+	return 413
+}
+
+// DOMTooLarge error is returned if the page rendered by Chrome exceeds maximum DOM size.
+type DOMTooLarge struct {
+	URL   string
+	Size  int64
+	Limit int64
+}
+
+func (e DOMTooLarge) Error() string {
+	return fmt.Sprintf("%s : DOM size %d exceeds %d bytes", e.URL, e.Size, e.Limit)
+}
+
+func (e DOMTooLarge) Status() int {
+	return 413
+}
+
+// TooManyRedirects error is returned if the fetched page is redirected more than allowed.
+type TooManyRedirects struct {
+	URL   string
+	Limit int
+}
+
+func (e TooManyRedirects) Error() string {
+	return fmt.Sprintf("%s : stopped after %d redirects", e.URL, e.Limit)
+}
+
+func (e TooManyRedirects) Status() int {
+	return 508
+}
+
+// Timeout error is returned if the fetch phase takes longer than allowed. Phase is "connect", "tls", "header" or "total".
+type Timeout struct {
+	URL     string
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e Timeout) Error() string {
+	return fmt.Sprintf("%s : %s timeout %s exceeded", e.URL, e.Phase, e.Timeout)
+}
+
+func (e Timeout) Status() int {
+	return 504
+}
+
+// JSError is returned if JavaScript error or console message of the page matches one of fatal patterns.
+type JSError struct {
+	URL     string
+	Message string
+}
+
+func (e JSError) Error() string {
+	return fmt.Sprintf("%s : fatal JavaScript error: %s", e.URL, e.Message)
+}
+
+func (e JSError) Status() int {
+	return 422
+}
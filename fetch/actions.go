@@ -3,44 +3,202 @@ package fetch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"time"
+	"unicode"
 
+	"github.com/mafredri/cdp/protocol/input"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/slotix/dataflowkit/errs"
 	"github.com/spf13/viper"
 )
 
-// [{"navigate":{"url":"http://url.com"}},
-// {"click":{"element":".element"}}]
+// Actions are passed as JSON list. Every item is an object with a single key naming the action type.
+// Options common to all actions are "timeout" in milliseconds and "continueOnError".
+// [{"input":{"element":"#search","value":"laptop"}},
+// {"press":{"key":"Enter"}},
+// {"wait":{"element":".results","visible":true,"timeout":15000}},
+// {"click":{"element":".filter","continueOnError":true}}]
 
+//defaultActionTimeout limits execution time of actions without timeout specified.
+var defaultActionTimeout = 10 * time.Second
+
+//actionConstructors creates empty actions by their types.
+var actionConstructors = map[string]func() Action{
+	"click":    func() Action { return &ClickAction{} },
+	"paginate": func() Action { return &PaginateAction{} },
+	"input":    func() Action { return &InputAction{} },
+	"type":     func() Action { return &InputAction{} },
+	"select":   func() Action { return &SelectAction{} },
+	"check":    func() Action { return &CheckAction{} },
+	"press":    func() Action { return &PressAction{} },
+	"hover":    func() Action { return &HoverAction{} },
+	"scroll":   func() Action { return &ScrollAction{} },
+	"wait":     func() Action { return &WaitAction{} },
+	"evaluate": func() Action { return &EvaluateAction{} },
+	"viewport": func() Action { return &ViewportAction{} },
+}
+
+//NewAction creates action of the given type from JSON parameters.
 func NewAction(actionType string, params json.RawMessage) (Action, error) {
-	var action Action
-	var err error
-	switch actionType {
-	case "click":
-		action = &ClickAction{}
-		err = json.Unmarshal(params, &action)
-	case "paginate":
-		action = &PaginateAction{}
-		err = json.Unmarshal(params, &action)
-	default:
-		err = fmt.Errorf("Failed to create new action. Unknown or undefined action type")
+	newAction, ok := actionConstructors[actionType]
+	if !ok {
+		return nil, fmt.Errorf("Failed to create new action. Unknown or undefined action type %s", actionType)
 	}
-	return action, err
+	action := newAction()
+	if err := json.Unmarshal(params, action); err != nil {
+		return nil, err
+	}
+	return action, nil
 }
 
+//Action is performed on the page loaded by ChromeFetcher.
 type Action interface {
 	Execute(ctx context.Context, f *ChromeFetcher) error
 }
 
+//ActionOptions are common to all actions.
+type ActionOptions struct {
+	//Timeout limits action execution time in milliseconds. Defaults to 10 seconds.
+	Timeout int `json:"timeout,omitempty"`
+	//ContinueOnError runs the next actions even if this one fails.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+//ActionResult reports the outcome of the action.
+type ActionResult struct {
+	//Type is the action type.
+	Type string `json:"type"`
+	//Result is the value returned by evaluate action.
+	Result json.RawMessage `json:"result,omitempty"`
+	//Error is set if the action failed.
+	Error string `json:"error,omitempty"`
+	//Duration is the time the action took.
+	Duration time.Duration `json:"duration"`
+}
+
+//actionStep is the action along with its options.
+type actionStep struct {
+	ActionOptions
+	typ    string
+	action Action
+}
+
+func (s actionStep) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Millisecond
+	}
+	return defaultActionTimeout
+}
+
+//parseActions decodes actions list. Nothing is executed if any of actions is invalid.
+func parseActions(actionsJSON string) ([]actionStep, error) {
+	if len(actionsJSON) == 0 {
+		return nil, nil
+	}
+	acts := []map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(actionsJSON), &acts); err != nil {
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid actions: %s", err)}
+	}
+	steps := []actionStep{}
+	for i, actionMap := range acts {
+		if len(actionMap) != 1 {
+			return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("action %d must have exactly one type", i)}
+		}
+		for actionType, params := range actionMap {
+			action, err := NewAction(actionType, params)
+			if err != nil {
+				return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("action %d: %s", i, err)}
+			}
+			step := actionStep{typ: actionType, action: action}
+			if err := json.Unmarshal(params, &step.ActionOptions); err != nil {
+				return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("action %d: %s", i, err)}
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+//runActions performs actions in order. Failed action stops the sequence unless its ContinueOnError is set.
+func (f *ChromeFetcher) runActions(ctx context.Context, actionsJSON string) ([]ActionResult, error) {
+	steps, err := parseActions(actionsJSON)
+	if err != nil {
+		return nil, err
+	}
+	results := []ActionResult{}
+	for i, step := range steps {
+		start := time.Now()
+		actionCtx, cancel := context.WithTimeout(ctx, step.timeout())
+		err := step.action.Execute(actionCtx, f)
+		if err != nil && actionCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			err = fmt.Errorf("timeout after %s", step.timeout())
+		}
+		cancel()
+		result := ActionResult{Type: step.typ, Duration: time.Since(start)}
+		if e, ok := step.action.(*EvaluateAction); ok {
+			result.Result = e.value
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+		if err != nil && !step.ContinueOnError {
+			return results, waitError(ctx, fmt.Sprintf("action %d (%s)", i, step.typ),
+				errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("action %d (%s) failed: %s", i, step.typ, err)})
+		}
+	}
+	return results, nil
+}
+
+//evaluate runs JavaScript expression and returns its value. Promises are awaited.
+func (f *ChromeFetcher) evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	reply, err := f.cdpClient.Runtime.Evaluate(ctx,
+		runtime.NewEvaluateArgs(expression).SetAwaitPromise(true).SetReturnByValue(true))
+	if err != nil {
+		return nil, err
+	}
+	if reply.ExceptionDetails != nil {
+		return nil, reply.ExceptionDetails
+	}
+	return reply.Result.Value, nil
+}
+
+//evaluateOnElement waits for the element matching selector and runs function body with the element passed as el.
+func (f *ChromeFetcher) evaluateOnElement(ctx context.Context, selector string, body string) (json.RawMessage, error) {
+	if selector == "" {
+		return nil, errors.New("element is not specified")
+	}
+	if err := f.waitForExpression(ctx, selectorExpression(selector, false)); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("element %s not found", selector)
+		}
+		return nil, err
+	}
+	sel, _ := json.Marshal(selector)
+	return f.evaluate(ctx, fmt.Sprintf("(function(el) {\n%s\n})(document.querySelector(%s))", body, sel))
+}
+
+//jsString returns JavaScript string literal.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+//ClickAction clicks the element.
 type ClickAction struct {
 	Element string `json:"element"`
 }
 
 func (a *ClickAction) Execute(ctx context.Context, f *ChromeFetcher) error {
-	path := filepath.Join(viper.GetString("CHROME_SCRIPTS"), "scroll2bottom.js")
-	return f.RunJSFromFile(ctx, path, fmt.Sprintf(`clickElement("%s");`, a.Element))
+	_, err := f.evaluateOnElement(ctx, a.Element, "el.click();")
+	return err
 }
 
+//PaginateAction scrolls the page down or clicks "more" button up to MaxPage times.
 type PaginateAction struct {
 	MaxPage int    `json:"maxpage"`
 	Element string `json:"element"`
@@ -50,3 +208,213 @@ func (pa *PaginateAction) Execute(ctx context.Context, f *ChromeFetcher) error {
 	path := filepath.Join(viper.GetString("CHROME_SCRIPTS"), "scroll2bottom.js")
 	return f.RunJSFromFile(ctx, path, fmt.Sprintf(`ScrollDown(%d, "%s");`, pa.MaxPage, pa.Element))
 }
+
+//InputAction types text into the input element. Clear removes current value first.
+type InputAction struct {
+	Element string `json:"element"`
+	Value   string `json:"value"`
+	Clear   bool   `json:"clear,omitempty"`
+}
+
+func (a *InputAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	body := "el.focus();"
+	if a.Clear {
+		body += "\nif ('value' in el) { el.value = ''; } else { el.textContent = ''; }"
+	}
+	if _, err := f.evaluateOnElement(ctx, a.Element, body); err != nil {
+		return err
+	}
+	//text is inserted as if it was typed so that input events are fired
+	if err := f.cdpClient.Input.InsertText(ctx, input.NewInsertTextArgs(a.Value)); err != nil {
+		return err
+	}
+	_, err := f.evaluateOnElement(ctx, a.Element, "el.dispatchEvent(new Event('change', {bubbles: true}));")
+	return err
+}
+
+//SelectAction selects the option of select element by its value or text.
+type SelectAction struct {
+	Element string `json:"element"`
+	Value   string `json:"value"`
+}
+
+func (a *SelectAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	_, err := f.evaluateOnElement(ctx, a.Element, fmt.Sprintf(`var value = %s;
+var opt = Array.prototype.find.call(el.options || [], function(o) { return o.value === value || o.text.trim() === value; });
+if (!opt) throw new Error('option not found: ' + value);
+el.value = opt.value;
+el.dispatchEvent(new Event('input', {bubbles: true}));
+el.dispatchEvent(new Event('change', {bubbles: true}));`, jsString(a.Value)))
+	return err
+}
+
+//CheckAction sets checkbox or radio button state. Checked defaults to true.
+type CheckAction struct {
+	Element string `json:"element"`
+	Checked *bool  `json:"checked,omitempty"`
+}
+
+func (a *CheckAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	checked := a.Checked == nil || *a.Checked
+	//element is clicked to fire the same events as user does
+	_, err := f.evaluateOnElement(ctx, a.Element, fmt.Sprintf("if (el.checked !== %t) el.click();", checked))
+	return err
+}
+
+//PressAction presses the key like "Enter", "Tab", "ArrowDown" or a single character. The element is focused first if specified.
+type PressAction struct {
+	Key     string `json:"key"`
+	Element string `json:"element,omitempty"`
+}
+
+func (a *PressAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	def, err := getKeyDefinition(a.Key)
+	if err != nil {
+		return err
+	}
+	if a.Element != "" {
+		if _, err := f.evaluateOnElement(ctx, a.Element, "el.focus();"); err != nil {
+			return err
+		}
+	}
+	down := input.NewDispatchKeyEventArgs("rawKeyDown")
+	if def.text != "" {
+		down = input.NewDispatchKeyEventArgs("keyDown").SetText(def.text).SetUnmodifiedText(def.text)
+	}
+	down.SetKey(a.Key).SetCode(def.code).SetWindowsVirtualKeyCode(def.keyCode).SetNativeVirtualKeyCode(def.keyCode)
+	if err := f.cdpClient.Input.DispatchKeyEvent(ctx, down); err != nil {
+		return err
+	}
+	up := input.NewDispatchKeyEventArgs("keyUp").
+		SetKey(a.Key).SetCode(def.code).SetWindowsVirtualKeyCode(def.keyCode).SetNativeVirtualKeyCode(def.keyCode)
+	return f.cdpClient.Input.DispatchKeyEvent(ctx, up)
+}
+
+//keyDefinition describes key event parameters.
+type keyDefinition struct {
+	code    string
+	keyCode int
+	text    string
+}
+
+var keyDefinitions = map[string]keyDefinition{
+	"Enter":      {"Enter", 13, "\r"},
+	"Tab":        {"Tab", 9, ""},
+	"Escape":     {"Escape", 27, ""},
+	"Backspace":  {"Backspace", 8, ""},
+	"Delete":     {"Delete", 46, ""},
+	" ":          {"Space", 32, " "},
+	"ArrowUp":    {"ArrowUp", 38, ""},
+	"ArrowDown":  {"ArrowDown", 40, ""},
+	"ArrowLeft":  {"ArrowLeft", 37, ""},
+	"ArrowRight": {"ArrowRight", 39, ""},
+	"PageUp":     {"PageUp", 33, ""},
+	"PageDown":   {"PageDown", 34, ""},
+	"Home":       {"Home", 36, ""},
+	"End":        {"End", 35, ""},
+}
+
+//getKeyDefinition returns event parameters of the named key or of a single character.
+func getKeyDefinition(key string) (keyDefinition, error) {
+	if def, ok := keyDefinitions[key]; ok {
+		return def, nil
+	}
+	r := []rune(key)
+	if len(r) != 1 {
+		return keyDefinition{}, fmt.Errorf("unknown key %s", key)
+	}
+	def := keyDefinition{text: key}
+	switch upper := unicode.ToUpper(r[0]); {
+	case upper >= 'A' && upper <= 'Z':
+		def.code = "Key" + string(upper)
+		def.keyCode = int(upper)
+	case r[0] >= '0' && r[0] <= '9':
+		def.code = "Digit" + key
+		def.keyCode = int(r[0])
+	}
+	return def, nil
+}
+
+//HoverAction moves mouse over the element.
+type HoverAction struct {
+	Element string `json:"element"`
+}
+
+func (a *HoverAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	value, err := f.evaluateOnElement(ctx, a.Element, `el.scrollIntoView({block: 'center', inline: 'center'});
+var r = el.getBoundingClientRect();
+return {x: r.left + r.width / 2, y: r.top + r.height / 2};`)
+	if err != nil {
+		return err
+	}
+	point := struct{ X, Y float64 }{}
+	if err := json.Unmarshal(value, &point); err != nil {
+		return err
+	}
+	return f.cdpClient.Input.DispatchMouseEvent(ctx, input.NewDispatchMouseEventArgs("mouseMoved", point.X, point.Y))
+}
+
+//ScrollAction scrolls the element into view. If no element specified, the page is scrolled to X, Y position.
+type ScrollAction struct {
+	Element string `json:"element,omitempty"`
+	X       int    `json:"x,omitempty"`
+	Y       int    `json:"y,omitempty"`
+}
+
+func (a *ScrollAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	if a.Element == "" {
+		_, err := f.evaluate(ctx, fmt.Sprintf("window.scrollTo(%d, %d)", a.X, a.Y))
+		return err
+	}
+	_, err := f.evaluateOnElement(ctx, a.Element, "el.scrollIntoView();")
+	return err
+}
+
+//WaitAction waits for the element to be attached or visible, for JavaScript expression to be true and then for the fixed delay in milliseconds.
+type WaitAction struct {
+	Element    string `json:"element,omitempty"`
+	Visible    bool   `json:"visible,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Delay      int    `json:"delay,omitempty"`
+}
+
+func (a *WaitAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	if a.Element != "" {
+		if err := f.waitForExpression(ctx, selectorExpression(a.Element, a.Visible)); err != nil {
+			return err
+		}
+	}
+	if a.Expression != "" {
+		if err := f.waitForExpression(ctx, a.Expression); err != nil {
+			return err
+		}
+	}
+	if a.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(a.Delay) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+//EvaluateAction runs JavaScript expression. Its value is reported in ActionResult.
+type EvaluateAction struct {
+	Expression string `json:"expression"`
+	value      json.RawMessage
+}
+
+func (a *EvaluateAction) Execute(ctx context.Context, f *ChromeFetcher) (err error) {
+	a.value, err = f.evaluate(ctx, a.Expression)
+	return err
+}
+
+//ViewportAction changes Chrome window size.
+type ViewportAction struct {
+	Viewport
+}
+
+func (a *ViewportAction) Execute(ctx context.Context, f *ChromeFetcher) error {
+	return f.setViewport(ctx, &a.Viewport)
+}
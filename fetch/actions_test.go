@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseActions(t *testing.T) {
+	steps, err := parseActions(`[{"input":{"element":"#q","value":"laptop","clear":true}},
+		{"press":{"key":"Enter"}},
+		{"wait":{"element":".results","visible":true,"timeout":15000}},
+		{"check":{"element":"#new","continueOnError":true}},
+		{"viewport":{"width":375,"height":667,"mobile":true}}]`)
+	assert.NoError(t, err)
+	assert.Len(t, steps, 5)
+	assert.Equal(t, &InputAction{Element: "#q", Value: "laptop", Clear: true}, steps[0].action)
+	assert.Equal(t, "press", steps[1].typ)
+	assert.Equal(t, defaultActionTimeout, steps[1].timeout())
+	assert.Equal(t, 15*time.Second, steps[2].timeout())
+	assert.True(t, steps[3].ContinueOnError)
+	assert.Equal(t, &ViewportAction{Viewport{Width: 375, Height: 667, Mobile: true}}, steps[4].action)
+
+	steps, err = parseActions("")
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+
+	for _, actions := range []string{
+		`{"click":{}}`,
+		`[{"fly":{"element":"a"}}]`,
+		`[{"click":{"element":"a"},"hover":{"element":"a"}}]`,
+	} {
+		_, err = parseActions(actions)
+		assert.Equal(t, http.StatusBadRequest, err.(errs.StatusError).Code, actions)
+	}
+}
+
+func TestGetKeyDefinition(t *testing.T) {
+	def, err := getKeyDefinition("Enter")
+	assert.NoError(t, err)
+	assert.Equal(t, keyDefinition{"Enter", 13, "\r"}, def)
+	def, err = getKeyDefinition("a")
+	assert.NoError(t, err)
+	assert.Equal(t, keyDefinition{"KeyA", 65, "a"}, def)
+	def, err = getKeyDefinition("7")
+	assert.NoError(t, err)
+	assert.Equal(t, keyDefinition{"Digit7", 55, "7"}, def)
+	_, err = getKeyDefinition("Hyper")
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/slotix/dataflowkit/errs"
+)
+
+//Authentication types
+const (
+	//BasicAuth sends username and password in Authorization header.
+	BasicAuth = "basic"
+	//BearerAuth sends token in Authorization header.
+	BearerAuth = "bearer"
+	//HeaderAuth sends value in a custom header, e.g. X-Api-Key.
+	HeaderAuth = "header"
+)
+
+//Auth contains credentials sent with the request. Type may be omitted, it is derived from the fields set.
+type Auth struct {
+	//Type is "basic", "bearer" or "header".
+	Type     string `json:"type,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	//Header is the name of the custom header.
+	Header string `json:"header,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+//header returns the name and the value of the header carrying credentials. Empty name is returned for nil Auth.
+func (a *Auth) header() (string, string, error) {
+	if a == nil {
+		return "", "", nil
+	}
+	authType := strings.ToLower(a.Type)
+	if authType == "" {
+		switch {
+		case a.Username != "":
+			authType = BasicAuth
+		case a.Token != "":
+			authType = BearerAuth
+		case a.Header != "":
+			authType = HeaderAuth
+		}
+	}
+	switch authType {
+	case BasicAuth:
+		if a.Username == "" {
+			return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("basic auth requires username")}
+		}
+		return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password)), nil
+	case BearerAuth:
+		if a.Token == "" {
+			return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("bearer auth requires token")}
+		}
+		return "Authorization", "Bearer " + a.Token, nil
+	case HeaderAuth:
+		if a.Header == "" || strings.ContainsAny(a.Header, " :\r\n") {
+			return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid auth header name %q", a.Header)}
+		}
+		return a.Header, a.Value, nil
+	}
+	return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unsupported auth type %q", a.Type)}
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHeader(t *testing.T) {
+	var a *Auth
+	name, value, err := a.header()
+	assert.NoError(t, err)
+	assert.Equal(t, "", name)
+
+	name, value, err = (&Auth{Username: "user", Password: "pass"}).header()
+	assert.NoError(t, err)
+	assert.Equal(t, "Authorization", name)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", value)
+
+	name, value, err = (&Auth{Type: "Bearer", Token: "abc"}).header()
+	assert.NoError(t, err)
+	assert.Equal(t, "Authorization", name)
+	assert.Equal(t, "Bearer abc", value)
+
+	name, value, err = (&Auth{Header: "X-Api-Key", Value: "key"}).header()
+	assert.NoError(t, err)
+	assert.Equal(t, "X-Api-Key", name)
+	assert.Equal(t, "key", value)
+
+	for _, a := range []*Auth{
+		{Type: "basic"},
+		{Type: "bearer", Username: "user"},
+		{Header: "X Api Key"},
+		{Type: "digest", Username: "user"},
+		{},
+	} {
+		_, _, err = a.header()
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(errs.StatusError).Code)
+	}
+}
+
+//TestAuthOrigin checks that Chrome fetcher adds Auth header to requests to the origin of the page only.
+func TestAuthOrigin(t *testing.T) {
+	assert.True(t, sameOrigin("https://example.com/page", "https://EXAMPLE.com/api/items?page=2"))
+	assert.False(t, sameOrigin("https://example.com/page", "http://example.com/page"))
+	assert.False(t, sameOrigin("https://example.com/page", "https://example.com:8443/page"))
+	assert.False(t, sameOrigin("https://example.com/page", "https://cdn.example.com/app.js"))
+	assert.False(t, sameOrigin("https://example.com/page", "https://tracker.com/?u=https://example.com"))
+
+	headers := withHeaders([]byte(`{"Accept":"*/*","authorization":"Basic old"}`), map[string]string{"Authorization": "Bearer abc"})
+	assert.JSONEq(t, `{"Accept":"*/*","Authorization":"Bearer abc"}`, string(headers))
+}
+
+func TestBaseFetcher_Auth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, err.(errs.StatusError).Code)
+
+	resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL, Auth: &Auth{Username: "user", Password: "pass"}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCacheKeyAuth(t *testing.T) {
+	req := Request{URL: "http://example.com", UserToken: "token"}
+	key := req.cacheKey()
+	req.Auth = &Auth{Token: "abc"}
+	assert.NotEqual(t, key, req.cacheKey())
+	req.Auth = nil
+	assert.Equal(t, key, req.cacheKey())
+}
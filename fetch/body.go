@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/slotix/dataflowkit/errs"
+)
+
+//FormPart is a part of multipart/form-data request body. Parts with FileName are sent as files.
+type FormPart struct {
+	//Name is the name of the form field.
+	Name string `json:"name"`
+	//Value is the value of the form field.
+	Value string `json:"value,omitempty"`
+	//FileName is the name of the uploaded file.
+	FileName string `json:"fileName,omitempty"`
+	//ContentType is the content type of the file. Defaults to "application/octet-stream".
+	ContentType string `json:"contentType,omitempty"`
+	//Data is the base64 encoded content of the file. Value is sent as file content if Data is empty.
+	Data []byte `json:"data,omitempty"`
+}
+
+//requestBody is the method, content type and body of the request.
+type requestBody struct {
+	method      string
+	contentType string
+	data        []byte
+}
+
+//hasBody returns true if any of request body fields is set.
+func (req Request) hasBody() bool {
+	return req.FormData != "" || req.Body != "" || len(req.JSON) > 0 || len(req.Multipart) > 0
+}
+
+//body builds the body of the request from FormData, Body, JSON or Multipart. Only one of them may be set. Method defaults to POST for requests with the body and to GET otherwise.
+func (req Request) body() (*requestBody, error) {
+	b := &requestBody{method: strings.ToUpper(strings.TrimSpace(req.Method))}
+	set := 0
+	if req.FormData != "" {
+		set++
+		values, err := parseFormData(req.FormData)
+		if err != nil {
+			return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid formData: %s", err)}
+		}
+		b.contentType = "application/x-www-form-urlencoded"
+		b.data = []byte(values.Encode())
+	}
+	if req.Body != "" {
+		set++
+		b.contentType = req.ContentType
+		if b.contentType == "" {
+			b.contentType = "text/plain; charset=utf-8"
+		}
+		b.data = []byte(req.Body)
+	}
+	if len(req.JSON) > 0 {
+		set++
+		if !json.Valid(req.JSON) {
+			return nil, errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("invalid json body")}
+		}
+		b.contentType = "application/json"
+		b.data = req.JSON
+	}
+	if len(req.Multipart) > 0 {
+		set++
+		var err error
+		if b.contentType, b.data, err = multipartBody(req.Multipart); err != nil {
+			return nil, errs.StatusError{Code: http.StatusBadRequest, Err: err}
+		}
+	}
+	if set > 1 {
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("only one of formData, body, json and multipart may be set")}
+	}
+	switch {
+	case b.method == "" && set > 0:
+		b.method = http.MethodPost
+	case b.method == "":
+		b.method = http.MethodGet
+	case strings.IndexFunc(b.method, func(r rune) bool { return r <= ' ' || r > '~' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) }) >= 0:
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid method %s", req.Method)}
+	}
+	return b, nil
+}
+
+//httpRequest creates HTTP request with the method and the body of the request.
+func (req Request) httpRequest() (*http.Request, error) {
+	b, err := req.body()
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(b.method, req.getURL(), bytes.NewReader(b.data))
+	if err != nil {
+		return nil, err
+	}
+	if b.contentType != "" {
+		r.Header.Set("Content-Type", b.contentType)
+	}
+	return r, nil
+}
+
+//multipartBody encodes form parts as multipart/form-data.
+func multipartBody(parts []FormPart) (string, []byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for i, p := range parts {
+		if p.Name == "" {
+			return "", nil, fmt.Errorf("multipart part %d has no name", i)
+		}
+		if p.FileName == "" {
+			if err := w.WriteField(p.Name, p.Value); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+		contentType := p.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.Name), escapeQuotes(p.FileName)))
+		h.Set("Content-Type", contentType)
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return "", nil, err
+		}
+		data := p.Data
+		if len(data) == 0 {
+			data = []byte(p.Value)
+		}
+		if _, err := pw.Write(data); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return w.FormDataContentType(), buf.Bytes(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+//parseFormData converts url-encoded form data string to url.Values. Keys and values are unescaped, pairs without "=" are keys with empty values.
+func parseFormData(fd string) (url.Values, error) {
+	//"auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=usr&ips_password=passw&rememberMe=0"
+	return url.ParseQuery(strings.TrimSpace(fd))
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBody(t *testing.T) {
+	b, err := Request{}.body()
+	assert.NoError(t, err)
+	assert.Equal(t, &requestBody{method: "GET"}, b)
+
+	b, err = Request{FormData: "q=a%20b&flag"}.body()
+	assert.NoError(t, err)
+	assert.Equal(t, &requestBody{method: "POST", contentType: "application/x-www-form-urlencoded", data: []byte("flag=&q=a+b")}, b)
+
+	b, err = Request{Method: "put", JSON: json.RawMessage(`{"q":"laptop"}`)}.body()
+	assert.NoError(t, err)
+	assert.Equal(t, &requestBody{method: "PUT", contentType: "application/json", data: []byte(`{"q":"laptop"}`)}, b)
+
+	b, err = Request{Method: "PATCH", Body: "<q/>", ContentType: "application/xml"}.body()
+	assert.NoError(t, err)
+	assert.Equal(t, &requestBody{method: "PATCH", contentType: "application/xml", data: []byte("<q/>")}, b)
+
+	for _, r := range []Request{
+		{FormData: "a=1", JSON: json.RawMessage(`{}`)},
+		{JSON: json.RawMessage(`{`)},
+		{Method: "GET /"},
+		{Multipart: []FormPart{{Value: "no name"}}},
+	} {
+		_, err = r.body()
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(errs.StatusError).Code)
+	}
+}
+
+func TestBaseFetcher_Body(t *testing.T) {
+	type received struct {
+		method, contentType, body string
+		form                      map[string][]string
+		file                      string
+	}
+	var got received
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = received{method: r.Method, contentType: r.Header.Get("Content-Type")}
+		if strings.HasPrefix(got.contentType, "multipart/form-data") {
+			assert.NoError(t, r.ParseMultipartForm(1<<20))
+			got.form = r.MultipartForm.Value
+			f, _, err := r.FormFile("file")
+			assert.NoError(t, err)
+			data, _ := ioutil.ReadAll(f)
+			got.file = string(data)
+		} else {
+			data, _ := ioutil.ReadAll(r.Body)
+			got.body = string(data)
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+
+	_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL, JSON: json.RawMessage(`{"q":"laptop"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, received{method: "POST", contentType: "application/json", body: `{"q":"laptop"}`}, got)
+
+	_, err = newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL, Method: "DELETE"})
+	assert.NoError(t, err)
+	assert.Equal(t, received{method: "DELETE"}, got)
+
+	_, err = newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL, Multipart: []FormPart{
+		{Name: "title", Value: "report"},
+		{Name: "file", FileName: "report.bin", Data: []byte{0, 1, 2}},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", got.method)
+	assert.Equal(t, map[string][]string{"title": {"report"}}, got.form)
+	assert.Equal(t, "\x00\x01\x02", got.file)
+}
@@ -0,0 +1,283 @@
+package fetch
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//cacheEntry is a cached response stored in storage.CACHE along with its validators.
+type cacheEntry struct {
+	//URL is the final URL of the response. Redirects lists URLs redirected before it.
+	URL        string      `json:"url"`
+	Redirects  []string    `json:"redirects,omitempty"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	//Expires is the time until the entry may be served without revalidation.
+	Expires time.Time `json:"expires"`
+	//Vary lists request headers selecting the variant of the response. The entry stored under the request key keeps Vary names only, variants are stored under keys including values of these headers.
+	Vary []string `json:"vary,omitempty"`
+}
+
+//cacheable returns true if response to the request may be taken from the cache. Only GET requests are cached.
+func (req Request) cacheable() bool {
+	if !viper.GetBool("HTTP_CACHE") || req.hasBody() {
+		return false
+	}
+	return req.Method == "" || strings.ToUpper(req.Method) == "GET"
+}
+
+//cacheKey returns storage key of the cached response. Responses are cached separately for every UserToken and Auth credentials as they may depend on cookies and credentials. Variants of responses with Vary header are stored under keys derived from it by variantRecord.
+func (req Request) cacheKey() string {
+	_, credentials, _ := req.Auth.header()
+	if credentials != "" {
+		return fmt.Sprintf("%x", md5.Sum([]byte(req.UserToken+" "+credentials+" "+req.getURL())))
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(req.UserToken+" "+req.getURL())))
+}
+
+//cachedResponse sends request using HTTP cache. Fresh cached responses are returned without contacting the server. Stale ones are revalidated with If-None-Match/If-Modified-Since. Request.NoCache bypasses cache lookup but the response is still cached. Documents over MAX_BODY_SIZE are not cached.
+func (bf *BaseFetcher) cachedResponse(r Request, req *http.Request) (*http.Response, error) {
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	rec := storage.Record{Type: storage.CACHE, Key: r.cacheKey()}
+	var entry *cacheEntry
+	if !r.NoCache {
+		entry = readCacheEntry(s, rec)
+		if entry != nil && len(entry.Vary) > 0 {
+			rec = variantRecord(rec, entry.Vary, req)
+			entry = readCacheEntry(s, rec)
+		}
+	}
+	if entry != nil {
+		if time.Now().Before(entry.Expires) {
+			return entry.response(req), nil
+		}
+		entry.setValidators(req)
+	}
+	resp, err := bf.send(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		//304 response may update cache headers
+		for _, h := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date"} {
+			if v := resp.Header.Get(h); v != "" {
+				entry.Header.Set(h, v)
+			}
+		}
+		entry.Expires = expires(entry.Header)
+		writeCacheEntry(s, rec, entry)
+		return entry.response(req), nil
+	}
+	if resp.StatusCode == http.StatusOK && storable(resp.Header) {
+		if err := checkContentLength(resp, r.getURL()); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		//one byte over MAX_BODY_SIZE is read to tell oversized documents
+		var reader io.Reader = resp.Body
+		limit := maxBodySize()
+		if limit > 0 {
+			reader = io.LimitReader(resp.Body, limit+1)
+		}
+		body, err := ioutil.ReadAll(reader)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if limit > 0 && int64(len(body)) > limit {
+			if !viper.GetBool("TRUNCATE_BODY") {
+				return nil, errs.TooLarge{URL: r.getURL(), Limit: limit}
+			}
+			//truncated documents are not cached. Fetch truncates the body read so far.
+			return checkStatus(resp)
+		}
+		entry = &cacheEntry{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+			Expires:    expires(resp.Header),
+		}
+		entry.URL, entry.Redirects = redirectChain(resp)
+		rec.Key = r.cacheKey()
+		if vary := varyHeaders(resp.Header); len(vary) > 0 {
+			writeCacheEntry(s, rec, &cacheEntry{URL: entry.URL, Expires: entry.Expires, Vary: vary})
+			rec = variantRecord(rec, vary, req)
+		}
+		writeCacheEntry(s, rec, entry)
+	}
+	return checkStatus(resp)
+}
+
+func readCacheEntry(s storage.Store, rec storage.Record) *cacheEntry {
+	data, err := s.Read(rec)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		logger.Warn("Failed to decode cached response", zap.Error(err))
+		return nil
+	}
+	return entry
+}
+
+func writeCacheEntry(s storage.Store, rec storage.Record, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Warn("Failed to encode response", zap.Error(err))
+		return
+	}
+	rec.Value = data
+	rec.ExpTime = entry.Expires.Unix()
+	if err := s.Write(rec); err != nil {
+		logger.Warn("Failed to cache response", zap.String("url", entry.URL), zap.Error(err))
+	}
+}
+
+//setValidators adds conditional headers to revalidate cached response.
+func (e *cacheEntry) setValidators(req *http.Request) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+//response converts cached entry to http.Response.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       e.request(req),
+	}
+}
+
+//request restores the final request of the cached response along with requests redirected before it, the way http.Client links them.
+func (e *cacheEntry) request(req *http.Request) *http.Request {
+	if len(e.Redirects) == 0 {
+		return req
+	}
+	r := req
+	for _, rawurl := range append(append([]string{}, e.Redirects[1:]...), e.URL) {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return req
+		}
+		next := new(http.Request)
+		*next = *r
+		next.URL = u
+		next.Response = &http.Response{Request: r}
+		r = next
+	}
+	return r
+}
+
+//cacheControl parses Cache-Control header directives.
+func cacheControl(h http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(h.Get("Cache-Control"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = strings.Trim(kv[1], `"`)
+		}
+		directives[strings.ToLower(kv[0])] = value
+	}
+	return directives
+}
+
+//storable returns false for responses which must not be cached. Responses varying on "*" are never served from cache.
+func storable(h http.Header) bool {
+	_, noStore := cacheControl(h)["no-store"]
+	for _, name := range varyHeaders(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return !noStore
+}
+
+//varyHeaders returns sorted canonical names of request headers listed in Vary header of the response.
+func varyHeaders(h http.Header) []string {
+	names := []string{}
+	for _, v := range h["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//variantRecord returns the record of the response variant selected by values of Vary headers sent with the request. Values are normalized so that insignificant whitespace and case differences select the same variant.
+func variantRecord(rec storage.Record, vary []string, req *http.Request) storage.Record {
+	key := rec.Key
+	for _, name := range vary {
+		values := []string{}
+		for _, v := range req.Header[name] {
+			for _, part := range strings.Split(v, ",") {
+				values = append(values, strings.ToLower(strings.Join(strings.Fields(part), " ")))
+			}
+		}
+		key += "\n" + name + ": " + strings.Join(values, ",")
+	}
+	rec.Key = fmt.Sprintf("%x", md5.Sum([]byte(key)))
+	return rec
+}
+
+//expires returns the time until the response is fresh. Cache-Control max-age takes precedence over Expires header. HTTP_CACHE_TTL is used for responses without explicit expiration.
+func expires(h http.Header) time.Time {
+	now := time.Now()
+	cc := cacheControl(h)
+	if _, ok := cc["no-cache"]; ok {
+		return now
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			if sec, err := strconv.Atoi(v); err == nil {
+				return now.Add(time.Duration(sec) * time.Second)
+			}
+			return now
+		}
+	}
+	if v := h.Get("Expires"); v != "" {
+		if t, err := http.ParseTime(v); err == nil {
+			return t
+		}
+		//invalid Expires value means already expired
+		return now
+	}
+	return now.Add(time.Duration(viper.GetInt("HTTP_CACHE_TTL")) * time.Second)
+}
@@ -0,0 +1,152 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseFetcher_Cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("STORAGE_TYPE", "Diskv")
+	viper.Set("DISKV_BASE_DIR", dir)
+	viper.Set("HTTP_CACHE", true)
+	defer viper.Set("HTTP_CACHE", false)
+
+	hits, notModified := 0, 0
+	cacheControl := "max-age=3600"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Write([]byte("<html>cached</html>"))
+	}))
+	defer ts.Close()
+
+	fetch := func(req Request) string {
+		resp, err := newBaseFetcher().response(context.Background(), req)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	req := Request{URL: ts.URL + "/page"}
+	assert.Equal(t, "<html>cached</html>", fetch(req))
+	//fresh response is served from the cache
+	assert.Equal(t, "<html>cached</html>", fetch(req))
+	assert.Equal(t, 1, hits)
+
+	//cache is bypassed
+	req.NoCache = true
+	assert.Equal(t, "<html>cached</html>", fetch(req))
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 0, notModified)
+
+	//stale response is revalidated
+	cacheControl = "no-cache"
+	req.URL = ts.URL + "/stale"
+	req.NoCache = false
+	fetch(req)
+	assert.Equal(t, "<html>cached</html>", fetch(req))
+	assert.Equal(t, 4, hits)
+	assert.Equal(t, 1, notModified)
+
+	//responses are cached per user
+	req.UserToken = "user"
+	fetch(req)
+	assert.Equal(t, 5, hits)
+	assert.Equal(t, 1, notModified)
+
+	//no-store responses are not cached
+	cacheControl = "no-store"
+	req.URL = ts.URL + "/nostore"
+	fetch(req)
+	fetch(req)
+	assert.Equal(t, 7, hits)
+}
+
+func TestBaseFetcher_CacheVary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("STORAGE_TYPE", "Diskv")
+	viper.Set("DISKV_BASE_DIR", dir)
+	viper.Set("HTTP_CACHE", true)
+	defer viper.Set("HTTP_CACHE", false)
+
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept-Encoding, Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	fetch := func(req Request) string {
+		req.URL = ts.URL
+		resp, err := newBaseFetcher().Fetch(context.Background(), req)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		body, _ := ioutil.ReadAll(resp)
+		return string(body)
+	}
+	assert.Equal(t, "de-DE", fetch(Request{Headers: map[string]string{"Accept-Language": "de-DE"}}))
+	assert.Equal(t, "en-US", fetch(Request{Headers: map[string]string{"Accept-Language": "en-US"}}))
+	assert.Equal(t, 2, hits)
+	//variants are selected by normalized header values
+	assert.Equal(t, "de-DE", fetch(Request{Headers: map[string]string{"accept-language": " DE-de"}}))
+	assert.Equal(t, "en-US", fetch(Request{Headers: map[string]string{"Accept-Language": "en-US"}}))
+	assert.Equal(t, 2, hits)
+	//emulated language selects the variant as well
+	assert.Equal(t, "fr-FR", fetch(Request{Emulation: &Emulation{Locale: "fr-FR"}}))
+	assert.Equal(t, "fr-FR", fetch(Request{Emulation: &Emulation{Locale: "fr-FR"}}))
+	assert.Equal(t, 3, hits)
+}
+
+func TestBaseFetcher_CacheRedirects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("STORAGE_TYPE", "Diskv")
+	viper.Set("DISKV_BASE_DIR", dir)
+	viper.Set("HTTP_CACHE", true)
+	defer viper.Set("HTTP_CACHE", false)
+
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/moved", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		default:
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Write([]byte("<html>new</html>"))
+		}
+	}))
+	defer ts.Close()
+
+	//cached response reports the final URL and redirects of the fetched one
+	for i := 0; i < 2; i++ {
+		resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/old"})
+		assert.NoError(t, err)
+		assert.Equal(t, ts.URL+"/new", resp.URL)
+		assert.Equal(t, []string{ts.URL + "/old", ts.URL + "/moved"}, resp.Redirects)
+	}
+	assert.Equal(t, 3, hits)
+}
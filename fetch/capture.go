@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mafredri/cdp/protocol/emulation"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/slotix/dataflowkit/errs"
+)
+
+//Capture types
+const (
+	//Screenshot captures PNG or JPEG image of the page or of the element.
+	Screenshot = "screenshot"
+	//PDF prints the page to PDF.
+	PDF = "pdf"
+)
+
+//Capture describes a screenshot or PDF to be taken by Chrome fetcher once the page is loaded and actions are performed.
+type Capture struct {
+	//Name identifies the capture in Response. It defaults to capture type. Name is a part of the file name captured data is saved to, so it may contain letters, digits, "_", "-" and "." only.
+	Name string `json:"name,omitempty"`
+	//Type is either "screenshot" or "pdf".
+	Type string `json:"type"`
+	//Format of the screenshot. It may be "png" or "jpeg". Defaults to "png".
+	Format string `json:"format,omitempty"`
+	//Quality of jpeg screenshot in range [0..100].
+	Quality int `json:"quality,omitempty"`
+	//FullPage captures the whole scrollable page instead of the viewport.
+	FullPage bool `json:"fullPage,omitempty"`
+	//Selector captures the element matching CSS selector only.
+	Selector string `json:"selector,omitempty"`
+	//Landscape sets PDF paper orientation.
+	Landscape bool `json:"landscape,omitempty"`
+}
+
+//Viewport sets the size of Chrome browser window.
+type Viewport struct {
+	Width             int     `json:"width"`
+	Height            int     `json:"height"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor,omitempty"`
+	Mobile            bool    `json:"mobile,omitempty"`
+}
+
+//CaptureData contains a screenshot or PDF taken by Chrome fetcher.
+type CaptureData struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data,omitempty"`
+	//Path is set by clients storing captured data to a file.
+	Path string `json:"path,omitempty"`
+}
+
+//Extension returns file extension for captured data.
+func (c CaptureData) Extension() string {
+	switch c.ContentType {
+	case "application/pdf":
+		return "pdf"
+	case "image/jpeg":
+		return "jpg"
+	default:
+		return "png"
+	}
+}
+
+//captureName matches valid capture names.
+var captureName = regexp.MustCompile(`^[\w.-]+$`)
+
+//validate checks capture settings and fills default values.
+func (c *Capture) validate() error {
+	c.Type = strings.ToLower(c.Type)
+	c.Format = strings.ToLower(c.Format)
+	switch c.Type {
+	case Screenshot:
+		switch c.Format {
+		case "":
+			c.Format = "png"
+		case "jpg":
+			c.Format = "jpeg"
+		case "png", "jpeg":
+		default:
+			return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unsupported screenshot format %s", c.Format)}
+		}
+	case PDF:
+	default:
+		return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unsupported capture type %s", c.Type)}
+	}
+	if c.Name == "" {
+		c.Name = c.Type
+	}
+	if !captureName.MatchString(c.Name) || strings.Contains(c.Name, "..") {
+		return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid capture name %s", c.Name)}
+	}
+	return nil
+}
+
+//checkCaptures validates Captures of the request replacing them with copies filled with default values.
+func (req *Request) checkCaptures() error {
+	if len(req.Captures) == 0 {
+		return nil
+	}
+	captures := make([]Capture, len(req.Captures))
+	for i, c := range req.Captures {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		captures[i] = c
+	}
+	req.Captures = captures
+	return nil
+}
+
+//setViewport overrides Chrome window size. Default window size is restored if viewport is nil.
+func (f *ChromeFetcher) setViewport(ctx context.Context, v *Viewport) error {
+	if v == nil {
+		return f.cdpClient.Emulation.ClearDeviceMetricsOverride(ctx)
+	}
+	scale := v.DeviceScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+	return f.cdpClient.Emulation.SetDeviceMetricsOverride(ctx,
+		emulation.NewSetDeviceMetricsOverrideArgs(v.Width, v.Height, scale, v.Mobile))
+}
+
+//capture takes screenshots and PDFs requested by Captures. Captures are validated by checkCaptures before the page is loaded.
+func (f *ChromeFetcher) capture(ctx context.Context, req Request) ([]CaptureData, error) {
+	captures := []CaptureData{}
+	for _, c := range req.Captures {
+		data := CaptureData{Name: c.Name, Type: c.Type}
+		var err error
+		if c.Type == PDF {
+			args := page.NewPrintToPDFArgs().SetPrintBackground(true).SetLandscape(c.Landscape)
+			var reply *page.PrintToPDFReply
+			if reply, err = f.cdpClient.Page.PrintToPDF(ctx, args); err == nil {
+				data.Data = reply.Data
+				data.ContentType = "application/pdf"
+			}
+		} else {
+			data.Data, err = f.screenshot(ctx, c, req.Viewport)
+			data.ContentType = "image/" + c.Format
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s capture failed: %s", c.Name, err)
+		}
+		captures = append(captures, data)
+	}
+	return captures, nil
+}
+
+//screenshot captures the viewport, the full page or the element.
+func (f *ChromeFetcher) screenshot(ctx context.Context, c Capture, viewport *Viewport) ([]byte, error) {
+	args := page.NewCaptureScreenshotArgs().SetFormat(c.Format)
+	if c.Format == "jpeg" && c.Quality > 0 {
+		args.SetQuality(c.Quality)
+	}
+	switch {
+	case c.Selector != "":
+		clip, err := f.elementClip(ctx, c.Selector)
+		if err != nil {
+			return nil, err
+		}
+		args.SetClip(*clip)
+	case c.FullPage:
+		metrics, err := f.cdpClient.Page.GetLayoutMetrics(ctx)
+		if err != nil {
+			return nil, err
+		}
+		width := int(math.Ceil(metrics.ContentSize.Width))
+		height := int(math.Ceil(metrics.ContentSize.Height))
+		full := Viewport{Width: width, Height: height}
+		if viewport != nil {
+			full.DeviceScaleFactor = viewport.DeviceScaleFactor
+			full.Mobile = viewport.Mobile
+		}
+		//resize window to the content size to render the whole page
+		if err = f.setViewport(ctx, &full); err != nil {
+			return nil, err
+		}
+		defer f.setViewport(ctx, viewport)
+		args.SetClip(page.Viewport{Width: float64(width), Height: float64(height), Scale: 1})
+	}
+	reply, err := f.cdpClient.Page.CaptureScreenshot(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+//elementClip returns bounding box of the element matching selector in page coordinates.
+func (f *ChromeFetcher) elementClip(ctx context.Context, selector string) (*page.Viewport, error) {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+	expression := fmt.Sprintf(`(function() {
+		var el = document.querySelector(%s);
+		if (!el) return null;
+		el.scrollIntoView();
+		var r = el.getBoundingClientRect();
+		return {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+	})()`, sel)
+	reply, err := f.cdpClient.Runtime.Evaluate(ctx, runtime.NewEvaluateArgs(expression).SetReturnByValue(true))
+	if err != nil {
+		return nil, err
+	}
+	if reply.ExceptionDetails != nil {
+		return nil, reply.ExceptionDetails
+	}
+	clip := &page.Viewport{}
+	if err = json.Unmarshal(reply.Result.Value, clip); err != nil {
+		return nil, err
+	}
+	if clip.Width == 0 || clip.Height == 0 {
+		return nil, errors.New("element not found or not visible: " + selector)
+	}
+	clip.Scale = 1
+	return clip, nil
+}
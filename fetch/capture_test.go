@@ -0,0 +1,63 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureValidate(t *testing.T) {
+	c := Capture{Type: "Screenshot"}
+	assert.NoError(t, c.validate())
+	assert.Equal(t, Capture{Name: "screenshot", Type: Screenshot, Format: "png"}, c)
+	c = Capture{Name: "thumb", Type: "screenshot", Format: "jpg"}
+	assert.NoError(t, c.validate())
+	assert.Equal(t, "jpeg", c.Format)
+	c = Capture{Type: "screenshot", Format: "gif"}
+	assert.Error(t, c.validate())
+	c = Capture{Type: "video"}
+	assert.Equal(t, http.StatusBadRequest, c.validate().(errs.StatusError).Code)
+	//names are parts of file names
+	for _, name := range []string{"../../etc/x", "a/b", `a\b`, "..", "shot 1"} {
+		c = Capture{Name: name, Type: "pdf"}
+		assert.Equal(t, http.StatusBadRequest, c.validate().(errs.StatusError).Code, name)
+	}
+	c = Capture{Name: "home_page-1.v2", Type: "pdf"}
+	assert.NoError(t, c.validate())
+
+	//captures are validated before the request is sent to Chrome
+	captures := []Capture{{Type: "Screenshot"}}
+	assert.NoError(t, CheckRequest(Request{Captures: captures}))
+	assert.Equal(t, "Screenshot", captures[0].Type)
+	assert.EqualError(t, CheckRequest(Request{Captures: []Capture{{Name: "../x", Type: "pdf"}}}), "invalid capture name ../x")
+	_, err := FetchService{}.Fetch(context.Background(), Request{URL: "http://example.com", Captures: []Capture{{Name: "../x", Type: "pdf"}}})
+	assert.Equal(t, http.StatusBadRequest, err.(errs.Error).Status())
+
+	assert.Equal(t, "pdf", CaptureData{ContentType: "application/pdf"}.Extension())
+	assert.Equal(t, "jpg", CaptureData{ContentType: "image/jpeg"}.Extension())
+	assert.Equal(t, "png", CaptureData{ContentType: "image/png"}.Extension())
+}
+
+func TestCaptureEndpoint(t *testing.T) {
+	var sent Request
+	fetchEndpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+		sent = request.(Request)
+		captures := []CaptureData{}
+		for _, c := range sent.Captures {
+			captures = append(captures, CaptureData{Name: c.Name, Type: c.Type, ContentType: "application/pdf", Data: []byte("%PDF")})
+		}
+		return &Response{Captures: captures}, nil
+	}
+	//full page capture is added by default
+	resp, err := makeCaptureEndpoint(fetchEndpoint, PDF)(context.Background(), Request{URL: "http://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Capture{{Type: PDF, FullPage: true}}, sent.Captures)
+	assert.Equal(t, []byte("%PDF"), resp.(CaptureData).Data)
+
+	_, err = makeCaptureEndpoint(fetchEndpoint, PDF)(context.Background(), Request{Captures: []Capture{{Type: "pdf", Landscape: true}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Capture{{Type: "pdf", Landscape: true}}, sent.Captures)
+}
@@ -0,0 +1,264 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mafredri/cdp"
+	"github.com/mafredri/cdp/devtool"
+	"github.com/mafredri/cdp/protocol/inspector"
+	"github.com/mafredri/cdp/protocol/target"
+	"github.com/mafredri/cdp/rpcc"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//chromeTab is a page opened in its own browser context. Cookies, cache and storage of the tab are isolated from other tabs.
+type chromeTab struct {
+	contextID target.BrowserContextID
+	targetID  target.ID
+	conn      *rpcc.Conn
+	client    *cdp.Client
+	crashed   inspector.TargetCrashedClient
+}
+
+//chromePool keeps pre-warmed Headless Chrome tabs. The number of tabs used simultaneously is limited by CHROME_POOL_SIZE. Fetches wait for a free tab when the pool is exhausted. Every tab serves a single fetch and is replaced with a fresh one afterwards.
+type chromePool struct {
+	devt *devtool.DevTools
+	//slots limits the number of tabs in use.
+	slots chan struct{}
+	//idle keeps pre-warmed tabs.
+	idle chan *chromeTab
+
+	mx          sync.Mutex
+	browserConn *rpcc.Conn
+	browser     *cdp.Client
+}
+
+var (
+	chromeTabsOnce sync.Once
+	chromeTabs     *chromePool
+)
+
+//getChromePool returns Headless Chrome tabs pool initialized with CHROME and CHROME_POOL_SIZE settings of fetch.d.
+func getChromePool() *chromePool {
+	chromeTabsOnce.Do(func() {
+		size := viper.GetInt("CHROME_POOL_SIZE")
+		if size <= 0 {
+			size = 1
+		}
+		chromeTabs = &chromePool{
+			devt:  devtool.New(viper.GetString("CHROME"), devtool.WithClient(&http.Client{})),
+			slots: make(chan struct{}, size),
+			idle:  make(chan *chromeTab, size),
+		}
+		for i := 0; i < size; i++ {
+			go chromeTabs.prewarm()
+		}
+	})
+	return chromeTabs
+}
+
+//acquire returns a tab for the fetch. Pre-warmed tabs are used for requests without proxy. Tabs for requests with proxy are created on demand as proxy is set per browser context. Error is returned if no tab is released within FETCH_TIMEOUT.
+func (p *chromePool) acquire(ctx context.Context, proxy *proxy) (*chromeTab, error) {
+	timeout := time.Duration(viper.GetInt("FETCH_TIMEOUT")) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-time.After(timeout):
+		return nil, errs.StatusError{Code: http.StatusServiceUnavailable, Err: fmt.Errorf("no free Chrome tabs in %s", timeout)}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if proxy == nil {
+		for {
+			var tab *chromeTab
+			select {
+			case tab = <-p.idle:
+			default:
+			}
+			if tab == nil {
+				break
+			}
+			if tab.alive() {
+				return tab, nil
+			}
+			logger.Warn("Recycling crashed Chrome tab", zap.String("target", string(tab.targetID)))
+			go p.dispose(tab)
+		}
+	}
+	tab, err := p.newTab(ctx, proxy)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return tab, nil
+}
+
+//release disposes the tab with its browser context so that nothing leaks to the next fetch. A fresh tab is pre-warmed instead.
+func (p *chromePool) release(tab *chromeTab) {
+	<-p.slots
+	go func() {
+		p.dispose(tab)
+		p.prewarm()
+	}()
+}
+
+//prewarm creates a tab and puts it to the idle tabs if there is a room for it.
+func (p *chromePool) prewarm() {
+	if len(p.idle) == cap(p.idle) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tab, err := p.newTab(ctx, nil)
+	if err != nil {
+		logger.Warn("Failed to create Chrome tab", zap.Error(err))
+		return
+	}
+	select {
+	case p.idle <- tab:
+	default:
+		p.dispose(tab)
+	}
+}
+
+//browserClient returns CDP client connected to the browser target. The connection is re-established if it is lost, e.g. when Chrome is restarted.
+func (p *chromePool) browserClient(ctx context.Context) (*cdp.Client, *rpcc.Conn, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.browserConn != nil {
+		select {
+		case <-p.browserConn.Context().Done():
+			p.browserConn = nil
+		default:
+			return p.browser, p.browserConn, nil
+		}
+	}
+	version, err := p.devt.Version(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := rpcc.DialContext(ctx, version.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.browserConn = conn
+	p.browser = cdp.NewClient(conn)
+	return p.browser, p.browserConn, nil
+}
+
+//newTab creates a page in a new browser context and connects to it. If proxy is set, all the traffic of the browser context is routed through the proxy.
+func (p *chromePool) newTab(ctx context.Context, proxy *proxy) (*chromeTab, error) {
+	browser, browserConn, err := p.browserClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tab := &chromeTab{}
+	if proxy == nil {
+		reply, err := browser.Target.CreateBrowserContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tab.contextID = reply.BrowserContextID
+	} else {
+		var reply target.CreateBrowserContextReply
+		err = rpcc.Invoke(ctx, "Target.createBrowserContext", &createBrowserContextArgs{ProxyServer: proxy.server()}, &reply, browserConn)
+		if err != nil {
+			return nil, err
+		}
+		tab.contextID = reply.BrowserContextID
+	}
+	created, err := browser.Target.CreateTarget(ctx, target.NewCreateTargetArgs("about:blank").SetBrowserContextID(tab.contextID))
+	if err != nil {
+		p.dispose(tab)
+		return nil, err
+	}
+	tab.targetID = created.TargetID
+	if err = p.connect(ctx, tab); err != nil {
+		p.dispose(tab)
+		return nil, err
+	}
+	return tab, nil
+}
+
+//connect dials the page target of the tab and enables CDP domains used by ChromeFetcher.
+func (p *chromePool) connect(ctx context.Context, tab *chromeTab) error {
+	targets, err := p.devt.List(ctx)
+	if err != nil {
+		return err
+	}
+	var pt *devtool.Target
+	for _, t := range targets {
+		if t.ID == string(tab.targetID) {
+			pt = t
+			break
+		}
+	}
+	if pt == nil {
+		return fmt.Errorf("target %s not found", tab.targetID)
+	}
+	opts := []rpcc.DialOption{}
+	if viper.GetBool("CHROME_TRACE") {
+		opts = append(opts, rpcc.WithCodec(func(conn io.ReadWriter) rpcc.Codec {
+			return &LogCodec{conn: conn}
+		}))
+	}
+	// Connect to WebSocket URL (page) that speaks the Chrome Debugging Protocol.
+	// ctx is used for dialing only so pre-warmed tabs stay connected.
+	tab.conn, err = rpcc.DialContext(ctx, pt.WebSocketDebuggerURL, opts...)
+	if err != nil {
+		return err
+	}
+	tab.client = cdp.NewClient(tab.conn)
+	tab.crashed, err = tab.client.Inspector.TargetCrashed(tab.conn.Context())
+	if err != nil {
+		return err
+	}
+	return runBatch(
+		// Enable all the domain events that we're interested in.
+		func() error { return tab.client.DOM.Enable(ctx) },
+		func() error { return tab.client.Network.Enable(ctx, nil) },
+		func() error { return tab.client.Page.Enable(ctx) },
+		func() error { return tab.client.Runtime.Enable(ctx) },
+		func() error { return tab.client.Inspector.Enable(ctx) },
+	)
+}
+
+//dispose closes the tab and its browser context.
+func (p *chromePool) dispose(tab *chromeTab) {
+	if tab.conn != nil {
+		tab.conn.Close()
+	}
+	browser, _, err := p.browserClient(context.Background())
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if tab.contextID != "" {
+		//disposing browser context closes all its targets
+		if err := browser.Target.DisposeBrowserContext(ctx, target.NewDisposeBrowserContextArgs(tab.contextID)); err != nil {
+			logger.Warn("Failed to dispose browser context", zap.Error(err))
+		}
+	}
+}
+
+//alive returns false if the tab has crashed or its connection is lost.
+func (tab *chromeTab) alive() bool {
+	select {
+	case <-tab.conn.Context().Done():
+		return false
+	case <-tab.crashed.Ready():
+		return false
+	default:
+		return true
+	}
+}
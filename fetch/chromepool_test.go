@@ -0,0 +1,31 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChromePoolExhausted(t *testing.T) {
+	p := &chromePool{
+		slots: make(chan struct{}, 1),
+		idle:  make(chan *chromeTab, 1),
+	}
+	//all tabs are busy
+	p.slots <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.acquire(ctx, nil)
+	assert.Equal(t, context.Canceled, err)
+
+	viper.Set("FETCH_TIMEOUT", 1)
+	defer viper.Set("FETCH_TIMEOUT", nil)
+	_, err = p.acquire(context.Background(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, err.(errs.StatusError).Code)
+}
@@ -0,0 +1,182 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+)
+
+//maxConsoleMessages limits the number of console messages returned with the response.
+const maxConsoleMessages = 1000
+
+//ConsoleException is the type of ConsoleMessage reporting uncaught JavaScript error.
+const ConsoleException = "exception"
+
+//Console requests console messages and uncaught JavaScript errors of the page collected by Chrome fetcher.
+type Console struct {
+	//Types lists console call types to be returned, e.g. ["error", "warning"]. Uncaught exceptions are always returned. All messages are returned if Types is empty.
+	Types []string `json:"types,omitempty"`
+	//Fatal lists regular expressions matched against uncaught exceptions and console errors. The fetch fails with 422 status if any of them matches. They are applied along with JS_FATAL_PATTERNS of fetch.d.
+	Fatal []string `json:"fatal,omitempty"`
+}
+
+//ConsoleMessage is a console API call or uncaught JavaScript error of the page.
+type ConsoleMessage struct {
+	//Type is console call type like "log", "info", "warning", "error" or "exception" for uncaught errors.
+	Type string `json:"type"`
+	Text string `json:"text"`
+	//URL, Line and Column point to the script location if it is known.
+	URL    string `json:"url,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+//IsError returns true for uncaught exceptions and console errors. They are matched against fatal patterns.
+func (m ConsoleMessage) IsError() bool {
+	return m.Type == ConsoleException || m.Type == "error" || m.Type == "assert"
+}
+
+//consoleRecorder collects console messages and exceptions thrown while the page is rendered.
+type consoleRecorder struct {
+	//collect is false if messages are checked against JS_FATAL_PATTERNS only.
+	collect          bool
+	types            map[string]bool
+	fatal            []*regexp.Regexp
+	consoleAPICalled runtime.ConsoleAPICalledClient
+	exceptionThrown  runtime.ExceptionThrownClient
+}
+
+//newConsoleRecorder starts listening for console messages and exceptions. Nothing is recorded if console is not requested and there are no JS_FATAL_PATTERNS.
+func (f *ChromeFetcher) newConsoleRecorder(ctx context.Context, c *Console) (*consoleRecorder, error) {
+	patterns := viper.GetStringSlice("JS_FATAL_PATTERNS")
+	if c == nil && len(patterns) == 0 {
+		return nil, nil
+	}
+	r := &consoleRecorder{collect: c != nil, types: map[string]bool{}}
+	if c != nil {
+		patterns = append(patterns, c.Fatal...)
+		for _, t := range c.Types {
+			r.types[strings.ToLower(t)] = true
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid fatal pattern: %s", err)}
+		}
+		r.fatal = append(r.fatal, re)
+	}
+	var err error
+	if r.consoleAPICalled, err = f.cdpClient.Runtime.ConsoleAPICalled(ctx); err != nil {
+		return nil, err
+	}
+	if r.exceptionThrown, err = f.cdpClient.Runtime.ExceptionThrown(ctx); err != nil {
+		r.consoleAPICalled.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *consoleRecorder) Close() {
+	if r == nil {
+		return
+	}
+	r.consoleAPICalled.Close()
+	r.exceptionThrown.Close()
+}
+
+//messages returns console messages and exceptions recorded so far. JSError is returned if an error matches one of fatal patterns.
+func (r *consoleRecorder) messages(url string) ([]ConsoleMessage, error) {
+	if r == nil {
+		return nil, nil
+	}
+	messages := []ConsoleMessage{}
+	for {
+		var m ConsoleMessage
+		select {
+		case <-r.consoleAPICalled.Ready():
+			ev, err := r.consoleAPICalled.Recv()
+			if err != nil {
+				return nil, err
+			}
+			m = consoleAPIMessage(ev)
+		case <-r.exceptionThrown.Ready():
+			ev, err := r.exceptionThrown.Recv()
+			if err != nil {
+				return nil, err
+			}
+			m = exceptionMessage(ev.ExceptionDetails)
+		default:
+			if !r.collect {
+				return nil, nil
+			}
+			return messages, nil
+		}
+		if m.IsError() {
+			for _, re := range r.fatal {
+				if re.MatchString(m.Text) {
+					return nil, errs.JSError{URL: url, Message: m.Text}
+				}
+			}
+		}
+		if r.collect && len(messages) < maxConsoleMessages && (len(r.types) == 0 || r.types[m.Type] || m.Type == ConsoleException) {
+			messages = append(messages, m)
+		}
+	}
+}
+
+//consoleAPIMessage joins arguments of console call the way browser devtools print them.
+func consoleAPIMessage(ev *runtime.ConsoleAPICalledReply) ConsoleMessage {
+	args := make([]string, 0, len(ev.Args))
+	for _, arg := range ev.Args {
+		args = append(args, remoteObjectText(arg))
+	}
+	m := ConsoleMessage{Type: ev.Type, Text: strings.Join(args, " ")}
+	if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+		frame := ev.StackTrace.CallFrames[0]
+		m.URL, m.Line, m.Column = frame.URL, frame.LineNumber+1, frame.ColumnNumber+1
+	}
+	return m
+}
+
+//exceptionMessage returns uncaught error message like "Uncaught TypeError: x is undefined".
+func exceptionMessage(details runtime.ExceptionDetails) ConsoleMessage {
+	m := ConsoleMessage{Type: ConsoleException, Text: details.Text, Line: details.LineNumber + 1, Column: details.ColumnNumber + 1}
+	if details.Exception != nil {
+		//description contains the stack trace after the first line
+		description := strings.SplitN(remoteObjectText(*details.Exception), "\n", 2)[0]
+		if description != "" && !strings.Contains(m.Text, description) {
+			m.Text = strings.TrimSpace(m.Text + " " + description)
+		}
+	}
+	if details.URL != nil {
+		m.URL = *details.URL
+	} else if details.StackTrace != nil && len(details.StackTrace.CallFrames) > 0 {
+		m.URL = details.StackTrace.CallFrames[0].URL
+	}
+	return m
+}
+
+func remoteObjectText(o runtime.RemoteObject) string {
+	if len(o.Value) > 0 {
+		var s string
+		if json.Unmarshal(o.Value, &s) == nil {
+			return s
+		}
+		return string(o.Value)
+	}
+	if o.UnserializableValue != nil {
+		return string(*o.UnserializableValue)
+	}
+	if o.Description != nil {
+		return *o.Description
+	}
+	return o.Type
+}
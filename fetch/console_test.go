@@ -0,0 +1,103 @@
+package fetch
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+//eventStream replays events of Chrome runtime domain.
+type eventStream struct {
+	console    []*runtime.ConsoleAPICalledReply
+	exceptions []*runtime.ExceptionThrownReply
+}
+
+func pending(n int) <-chan struct{} {
+	if n == 0 {
+		return nil
+	}
+	c := make(chan struct{})
+	close(c)
+	return c
+}
+
+type consoleStream struct{ *eventStream }
+
+func (s consoleStream) Ready() <-chan struct{}      { return pending(len(s.console)) }
+func (s consoleStream) RecvMsg(m interface{}) error { return nil }
+func (s consoleStream) Close() error                { return nil }
+func (s consoleStream) Recv() (*runtime.ConsoleAPICalledReply, error) {
+	ev := s.console[0]
+	s.console = s.console[1:]
+	return ev, nil
+}
+
+type exceptionStream struct{ *eventStream }
+
+func (s exceptionStream) Ready() <-chan struct{}      { return pending(len(s.exceptions)) }
+func (s exceptionStream) RecvMsg(m interface{}) error { return nil }
+func (s exceptionStream) Close() error                { return nil }
+func (s exceptionStream) Recv() (*runtime.ExceptionThrownReply, error) {
+	ev := s.exceptions[0]
+	s.exceptions = s.exceptions[1:]
+	return ev, nil
+}
+
+func newTestConsoleRecorder(collect bool, types []string, fatal ...string) *consoleRecorder {
+	description := "TypeError: Cannot read properties of undefined (reading 'price')\n    at render (app.js:10:5)"
+	url := "http://example.com/app.js"
+	events := &eventStream{
+		console: []*runtime.ConsoleAPICalledReply{
+			{Type: "log", Args: []runtime.RemoteObject{{Type: "string", Value: json.RawMessage(`"loaded"`)}, {Type: "number", Value: json.RawMessage(`3`)}}},
+			{Type: "error", Args: []runtime.RemoteObject{{Type: "string", Value: json.RawMessage(`"API failed"`)}},
+				StackTrace: &runtime.StackTrace{CallFrames: []runtime.CallFrame{{URL: url, LineNumber: 4, ColumnNumber: 2}}}},
+		},
+		exceptions: []*runtime.ExceptionThrownReply{
+			{ExceptionDetails: runtime.ExceptionDetails{Text: "Uncaught", LineNumber: 9, ColumnNumber: 4, URL: &url,
+				Exception: &runtime.RemoteObject{Type: "object", Description: &description}}},
+		},
+	}
+	r := &consoleRecorder{collect: collect, types: map[string]bool{}, consoleAPICalled: consoleStream{events}, exceptionThrown: exceptionStream{events}}
+	for _, t := range types {
+		r.types[t] = true
+	}
+	for _, p := range fatal {
+		r.fatal = append(r.fatal, regexp.MustCompile(p))
+	}
+	return r
+}
+
+func TestConsoleRecorder(t *testing.T) {
+	messages, err := newTestConsoleRecorder(true, nil).messages("http://example.com")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []ConsoleMessage{
+		{Type: "log", Text: "loaded 3"},
+		{Type: "error", Text: "API failed", URL: "http://example.com/app.js", Line: 5, Column: 3},
+		{Type: ConsoleException, Text: "Uncaught TypeError: Cannot read properties of undefined (reading 'price')", URL: "http://example.com/app.js", Line: 10, Column: 5},
+	}, messages)
+
+	//exceptions are returned along with selected types
+	messages, err = newTestConsoleRecorder(true, []string{"warning"}).messages("http://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.True(t, messages[0].IsError())
+
+	//log messages are never fatal
+	_, err = newTestConsoleRecorder(true, nil, "loaded").messages("http://example.com")
+	assert.NoError(t, err)
+	_, err = newTestConsoleRecorder(false, nil, `TypeError.*price`).messages("http://example.com")
+	assert.Equal(t, errs.JSError{URL: "http://example.com", Message: "Uncaught TypeError: Cannot read properties of undefined (reading 'price')"}, err)
+	assert.Equal(t, 422, err.(errs.Error).Status())
+
+	messages, err = newTestConsoleRecorder(false, nil, "unmatched").messages("http://example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, messages)
+
+	messages, err = (*consoleRecorder)(nil).messages("http://example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, messages)
+}
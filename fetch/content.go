@@ -0,0 +1,76 @@
+package fetch
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//Kinds of fetched documents. Kind is detected from Content-Type of the response and from the document content if Content-Type is missing or generic.
+const (
+	KindHTML   = "html"
+	KindJSON   = "json"
+	KindXML    = "xml"
+	KindPDF    = "pdf"
+	KindImage  = "image"
+	KindText   = "text"
+	KindBinary = "binary"
+)
+
+//ContentKind returns the kind of document with given Content-Type and content.
+func ContentKind(contentType string, body []byte) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" || mediaType == "text/plain" {
+		//servers often send JSON and XML documents as plain text
+		if kind := sniffKind(body); kind != "" {
+			return kind
+		}
+		if mediaType == "" || err != nil {
+			mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+		}
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return KindHTML
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return KindJSON
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return KindXML
+	case mediaType == "application/pdf":
+		return KindPDF
+	case strings.HasPrefix(mediaType, "image/"):
+		return KindImage
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/javascript":
+		return KindText
+	}
+	return KindBinary
+}
+
+//sniffKind detects JSON, XML, HTML and PDF documents by their content.
+func sniffKind(body []byte) string {
+	b := bytes.TrimLeft(body, " \t\r\n\xef\xbb\xbf")
+	switch {
+	case bytes.HasPrefix(b, []byte("%PDF-")):
+		return KindPDF
+	case len(b) > 0 && (b[0] == '{' || b[0] == '['):
+		return KindJSON
+	case bytes.HasPrefix(b, []byte("<?xml")):
+		head := b
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		if bytes.Contains(bytes.ToLower(head), []byte("<html")) {
+			return KindHTML
+		}
+		return KindXML
+	case len(b) > 0 && b[0] == '<':
+		return KindHTML
+	}
+	return ""
+}
+
+//IsBinary returns true for documents which are not text. Charset detection and conversion are skipped for them.
+func IsBinary(kind string) bool {
+	return kind == KindPDF || kind == KindImage || kind == KindBinary
+}
@@ -0,0 +1,35 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentKind(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		kind        string
+	}{
+		{"text/html; charset=utf-8", "<html></html>", KindHTML},
+		{"application/json", `{"a":1}`, KindJSON},
+		{"application/ld+json", `{"a":1}`, KindJSON},
+		{"text/plain", ` [1, 2]`, KindJSON},
+		{"application/rss+xml", "<rss/>", KindXML},
+		{"text/xml", "<?xml version=\"1.0\"?><feed/>", KindXML},
+		{"", "<?xml version=\"1.0\"?><feed/>", KindXML},
+		{"", "<!DOCTYPE html><html></html>", KindHTML},
+		{"application/octet-stream", "%PDF-1.4", KindPDF},
+		{"application/pdf", "%PDF-1.4", KindPDF},
+		{"image/png", "\x89PNG\r\n\x1a\n", KindImage},
+		{"", "\x89PNG\r\n\x1a\n", KindImage},
+		{"text/plain", "hello", KindText},
+		{"application/zip", "PK\x03\x04", KindBinary},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.kind, ContentKind(tt.contentType, []byte(tt.body)), tt.contentType+" "+tt.body)
+	}
+	assert.True(t, IsBinary(KindPDF))
+	assert.False(t, IsBinary(KindJSON))
+}
@@ -0,0 +1,516 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/storage"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"golang.org/x/net/publicsuffix"
+)
+
+//Cookie is a cookie of the user session as it is listed, imported and exported by cookies API. The format is compatible with cookies exported by browser extensions and by Chrome DevTools protocol.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	//Domain of the cookie. A leading dot is ignored.
+	Domain string `json:"domain,omitempty"`
+	Path   string `json:"path,omitempty"`
+	//Expires is expiration time in seconds since the UNIX epoch. Session cookies have no expiration time.
+	Expires float64 `json:"expires,omitempty"`
+	//ExpirationDate is an alias of Expires used by browser extensions.
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	//HostOnly cookies are sent to the host which set them only. Cookies without Domain are host only.
+	HostOnly bool `json:"hostOnly,omitempty"`
+	Secure   bool `json:"secure,omitempty"`
+	HTTPOnly bool `json:"httpOnly,omitempty"`
+	//SameSite is "Strict", "Lax" or "None".
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+//newCookie converts session cookie of the host to Cookie.
+func newCookie(c *http.Cookie, host string) Cookie {
+	cookie := Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+	}
+	if cookie.Domain == "" {
+		cookie.Domain = host
+		cookie.HostOnly = true
+	}
+	if !c.Expires.IsZero() {
+		cookie.Expires = float64(c.Expires.Unix())
+	}
+	switch c.SameSite {
+	case http.SameSiteStrictMode:
+		cookie.SameSite = "Strict"
+	case http.SameSiteLaxMode:
+		cookie.SameSite = "Lax"
+	case http.SameSiteNoneMode:
+		cookie.SameSite = "None"
+	}
+	return cookie
+}
+
+//httpCookie converts Cookie to session cookie of the host. Host only cookies keep empty Domain. Nil is returned if the cookie is not sent to the host.
+func (c Cookie) httpCookie(host string) (*http.Cookie, error) {
+	if c.Name == "" {
+		return nil, errors.New("cookie has no name")
+	}
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+	}
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	switch {
+	case domain == "":
+	case c.HostOnly && domain != host:
+		return nil, nil
+	case c.HostOnly:
+	case !domainMatch(host, domain):
+		return nil, nil
+	default:
+		cookie.Domain = domain
+	}
+	expires := c.Expires
+	if expires == 0 {
+		expires = c.ExpirationDate
+	}
+	if expires > 0 {
+		sec, dec := math.Modf(expires)
+		cookie.Expires = time.Unix(int64(sec), int64(dec*1e9))
+	}
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "none", "no_restriction":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie, nil
+}
+
+//domainMatch returns true if cookies of the domain are sent to the host.
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+//parseNetscapeCookies parses cookies.txt file in Netscape format. Each line contains tab separated domain, include subdomains flag, path, secure flag, expiration time, name and value. Lines starting with "#HttpOnly_" contain HTTP only cookies, other lines starting with "#" are comments.
+func parseNetscapeCookies(data []byte) ([]Cookie, error) {
+	cookies := []Cookie{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			//cookies without value
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", n, len(fields))
+		}
+		expires, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiration time %s", n, fields[4])
+		}
+		cookies = append(cookies, Cookie{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Expires:  expires,
+			Name:     fields[5],
+			Value:    fields[6],
+			HTTPOnly: httpOnly,
+		})
+	}
+	return cookies, scanner.Err()
+}
+
+//formatNetscapeCookies writes cookies in Netscape cookies.txt format accepted by curl, wget and browser extensions.
+func formatNetscapeCookies(cookies []Cookie) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	flag := func(v bool) string {
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	for _, c := range cookies {
+		domain := c.Domain
+		if c.HTTPOnly {
+			b.WriteString("#HttpOnly_")
+		}
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, flag(!c.HostOnly), path, flag(c.Secure), int64(c.Expires), c.Name, c.Value)
+	}
+	return b.Bytes()
+}
+
+//parseCookies parses JSON array of cookies or cookies.txt file in Netscape format.
+func parseCookies(contentType string, data []byte) ([]Cookie, error) {
+	trimmed := bytes.TrimSpace(data)
+	if strings.Contains(contentType, "json") || bytes.HasPrefix(trimmed, []byte("[")) {
+		cookies := []Cookie{}
+		if err := json.Unmarshal(trimmed, &cookies); err != nil {
+			return nil, err
+		}
+		return cookies, nil
+	}
+	return parseNetscapeCookies(data)
+}
+
+//sessionJar is a cookie jar keeping all the attributes of cookies so that the session may be saved and shared between Base and Chrome fetchers. Cookies are sent with requests by cookiejar.Jar.
+type sessionJar struct {
+	jar     *cookiejar.Jar
+	mx      sync.Mutex
+	cookies map[string]*sessionCookie
+}
+
+//sessionCookie is a cookie along with the host which set it.
+type sessionCookie struct {
+	cookie *http.Cookie
+	host   string
+}
+
+//newSessionJar creates empty cookie jar remembering hosts which set cookies.
+func newSessionJar() (*sessionJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	return &sessionJar{jar: jar, cookies: map[string]*sessionCookie{}}, nil
+}
+
+//Cookies implements http.CookieJar interface.
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+//SetCookies implements http.CookieJar interface. Expired cookies and cookies with negative MaxAge remove the cookie from the session.
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	for _, c := range cookies {
+		cookie := *c
+		cookie.Raw, cookie.Unparsed, cookie.RawExpires = "", nil, ""
+		cookie.Domain = strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+		if cookie.Domain != "" && !domainMatch(host, cookie.Domain) {
+			continue
+		}
+		if cookie.Path == "" || cookie.Path[0] != '/' {
+			cookie.Path = defaultCookiePath(u.Path)
+		}
+		key := cookie.Name + ";" + cookie.Domain + ";" + cookie.Path
+		if cookie.Domain == "" {
+			key += ";" + host
+		}
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && !cookie.Expires.After(now)) {
+			delete(j.cookies, key)
+			continue
+		}
+		if cookie.MaxAge > 0 {
+			cookie.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			cookie.MaxAge = 0
+		}
+		j.cookies[key] = &sessionCookie{cookie: &cookie, host: host}
+	}
+}
+
+//all returns not expired cookies sent to the host of u along with all their attributes. Cookies set by other hosts of the same domain are returned with their domain.
+func (j *sessionJar) all(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	keys := make([]string, 0, len(j.cookies))
+	for k := range j.cookies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cookies := []*http.Cookie{}
+	for _, k := range keys {
+		sc := j.cookies[k]
+		c := *sc.cookie
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			continue
+		}
+		switch {
+		case c.Domain == "" && sc.host != host:
+			continue
+		case c.Domain != "" && !domainMatch(host, c.Domain):
+			continue
+		}
+		cookies = append(cookies, &c)
+	}
+	return cookies
+}
+
+//defaultCookiePath returns default path of cookies set by the request path as defined in RFC 6265 section 5.1.4.
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+//sessionKey returns storage record of cookies of the user session with the host.
+func sessionKey(userToken, host string) storage.Record {
+	return storage.Record{Type: storage.COOKIES, Key: userToken + host}
+}
+
+//loadSession reads cookies of the user session with the host from the store. Missing session has no cookies.
+func loadSession(s storage.Store, userToken, host string) ([]*http.Cookie, error) {
+	cookies := []*http.Cookie{}
+	data, err := s.Read(sessionKey(userToken, host))
+	if err != nil {
+		logger.Debug(err.Error(), zap.String("User Token", userToken))
+		return cookies, nil
+	}
+	if len(data) == 0 {
+		return cookies, nil
+	}
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+//saveSession writes cookies of the user session with the host to the store.
+func saveSession(s storage.Store, userToken, host string, cookies []*http.Cookie) error {
+	data, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+	rec := sessionKey(userToken, host)
+	rec.Value = data
+	return s.Write(rec)
+}
+
+//mergeSession adds cookies to the session cookies as if they were set by the host. Expired cookies remove the cookie from the session.
+func mergeSession(host string, session, cookies []*http.Cookie) ([]*http.Cookie, error) {
+	jar, err := newSessionJar()
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{Scheme: "http", Host: host, Path: "/"}
+	jar.SetCookies(u, session)
+	jar.SetCookies(u, cookies)
+	return jar.all(u), nil
+}
+
+//sessionParams returns userToken and host query parameters of cookies API request. Host may be passed as URL.
+func sessionParams(r *http.Request) (string, string, error) {
+	userToken := r.URL.Query().Get("userToken")
+	host := r.URL.Query().Get("host")
+	if strings.Contains(host, "://") {
+		u, err := url.Parse(host)
+		if err != nil {
+			return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: err}
+		}
+		host = u.Host
+	}
+	if userToken == "" || host == "" {
+		return "", "", errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("userToken and host parameters are required")}
+	}
+	return userToken, host, nil
+}
+
+//hostname returns host without port.
+func hostname(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
+
+//sessionCookies converts session cookies of the host to Cookie list returned by cookie API and recorded in cassettes.
+func sessionCookies(cookies []*http.Cookie, host string) []Cookie {
+	list := make([]Cookie, len(cookies))
+	for i, c := range cookies {
+		list[i] = newCookie(c, hostname(host))
+	}
+	return list
+}
+
+//writeCookies writes session cookies of the host as JSON response.
+func writeCookies(w http.ResponseWriter, cookies []*http.Cookie, host string) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(sessionCookies(cookies, host))
+}
+
+//listCookiesHandler returns cookies of the user session with the host.
+func listCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	userToken, host, err := sessionParams(r)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	cookies, err := loadSession(s, userToken, host)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	writeCookies(w, cookies, host)
+}
+
+//importCookiesHandler merges cookies passed as JSON array or in Netscape cookies.txt format into the user session with the host. Cookies not sent to the host are skipped. Resulting session cookies are returned.
+func importCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	userToken, host, err := sessionParams(r)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	imported, err := parseCookies(r.Header.Get("Content-Type"), data)
+	if err != nil {
+		encodeError(r.Context(), errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid cookies: %s", err)}, w)
+		return
+	}
+	cookies := []*http.Cookie{}
+	for _, c := range imported {
+		cookie, err := c.httpCookie(hostname(host))
+		if err != nil {
+			encodeError(r.Context(), errs.StatusError{Code: http.StatusBadRequest, Err: err}, w)
+			return
+		}
+		if cookie != nil {
+			cookies = append(cookies, cookie)
+		}
+	}
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	session, err := loadSession(s, userToken, host)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	if session, err = mergeSession(host, session, cookies); err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	if err = saveSession(s, userToken, host, session); err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	writeCookies(w, session, host)
+}
+
+//exportCookiesHandler returns cookies of the user session with the host as a file in Netscape cookies.txt ("format=netscape", default) or JSON ("format=json") format.
+func exportCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	userToken, host, err := sessionParams(r)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "netscape" && format != "json" {
+		encodeError(r.Context(), errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unsupported format %s", format)}, w)
+		return
+	}
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	cookies, err := loadSession(s, userToken, host)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	fileName := hostname(host) + ".cookies"
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, fileName))
+		json.NewEncoder(w).Encode(sessionCookies(cookies, host))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.txt"`, fileName))
+	w.Write(formatNetscapeCookies(sessionCookies(cookies, host)))
+}
+
+//deleteCookiesHandler deletes the user session with the host. Only cookies with the given name are deleted if "name" parameter is passed.
+func deleteCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	userToken, host, err := sessionParams(r)
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	s := storage.NewStore(viper.GetString("STORAGE_TYPE"))
+	defer s.Close()
+	name := r.URL.Query().Get("name")
+	cookies := []*http.Cookie{}
+	if name != "" {
+		session, err := loadSession(s, userToken, host)
+		if err != nil {
+			encodeError(r.Context(), err, w)
+			return
+		}
+		for _, c := range session {
+			if c.Name != name {
+				cookies = append(cookies, c)
+			}
+		}
+	}
+	if len(cookies) > 0 {
+		err = saveSession(s, userToken, host, cookies)
+	} else if s.IsExists(sessionKey(userToken, host)) {
+		err = s.Delete(sessionKey(userToken, host))
+	}
+	if err != nil {
+		encodeError(r.Context(), err, w)
+		return
+	}
+	writeCookies(w, cookies, host)
+}
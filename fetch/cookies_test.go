@@ -0,0 +1,143 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionJar(t *testing.T) {
+	jar, err := newSessionJar()
+	assert.NoError(t, err)
+	u, _ := url.Parse("http://www.example.com/account/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "1", HttpOnly: true, MaxAge: 3600},
+		{Name: "lang", Value: "en", Domain: ".Example.com", Path: "/"},
+		{Name: "other", Value: "x", Domain: "other.com"},
+		{Name: "old", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	cookies := jar.all(u)
+	assert.Len(t, cookies, 2)
+	assert.Equal(t, "lang", cookies[0].Name)
+	assert.Equal(t, "example.com", cookies[0].Domain)
+	assert.Equal(t, "sid", cookies[1].Name)
+	assert.Equal(t, "", cookies[1].Domain)
+	assert.Equal(t, "/account", cookies[1].Path)
+	assert.True(t, cookies[1].HttpOnly)
+	assert.True(t, cookies[1].Expires.After(time.Now()))
+	assert.Equal(t, 2, len(jar.Cookies(u)))
+
+	//host only cookies are not sent to other hosts of the domain
+	sub, _ := url.Parse("http://shop.example.com/")
+	assert.Len(t, jar.all(sub), 1)
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "sid", Path: "/account", MaxAge: -1}})
+	assert.Len(t, jar.all(u), 1)
+}
+
+func TestNetscapeCookies(t *testing.T) {
+	txt := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tFALSE\t2000000000\tlang\ten\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/account\tTRUE\t0\tsid\tabc=\n" +
+		"# comment\n\n"
+	cookies, err := parseCookies("text/plain", []byte(txt))
+	assert.NoError(t, err)
+	assert.Equal(t, []Cookie{
+		{Name: "lang", Value: "en", Domain: ".example.com", Path: "/", Expires: 2000000000},
+		{Name: "sid", Value: "abc=", Domain: "www.example.com", Path: "/account", HostOnly: true, Secure: true, HTTPOnly: true},
+	}, cookies)
+
+	c, err := cookies[0].httpCookie("www.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", c.Domain)
+	assert.Equal(t, int64(2000000000), c.Expires.Unix())
+	cookies[0].Domain = "example.com"
+	assert.Equal(t, txt[:len(txt)-len("# comment\n\n")], string(formatNetscapeCookies(cookies)))
+
+	c, err = cookies[1].httpCookie("shop.example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	_, err = parseCookies("", []byte("example.com\tTRUE\t/\n"))
+	assert.Error(t, err)
+
+	cookies, err = parseCookies("", []byte(`[{"name":"a","value":"1","domain":".example.com","expirationDate":2000000000.5,"sameSite":"lax","session":false}]`))
+	assert.NoError(t, err)
+	c, err = cookies[0].httpCookie("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+	assert.Equal(t, int64(2000000000), c.Expires.Unix())
+}
+
+func TestCookiesAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookies")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("STORAGE_TYPE", "Diskv")
+	viper.Set("DISKV_BASE_DIR", dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("imported"); err == nil {
+			w.Write([]byte(c.Value))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/", HttpOnly: true})
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	handler := newHttpHandler(context.Background(), endpoints{})
+	call := func(method, query, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/cookies"+query, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	list := func(w *httptest.ResponseRecorder) []Cookie {
+		cookies := []Cookie{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cookies))
+		return cookies
+	}
+	query := "?userToken=user&host=" + url.QueryEscape(ts.URL)
+
+	assert.Equal(t, http.StatusBadRequest, call("GET", "?host=example.com", "", "").Code)
+	assert.Empty(t, list(call("GET", query, "", "")))
+
+	//cookies set during fetch are kept in the session
+	_, err = FetchService{}.Fetch(context.Background(), Request{URL: ts.URL, UserToken: "user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Cookie{{Name: "sid", Value: "1", Domain: u.Hostname(), Path: "/", HostOnly: true, HTTPOnly: true}}, list(call("GET", query, "", "")))
+
+	//imported cookies are sent with the next fetch
+	w := call("POST", query, "text/plain", u.Hostname()+"\tFALSE\t/\tFALSE\t0\timported\tyes\nexample.com\tTRUE\t/\tFALSE\t0\tskipped\tyes\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, list(w), 2)
+	resp, err := FetchService{}.Fetch(context.Background(), Request{URL: ts.URL, UserToken: "user"})
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp)
+	assert.Equal(t, "yes", string(body))
+	assert.Equal(t, http.StatusBadRequest, call("POST", query, "application/json", "{").Code)
+
+	w = call("GET", "/export"+query, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), u.Hostname()+".cookies.txt")
+	assert.Contains(t, w.Body.String(), "#HttpOnly_"+u.Hostname()+"\tFALSE\t/\tFALSE\t0\tsid\t1\n")
+	w = call("GET", "/export"+query+"&format=json", "", "")
+	assert.Len(t, list(w), 2)
+	assert.Equal(t, http.StatusBadRequest, call("GET", "/export"+query+"&format=xml", "", "").Code)
+
+	assert.Len(t, list(call("DELETE", query+"&name=sid", "", "")), 1)
+	assert.Equal(t, "imported", list(call("GET", query, "", ""))[0].Name)
+	assert.Empty(t, list(call("DELETE", query, "", "")))
+	assert.Empty(t, list(call("GET", query, "", "")))
+}
@@ -0,0 +1,304 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mafredri/cdp/protocol/browser"
+	"github.com/mafredri/cdp/protocol/emulation"
+	"github.com/mafredri/cdp/rpcc"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//Emulation describes device, locale and location emulated by Chrome fetcher. Fields override the values of the named Profile. Base fetcher sends the matching User-Agent and Accept-Language headers only.
+type Emulation struct {
+	//Profile is the name of the built-in profile ("desktop", "laptop", "mobile", "tablet", "iphone", "pixel", "ipad") or the profile from EMULATION_PROFILES_FILE of fetch.d.
+	Profile string `json:"profile,omitempty"`
+	//Viewport sets window size, device scale factor and mobile mode. Request.Viewport takes precedence over it.
+	Viewport *Viewport `json:"viewport,omitempty"`
+	//Touch enables touch events support.
+	Touch *bool `json:"touch,omitempty"`
+	//UserAgent is used if neither Request.UserAgent nor User-Agent header is set.
+	UserAgent string `json:"userAgent,omitempty"`
+	//AcceptLanguage is used if Accept-Language header is not set. It defaults to Locale.
+	AcceptLanguage string `json:"acceptLanguage,omitempty"`
+	//Locale is ICU locale like "de-DE" used by Intl API of the page. It defaults to the first language of AcceptLanguage.
+	Locale string `json:"locale,omitempty"`
+	//Timezone is IANA time zone ID like "Europe/Berlin".
+	Timezone string `json:"timezone,omitempty"`
+	//Geolocation is the position returned by Geolocation API. The page is granted the permission to read it.
+	Geolocation *Geolocation `json:"geolocation,omitempty"`
+	//ColorScheme is emulated prefers-color-scheme media feature: "light", "dark" or "no-preference".
+	ColorScheme string `json:"colorScheme,omitempty"`
+}
+
+//Geolocation is a position in degrees. Accuracy is in meters.
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy,omitempty"`
+}
+
+var (
+	touch   = true
+	desktop = Emulation{
+		Viewport:  &Viewport{Width: 1920, Height: 1080, DeviceScaleFactor: 1},
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	}
+	laptop = Emulation{
+		Viewport:  &Viewport{Width: 1366, Height: 768, DeviceScaleFactor: 1},
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	}
+	iphone = Emulation{
+		Viewport:  &Viewport{Width: 390, Height: 844, DeviceScaleFactor: 3, Mobile: true},
+		Touch:     &touch,
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+	}
+	pixel = Emulation{
+		Viewport:  &Viewport{Width: 412, Height: 915, DeviceScaleFactor: 2.625, Mobile: true},
+		Touch:     &touch,
+		UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+	}
+	ipad = Emulation{
+		Viewport:  &Viewport{Width: 820, Height: 1180, DeviceScaleFactor: 2, Mobile: true},
+		Touch:     &touch,
+		UserAgent: "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+	}
+	//builtinEmulations are emulation profiles available without EMULATION_PROFILES_FILE.
+	builtinEmulations = map[string]Emulation{
+		"desktop": desktop,
+		"laptop":  laptop,
+		"mobile":  pixel,
+		"tablet":  ipad,
+		"iphone":  iphone,
+		"pixel":   pixel,
+		"ipad":    ipad,
+	}
+)
+
+var colorSchemes = map[string]bool{"light": true, "dark": true, "no-preference": true}
+
+var (
+	emulationsOnce sync.Once
+	emulations     map[string]Emulation
+	emulationsErr  error
+)
+
+//loadEmulations returns built-in emulation profiles along with the profiles from JSON file mapping profile names to Emulation objects. Profiles from the file replace built-in ones with the same name.
+func loadEmulations(path string) (map[string]Emulation, error) {
+	profiles := map[string]Emulation{}
+	for name, e := range builtinEmulations {
+		profiles[name] = e
+	}
+	if path == "" {
+		return profiles, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	custom := map[string]Emulation{}
+	if err = json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for name, e := range custom {
+		if e.Profile != "" {
+			return nil, fmt.Errorf("%s: emulation profile %q refers to another profile", path, name)
+		}
+		if err = e.validate(); err != nil {
+			return nil, fmt.Errorf("%s: emulation profile %q: %s", path, name, err)
+		}
+		profiles[strings.ToLower(name)] = e
+	}
+	return profiles, nil
+}
+
+//getEmulation returns emulation profile with the given name.
+func getEmulation(name string) (Emulation, error) {
+	emulationsOnce.Do(func() {
+		emulations, emulationsErr = loadEmulations(viper.GetString("EMULATION_PROFILES_FILE"))
+		if emulationsErr != nil {
+			logger.Error("Failed to load emulation profiles", zap.Error(emulationsErr))
+		}
+	})
+	if emulationsErr != nil {
+		return Emulation{}, errs.StatusError{Code: http.StatusInternalServerError, Err: emulationsErr}
+	}
+	e, ok := emulations[strings.ToLower(name)]
+	if !ok {
+		return Emulation{}, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unknown emulation profile %s", name)}
+	}
+	return e, nil
+}
+
+//resolve returns emulation settings with the named profile applied. Non-empty fields of e override the profile.
+func (e *Emulation) resolve() (*Emulation, error) {
+	if e == nil {
+		return nil, nil
+	}
+	r := Emulation{}
+	if e.Profile != "" {
+		p, err := getEmulation(e.Profile)
+		if err != nil {
+			return nil, err
+		}
+		r = p
+	}
+	if e.Viewport != nil {
+		r.Viewport = e.Viewport
+	}
+	if e.Touch != nil {
+		r.Touch = e.Touch
+	}
+	if e.UserAgent != "" {
+		r.UserAgent = e.UserAgent
+	}
+	if e.AcceptLanguage != "" {
+		r.AcceptLanguage = e.AcceptLanguage
+	}
+	if e.Locale != "" {
+		r.Locale = e.Locale
+	}
+	if e.Timezone != "" {
+		r.Timezone = e.Timezone
+	}
+	if e.Geolocation != nil {
+		r.Geolocation = e.Geolocation
+	}
+	if e.ColorScheme != "" {
+		r.ColorScheme = e.ColorScheme
+	}
+	if r.AcceptLanguage == "" {
+		r.AcceptLanguage = r.Locale
+	}
+	if r.Locale == "" && r.AcceptLanguage != "" {
+		r.Locale = strings.TrimSpace(strings.Split(strings.Split(r.AcceptLanguage, ",")[0], ";")[0])
+	}
+	if err := r.validate(); err != nil {
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: err}
+	}
+	return &r, nil
+}
+
+//validate checks emulation settings which are not validated by Chrome.
+func (e Emulation) validate() error {
+	if e.Viewport != nil && (e.Viewport.Width <= 0 || e.Viewport.Height <= 0) {
+		return errors.New("emulation viewport width and height must be positive")
+	}
+	if g := e.Geolocation; g != nil && (g.Latitude < -90 || g.Latitude > 90 || g.Longitude < -180 || g.Longitude > 180 || g.Accuracy < 0) {
+		return fmt.Errorf("invalid geolocation %v, %v", g.Latitude, g.Longitude)
+	}
+	if e.ColorScheme != "" && !colorSchemes[strings.ToLower(e.ColorScheme)] {
+		return fmt.Errorf("unsupported color scheme %s", e.ColorScheme)
+	}
+	return nil
+}
+
+//resolveEmulation applies the emulation profile of the request. Emulated viewport is used if the request has no Viewport.
+func (req *Request) resolveEmulation() error {
+	e, err := req.Emulation.resolve()
+	if err != nil {
+		return err
+	}
+	req.Emulation = e
+	if req.Viewport == nil && e != nil {
+		req.Viewport = e.Viewport
+	}
+	return nil
+}
+
+//acceptLanguage returns Accept-Language of the request. Accept-Language header takes precedence over emulated language.
+func (req Request) acceptLanguage() string {
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, "Accept-Language") {
+			return v
+		}
+	}
+	if req.Emulation != nil {
+		return req.Emulation.AcceptLanguage
+	}
+	return ""
+}
+
+//timezoneOverrideArgs contains arguments of Emulation.setTimezoneOverride missing in cdp package.
+type timezoneOverrideArgs struct {
+	TimezoneID string `json:"timezoneId"`
+}
+
+//localeOverrideArgs contains arguments of Emulation.setLocaleOverride missing in cdp package.
+type localeOverrideArgs struct {
+	Locale string `json:"locale,omitempty"`
+}
+
+//mediaFeature is a media feature of Emulation.setEmulatedMedia missing in cdp package.
+type mediaFeature struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type emulatedMediaArgs struct {
+	Media    string         `json:"media"`
+	Features []mediaFeature `json:"features"`
+}
+
+//emulate applies touch, locale, timezone, geolocation and color scheme emulation to the Chrome tab. Viewport, User-Agent and Accept-Language are applied along with other request settings.
+func (f *ChromeFetcher) emulate(ctx context.Context, tab *chromeTab, req Request) error {
+	e := req.Emulation
+	if e == nil {
+		return nil
+	}
+	if e.Touch != nil {
+		if err := f.cdpClient.Emulation.SetTouchEmulationEnabled(ctx, emulation.NewSetTouchEmulationEnabledArgs(*e.Touch).SetMaxTouchPoints(5)); err != nil {
+			return err
+		}
+	}
+	if e.Locale != "" {
+		if err := rpcc.Invoke(ctx, "Emulation.setLocaleOverride", &localeOverrideArgs{Locale: e.Locale}, nil, tab.conn); err != nil {
+			return err
+		}
+	}
+	if e.Timezone != "" {
+		if err := rpcc.Invoke(ctx, "Emulation.setTimezoneOverride", &timezoneOverrideArgs{TimezoneID: e.Timezone}, nil, tab.conn); err != nil {
+			return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("timezone %s: %s", e.Timezone, err)}
+		}
+	}
+	if e.ColorScheme != "" {
+		args := &emulatedMediaArgs{Features: []mediaFeature{{Name: "prefers-color-scheme", Value: strings.ToLower(e.ColorScheme)}}}
+		if err := rpcc.Invoke(ctx, "Emulation.setEmulatedMedia", args, nil, tab.conn); err != nil {
+			return err
+		}
+	}
+	if g := e.Geolocation; g != nil {
+		u, err := url.Parse(req.getURL())
+		if err != nil {
+			return err
+		}
+		b, _, err := getChromePool().browserClient(ctx)
+		if err != nil {
+			return err
+		}
+		args := browser.NewGrantPermissionsArgs(u.Scheme+"://"+u.Host, []browser.PermissionType{browser.PermissionTypeGeolocation})
+		if tab.contextID != "" {
+			args.SetBrowserContextID(tab.contextID)
+		}
+		if err = b.Browser.GrantPermissions(ctx, args); err != nil {
+			return err
+		}
+		accuracy := g.Accuracy
+		if accuracy == 0 {
+			accuracy = 100
+		}
+		return f.cdpClient.Emulation.SetGeolocationOverride(ctx,
+			emulation.NewSetGeolocationOverrideArgs().SetLatitude(g.Latitude).SetLongitude(g.Longitude).SetAccuracy(accuracy))
+	}
+	return nil
+}
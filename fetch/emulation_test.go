@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmulationResolve(t *testing.T) {
+	noTouch := false
+	e, err := (&Emulation{Profile: "iPhone", Touch: &noTouch, AcceptLanguage: "de-DE,de;q=0.9", Timezone: "Europe/Berlin"}).resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, iphone.Viewport, e.Viewport)
+	assert.Equal(t, iphone.UserAgent, e.UserAgent)
+	assert.False(t, *e.Touch)
+	assert.Equal(t, "de-DE", e.Locale)
+	assert.Equal(t, "Europe/Berlin", e.Timezone)
+	//resolved emulation stays the same
+	again, err := e.resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, e, again)
+
+	e, err = (&Emulation{Locale: "fr-FR"}).resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, "fr-FR", e.AcceptLanguage)
+
+	e, err = (*Emulation)(nil).resolve()
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+
+	for _, bad := range []Emulation{
+		{Profile: "unknown"},
+		{ColorScheme: "sepia"},
+		{Geolocation: &Geolocation{Latitude: 91}},
+		{Viewport: &Viewport{Width: 0, Height: 100}},
+	} {
+		_, err = bad.resolve()
+		assert.Error(t, err)
+	}
+
+	req := Request{Emulation: &Emulation{Profile: "desktop"}}
+	assert.NoError(t, req.resolveEmulation())
+	assert.Equal(t, desktop.Viewport, req.Viewport)
+	assert.Equal(t, desktop.UserAgent, req.userAgent())
+}
+
+func TestLoadEmulations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emulation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"Berlin": {"locale": "de-DE", "timezone": "Europe/Berlin", "geolocation": {"latitude": 52.52, "longitude": 13.405}}}`), 0600))
+	profiles, err := loadEmulations(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", profiles["berlin"].Timezone)
+	assert.Equal(t, pixel, profiles["mobile"])
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"dark": {"profile": "desktop", "colorScheme": "dark"}}`), 0600))
+	_, err = loadEmulations(path)
+	assert.Error(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"dark": {"colorScheme": "black"}}`), 0600))
+	_, err = loadEmulations(path)
+	assert.Error(t, err)
+}
+
+func TestBaseFetcher_Emulation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("User-Agent") + "|" + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+	fetch := func(req Request) string {
+		req.URL = ts.URL
+		resp, err := newBaseFetcher().Fetch(context.Background(), req)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		body, _ := ioutil.ReadAll(resp)
+		return string(body)
+	}
+	assert.Equal(t, pixel.UserAgent+"|de-DE", fetch(Request{Emulation: &Emulation{Profile: "mobile", Locale: "de-DE"}}))
+	//request headers take precedence over emulation
+	assert.Equal(t, "agent|fr", fetch(Request{
+		Headers:   map[string]string{"accept-language": "fr"},
+		UserAgent: "agent",
+		Emulation: &Emulation{Profile: "mobile", Locale: "de-DE"},
+	}))
+	_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL, Emulation: &Emulation{Profile: "unknown"}})
+	assert.Error(t, err)
+}
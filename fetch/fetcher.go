@@ -12,23 +12,22 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mafredri/cdp"
-	"github.com/mafredri/cdp/devtool"
 	"github.com/mafredri/cdp/protocol/dom"
 	"github.com/mafredri/cdp/protocol/network"
-	"github.com/mafredri/cdp/protocol/page"
 	"github.com/mafredri/cdp/protocol/runtime"
 	"github.com/mafredri/cdp/rpcc"
 	"github.com/slotix/dataflowkit/errs"
 	"github.com/spf13/viper"
-	"golang.org/x/net/publicsuffix"
+	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -41,6 +40,8 @@ const (
 	Base Type = "Base"
 	//Headless chrome is used to download content from JS driven web pages
 	Chrome = "Chrome"
+	//Replay fetcher serves documents recorded to CASSETTE_DIR without network access
+	Replay = "Replay"
 )
 
 // Fetcher is the interface that must be satisfied by things that can fetch
@@ -49,23 +50,25 @@ const (
 // Note: Fetchers may or may not be safe to use concurrently.  Please read the
 // documentation for each fetcher for more details.
 type Fetcher interface {
-	//  Fetch is called to retrieve HTML content of a document from the remote server.
-	Fetch(request Request) (io.ReadCloser, error)
+	//  Fetch is called to retrieve HTML content of a document from the remote server along with response metadata.
+	Fetch(ctx context.Context, request Request) (*Response, error)
 	getCookieJar() http.CookieJar
 	setCookieJar(jar http.CookieJar)
 	getCookies(u *url.URL) ([]*http.Cookie, error)
 	setCookies(u *url.URL, cookies []*http.Cookie) error
+	setProxy(p *proxy)
+	setTLS(s *tlsSettings)
 }
 
 //Request struct contains request information sent to  Fetchers
 type Request struct {
-	// Type defines Fetcher type. It may be "chrome" or "base". Defaults to "base".
+	// Type defines Fetcher type. It may be "chrome", "base", "replay" or the name of a fetcher added with Register. Defaults to "base". "replay" serves documents recorded to CASSETTE_DIR of fetch.d.
 	Type string `json:"type"`
 	//	URL to be retrieved
 	URL string `json:"url"`
-	//	HTTP method : GET, POST
-	Method string
-	// FormData is a string value for passing formdata parameters.
+	//	HTTP method, e.g. GET, POST, PUT, PATCH or DELETE. Defaults to POST for requests with the body and to GET otherwise.
+	Method string `json:"method,omitempty"`
+	// FormData is a string value for passing url-encoded formdata parameters. It is sent with application/x-www-form-urlencoded content type.
 	//
 	// For example it may be used for processing pages which require authentication
 	//
@@ -74,16 +77,60 @@ type Request struct {
 	// "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=user&ips_password=userpassword&rememberMe=1"
 	//
 	FormData string `json:"formData,omitempty"`
+	// Body is a raw request body sent with ContentType.
+	Body string `json:"body,omitempty"`
+	// ContentType is the content type of Body. Defaults to "text/plain; charset=utf-8".
+	ContentType string `json:"contentType,omitempty"`
+	// JSON is a request body sent with application/json content type, e.g. {"query":"laptop","page":1}
+	JSON json.RawMessage `json:"json,omitempty"`
+	// Multipart lists parts of multipart/form-data request body including files.
+	//
+	// Only one of FormData, Body, JSON and Multipart may be set.
+	Multipart []FormPart `json:"multipart,omitempty"`
 	//UserToken identifies user to keep personal cookies information.
 	UserToken string `json:"userToken"`
-	// Actions contains the list of action we have to perform on page
+	// Auth contains Basic, Bearer or custom header credentials sent with the request. Chrome fetcher sends them with requests made by the page to the origin of the request only. Parse.d shares them with paginator and details requests to the same host.
+	Auth *Auth `json:"auth,omitempty"`
+	// Actions contains JSON list of actions performed in order on the page loaded by Chrome fetcher, e.g.
+	// [{"input":{"element":"#search","value":"laptop"}},{"press":{"key":"Enter"}},{"wait":{"element":".results"}}]
+	// Requests of base type with Actions are processed by Chrome fetcher.
 	Actions string `json:"actions"`
+	// Headers contains HTTP headers to be sent along with the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// UserAgent overrides User-Agent header. If neither UserAgent nor User-Agent header is set, User-Agents from USER_AGENTS_FILE of fetch.d are rotated.
+	UserAgent string `json:"userAgent,omitempty"`
+	// TLSProfile selects named TLS profile of fetch.d with extra root CAs, client certificate, minimum TLS version or disabled certificate verification. If omitted, the default profile is used.
+	TLSProfile string `json:"tlsProfile,omitempty"`
+	// Proxy pins the request to the proxy or to the group of proxies with the given name. If omitted, any proxy from the pool is used.
+	Proxy string `json:"proxy,omitempty"`
+	// NoCache forces the request to bypass HTTP cache. The response is cached anyway. HTTP cache is enabled with HTTP_CACHE setting and applies to base fetcher GET requests.
+	NoCache bool `json:"noCache,omitempty"`
+	// Viewport sets Chrome browser window size. Requests of base type with Viewport are processed by Chrome fetcher.
+	Viewport *Viewport `json:"viewport,omitempty"`
+	// Emulation selects emulation profile of device, locale, timezone, geolocation and color scheme and overrides its settings. Base fetcher sends the matching User-Agent and Accept-Language headers only.
+	Emulation *Emulation `json:"emulation,omitempty"`
+	// Captures lists screenshots and PDFs to be taken by Chrome fetcher. Requests of base type with Captures are processed by Chrome fetcher.
+	Captures []Capture `json:"captures,omitempty"`
+	// Network lists filters of XHR and fetch responses recorded by Chrome fetcher while the page is rendered. Requests of base type with Network filters are processed by Chrome fetcher.
+	Network []NetworkFilter `json:"network,omitempty"`
+	// HAR requests HTTP Archive of Chrome network session to be returned along with the page for debugging.
+	HAR bool `json:"har,omitempty"`
+	// Wait lists conditions Chrome fetcher waits for after navigation. By default it waits for the load event followed by 750 ms delay. Requests of base type with Wait are processed by Chrome fetcher.
+	Wait *Wait `json:"wait,omitempty"`
+	// Console requests console messages and uncaught JavaScript errors of the page to be returned with the response. Errors matching fatal patterns fail the fetch. Requests of base type with Console are processed by Chrome fetcher.
+	Console *Console `json:"console,omitempty"`
+	// Timeout is the overall time limit of the fetch in seconds. It overrides FETCH_TIMEOUT setting of fetch.d.
+	Timeout int `json:"timeout,omitempty"`
 }
 
 // BaseFetcher is a Fetcher that uses the Go standard library's http
 // client to fetch URLs.
 type BaseFetcher struct {
 	client *http.Client
+	proxy  *proxy
+	tls    *tlsSettings
+	//timings collects request timings if set.
+	timings *Timings
 }
 
 // ChromeFetcher is used to fetch Java Script rendeded pages.
@@ -91,98 +138,182 @@ type ChromeFetcher struct {
 	cdpClient *cdp.Client
 	client    *http.Client
 	cookies   []*http.Cookie
+	proxy     *proxy
+	tls       *tlsSettings
+	//aborted keeps URLs of resources aborted by request interception.
+	aborted   map[string]bool
+	abortedMx sync.Mutex
 }
 
-//newFetcher creates instances of Fetcher for downloading a web page.
+//newFetcher creates instances of registered Fetcher for downloading a web page.
 func newFetcher(t Type) Fetcher {
-	switch t {
-	case Base:
-		return newBaseFetcher()
-	case Chrome:
-		return newChromeFetcher()
-	default:
+	r, ok := lookup(string(t))
+	if !ok {
 		logger.Panic(fmt.Sprintf("unhandled type: %#v", t))
 	}
-	panic("unreachable")
+	return r.factory()
+}
+
+//NewBaseFetcher creates base fetcher. Custom fetchers registered with Register embed it to inherit session cookies, proxy and TLS handling.
+func NewBaseFetcher() *BaseFetcher {
+	return newBaseFetcher()
 }
 
 // newBaseFetcher creates instances of newBaseFetcher{} to fetch
 // a page content from regular websites as-is
 // without running js scripts on the page.
 func newBaseFetcher() *BaseFetcher {
-	var client *http.Client
-	proxy := viper.GetString("PROXY")
-	if len(proxy) > 0 {
-		proxyURL, err := url.Parse(proxy)
-		if err != nil {
-			logger.Error(err.Error())
-			return nil
-		}
-		transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-		client = &http.Client{Transport: transport}
-	} else {
-		client = &http.Client{}
-	}
-	f := &BaseFetcher{
-		client: client,
-	}
-	jarOpts := &cookiejar.Options{PublicSuffixList: publicsuffix.List}
-	var err error
-	f.client.Jar, err = cookiejar.New(jarOpts)
+	f := &BaseFetcher{}
+	f.client = &http.Client{Transport: baseTransport{f}, CheckRedirect: checkRedirect}
+	jar, err := newSessionJar()
 	if err != nil {
 		return nil
 	}
+	f.client.Jar = jar
 	return f
 }
 
-// Fetch retrieves document from the remote server.
-func (bf *BaseFetcher) Fetch(request Request) (io.ReadCloser, error) {
-	resp, err := bf.response(request)
-	if err != nil {
+//baseTransport sends requests of base fetcher with the transport shared by all fetchers with the same proxy and TLS profile. Proxy and TLS profile are selected by FetchService before the request is sent.
+type baseTransport struct {
+	f *BaseFetcher
+}
+
+func (t baseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return sharedTransport(t.f.proxy, t.f.tls).RoundTrip(req)
+}
+
+//transportKey identifies transports shared by base fetchers.
+type transportKey struct {
+	proxy                       string
+	tls                         *tlsSettings
+	connect, handshake, headers time.Duration
+}
+
+var (
+	transportsMx sync.Mutex
+	transports   = map[transportKey]*http.Transport{}
+)
+
+//sharedTransport returns transport for the proxy and TLS profile. Transports are created once, so keep-alive connections are reused by subsequent fetches instead of being left open by every fetch. Nil TLS profile stands for the default one.
+func sharedTransport(p *proxy, s *tlsSettings) *http.Transport {
+	if s == nil {
+		s, _ = getTLSSettings("")
+	}
+	key := transportKey{
+		tls:       s,
+		connect:   seconds("CONNECT_TIMEOUT"),
+		handshake: seconds("TLS_TIMEOUT"),
+		headers:   seconds("HEADER_TIMEOUT"),
+	}
+	var proxyURL *url.URL
+	if p != nil && p.URL != nil {
+		proxyURL = p.URL
+		key.proxy = p.URL.String()
+	}
+	transportsMx.Lock()
+	defer transportsMx.Unlock()
+	if t, ok := transports[key]; ok {
+		return t
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyURL(proxyURL)
+	setTimeouts(t)
+	if s != nil {
+		t.TLSClientConfig = s.config.Clone()
+	}
+	transports[key] = t
+	return t
+}
+
+// Fetch retrieves document from the remote server. The request is cancelled when ctx is done or its overall timeout is exceeded.
+func (bf *BaseFetcher) Fetch(ctx context.Context, request Request) (*Response, error) {
+	bf.timings = &Timings{Start: time.Now()}
+	if err := request.resolveEmulation(); err != nil {
 		return nil, err
 	}
-	//Converting fetched content to UTF-8
-	utf8Res, _, _, err := readerToUtf8Encoding(resp.Body)
+	ctx, cancel := context.WithTimeout(ctx, request.timeout())
+	defer cancel()
+	resp, err := bf.response(ctx, request)
 	if err != nil {
+		return nil, totalTimeout(ctx, request, err)
+	}
+	defer resp.Body.Close()
+	if err = checkContentLength(resp, request.getURL()); err != nil {
 		return nil, err
 	}
-	return utf8Res, nil
+	body, truncated, err := readBody(resp.Body, request.getURL())
+	if err != nil {
+		return nil, totalTimeout(ctx, request, err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	kind := ContentKind(contentType, body)
+	content := ioutil.NopCloser(bytes.NewReader(body))
+	name := ""
+	//Converting fetched text content to UTF-8. Binary documents like PDF and images are returned as is.
+	if !IsBinary(kind) {
+		if content, name, _, err = readerToUtf8Encoding(content, contentType); err != nil {
+			return nil, err
+		}
+	}
+	bf.timings.Total = time.Since(bf.timings.Start)
+	r := newResponse(resp, content, kind, name, *bf.timings)
+	r.Truncated = truncated
+	return r, nil
 }
 
 //Response return response after document fetching using BaseFetcher
-func (bf *BaseFetcher) response(r Request) (*http.Response, error) {
+func (bf *BaseFetcher) response(ctx context.Context, r Request) (*http.Response, error) {
 	//URL validation
 	if _, err := url.ParseRequestURI(r.getURL()); err != nil {
 		return nil, err
 	}
-	var err error
-	var req *http.Request
-
-	if r.FormData == "" {
-		req, err = http.NewRequest(r.Method, r.URL, nil)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		//if form data exists send POST request
-		formData := parseFormData(r.FormData)
-		req, err = http.NewRequest("POST", r.URL, strings.NewReader(formData.Encode()))
-		if err != nil {
-			return nil, err
-		}
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-		req.Header.Add("Content-Length", strconv.Itoa(len(formData.Encode())))
+	req, err := r.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+	r.setHeaders(req)
+	if bf.timings != nil {
+		ctx = withTimingsTrace(ctx, bf.timings)
+	}
+	req = req.WithContext(ctx)
+	if r.cacheable() {
+		return bf.cachedResponse(r, req)
 	}
-	//TODO: Add UA to requests
-	//req.Header.Add("User-Agent", "Dataflow kit - https://github.com/slotix/dataflowkit")
 	return bf.doRequest(req)
 }
 
 func (bf *BaseFetcher) doRequest(req *http.Request) (*http.Response, error) {
+	resp, err := bf.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return checkStatus(resp)
+}
+
+//send sends HTTP request and returns response regardless of its status code.
+func (bf *BaseFetcher) send(req *http.Request) (*http.Response, error) {
 	resp, err := bf.client.Do(req)
 	if err != nil {
+		var tooManyRedirects errs.TooManyRedirects
+		if errors.As(err, &tooManyRedirects) {
+			return nil, tooManyRedirects
+		}
+		if timeout := phaseTimeout(req, err); timeout != nil {
+			return nil, timeout
+		}
+		//Network errors are reported as 502 Bad Gateway so that clients are able to retry them.
+		if urlErr, ok := err.(*url.Error); ok {
+			if _, ok := urlErr.Err.(net.Error); ok {
+				return nil, errs.StatusError{Code: http.StatusBadGateway, Err: err}
+			}
+		}
 		return nil, err
 	}
+	return resp, nil
+}
+
+//checkStatus returns an error for responses with status other than 200 OK.
+func checkStatus(resp *http.Response) (*http.Response, error) {
 	switch resp.StatusCode {
 	case 200:
 		return resp, nil
@@ -204,7 +335,11 @@ func (bf *BaseFetcher) setCookieJar(jar http.CookieJar) {
 	bf.client.Jar = jar
 }
 
+//getCookies returns session cookies sent to the host of u with all their attributes.
 func (bf *BaseFetcher) getCookies(u *url.URL) ([]*http.Cookie, error) {
+	if jar, ok := bf.client.Jar.(*sessionJar); ok {
+		return jar.all(u), nil
+	}
 	return bf.client.Jar.Cookies(u), nil
 }
 
@@ -213,16 +348,8 @@ func (bf *BaseFetcher) setCookies(u *url.URL, cookies []*http.Cookie) error {
 	return nil
 }
 
-// parseFormData is used for converting formdata string to url.Values type
-func parseFormData(fd string) url.Values {
-	//"auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=usr&ips_password=passw&rememberMe=0"
-	formData := url.Values{}
-	pairs := strings.Split(fd, "&")
-	for _, pair := range pairs {
-		kv := strings.Split(pair, "=")
-		formData.Add(kv[0], kv[1])
-	}
-	return formData
+func (bf *BaseFetcher) setProxy(p *proxy) {
+	bf.proxy = p
 }
 
 // Static type assertion
@@ -230,21 +357,8 @@ var _ Fetcher = &BaseFetcher{}
 
 // NewChromeFetcher returns ChromeFetcher
 func newChromeFetcher() *ChromeFetcher {
-	var client *http.Client
-	proxy := viper.GetString("PROXY")
-	if len(proxy) > 0 {
-		proxyURL, err := url.Parse(proxy)
-		if err != nil {
-			logger.Error(err.Error())
-			return nil
-		}
-		transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-		client = &http.Client{Transport: transport}
-	} else {
-		client = &http.Client{}
-	}
 	f := &ChromeFetcher{
-		client: client,
+		client: &http.Client{},
 	}
 	return f
 }
@@ -280,242 +394,215 @@ func (c *LogCodec) ReadResponse(resp *rpcc.Response) error {
 	return nil
 }
 
-// Fetch retrieves document from the remote server. It returns web page content along with cache and expiration information.
-func (f *ChromeFetcher) Fetch(request Request) (io.ReadCloser, error) {
+// Fetch retrieves document from the remote server. It returns web page content along with cache and expiration information. All CDP calls are cancelled and the Chrome tab is closed when ctx is done or the overall timeout of the request is exceeded.
+func (f *ChromeFetcher) Fetch(ctx context.Context, request Request) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, request.timeout())
+	defer cancel()
+	resp, err := f.fetch(ctx, request)
+	return resp, totalTimeout(ctx, request, err)
+}
+
+func (f *ChromeFetcher) fetch(ctx context.Context, request Request) (*Response, error) {
+	start := time.Now()
 	//URL validation
 	if _, err := url.ParseRequestURI(strings.TrimSpace(request.getURL())); err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	devt := devtool.New(viper.GetString("CHROME"), devtool.WithClient(f.client))
-	//https://github.com/mafredri/cdp/issues/60
-	//pt, err := devt.Get(ctx, devtool.Page)
-	pt, err := devt.Create(ctx)
+	wait, err := request.getWait()
 	if err != nil {
 		return nil, err
 	}
-	var conn *rpcc.Conn
-	if viper.GetBool("CHROME_TRACE") {
-		newLogCodec := func(conn io.ReadWriter) rpcc.Codec {
-			return &LogCodec{conn: conn}
-		}
-		// Connect to WebSocket URL (page) that speaks the Chrome Debugging Protocol.
-		conn, err = rpcc.DialContext(ctx, pt.WebSocketDebuggerURL, rpcc.WithCodec(newLogCodec))
-	} else {
-		conn, err = rpcc.DialContext(ctx, pt.WebSocketDebuggerURL)
+	if err = request.checkCaptures(); err != nil {
+		return nil, err
 	}
+	if err = request.resolveEmulation(); err != nil {
+		return nil, err
+	}
+
+	pool := getChromePool()
+	tab, err := pool.acquire(ctx, f.proxy)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-	defer conn.Close() // Cleanup.
-	defer devt.Close(ctx, pt)
-	// Create a new CDP Client that uses conn.
-	f.cdpClient = cdp.NewClient(conn)
-
-	if err = runBatch(
-		// Enable all the domain events that we're interested in.
-		func() error { return f.cdpClient.DOM.Enable(ctx) },
-		func() error { return f.cdpClient.Network.Enable(ctx, nil) },
-		func() error { return f.cdpClient.Page.Enable(ctx) },
-		func() error { return f.cdpClient.Runtime.Enable(ctx) },
-	); err != nil {
+	defer pool.release(tab)
+	f.cdpClient = tab.client
+	if err = f.applyTLS(ctx); err != nil {
 		return nil, err
 	}
 
-	err = f.loadCookies()
+	err = f.loadCookies(ctx, request.getURL())
 	if err != nil {
 		return nil, err
 	}
-	domLoadTimeout := 60 * time.Second
-	if request.FormData == "" {
-		err = f.navigate(ctx, f.cdpClient.Page, "GET", request.getURL(), "", domLoadTimeout)
-	} else {
-		formData := parseFormData(request.FormData)
-		err = f.navigate(ctx, f.cdpClient.Page, "POST", request.getURL(), formData.Encode(), domLoadTimeout)
+	if err = f.setHeaders(ctx, request); err != nil {
+		return nil, err
+	}
+	if err = f.emulate(ctx, tab, request); err != nil {
+		return nil, err
+	}
+	if request.Viewport != nil {
+		if err = f.setViewport(ctx, request.Viewport); err != nil {
+			return nil, err
+		}
 	}
+	reqBody, err := request.body()
 	if err != nil {
 		return nil, err
 	}
-
-	if err := f.runActions(ctx, request.Actions); err != nil {
-		logger.Warn(err.Error())
+	if err = f.setRequestInterception(ctx, request.getURL(), reqBody, request.Auth); err != nil {
+		return nil, err
 	}
-
-	u, err := url.Parse(request.getURL())
+	requestWillBeSent, err := f.cdpClient.Network.RequestWillBeSent(ctx)
 	if err != nil {
 		return nil, err
 	}
-	f.cookies, err = f.saveCookies(u, &ctx)
+	defer requestWillBeSent.Close()
+	responseReceived, err := f.cdpClient.Network.ResponseReceived(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	// Fetch the document root node. We can pass nil here
-	// since this method only takes optional arguments.
-	doc, err := f.cdpClient.DOM.GetDocument(ctx, nil)
+	defer responseReceived.Close()
+	recorder, err := f.newNetworkRecorder(ctx, request.Network)
 	if err != nil {
 		return nil, err
 	}
-
-	// Get the outer HTML for the page.
-	result, err := f.cdpClient.DOM.GetOuterHTML(ctx, &dom.GetOuterHTMLArgs{
-		NodeID: &doc.Root.NodeID,
-	})
+	defer recorder.Close()
+	console, err := f.newConsoleRecorder(ctx, request.Console)
+	if err != nil {
+		return nil, err
+	}
+	defer console.Close()
+	var harRecorder *harRecorder
+	if request.HAR {
+		if harRecorder, err = f.newHARRecorder(ctx); err != nil {
+			return nil, err
+		}
+		defer harRecorder.Close()
+	}
+	err = f.navigate(ctx, request.getURL(), wait)
 	if err != nil {
 		return nil, err
 	}
-	readCloser := ioutil.NopCloser(strings.NewReader(result.OuterHTML))
-	return readCloser, nil
-
-}
 
-func (f *ChromeFetcher) runActions(ctx context.Context, actionsJSON string) error {
-	if len(actionsJSON) == 0 {
-		return nil
+	actions, err := f.runActions(ctx, request.Actions)
+	if err != nil {
+		return nil, err
 	}
-	acts := []map[string]json.RawMessage{}
-	err := json.Unmarshal([]byte(actionsJSON), &acts)
+	recorded, err := f.recordedResponses(ctx, recorder)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, actionMap := range acts {
-		for actionType, params := range actionMap {
-			action, err := NewAction(actionType, params)
-			if err == nil {
-				return action.Execute(ctx, f)
-			}
-		}
+	messages, err := console.messages(request.getURL())
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
-
-func (f *ChromeFetcher) setCookieJar(jar http.CookieJar) {
-	f.client.Jar = jar
-}
 
-func (f *ChromeFetcher) getCookieJar() http.CookieJar {
-	return f.client.Jar
-}
-
-// Static type assertion
-var _ Fetcher = &ChromeFetcher{}
-
-// navigate to the URL and wait for DOMContentEventFired. An error is
-// returned if timeout happens before DOMContentEventFired.
-func (f *ChromeFetcher) navigate(ctx context.Context, pageClient cdp.Page, method, url string, formData string, timeout time.Duration) error {
-	defer time.Sleep(750 * time.Millisecond)
-
-	ctxTimeout, cancelTimeout := context.WithTimeout(context.Background(), timeout)
-
-	// Make sure Page events are enabled.
-	err := pageClient.Enable(ctxTimeout)
+	u, err := url.Parse(request.getURL())
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Navigate to GitHub, block until ready.
-	loadEventFired, err := pageClient.LoadEventFired(ctxTimeout)
+	f.cookies, err = f.saveCookies(ctx, u)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer loadEventFired.Close()
 
-	loadingFailed, err := f.cdpClient.Network.LoadingFailed(ctxTimeout)
+	resp, err := f.documentResponse(ctx, requestWillBeSent, responseReceived)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer loadingFailed.Close()
-
-	// exceptionThrown, err := f.cdpClient.Runtime.ExceptionThrown(ctxTimeout)
-	// if err != nil {
-	// 	return err
-	// }
-	//defer exceptionThrown.Close()
-
-	if method == "GET" {
-		_, err = pageClient.Navigate(ctxTimeout, page.NewNavigateArgs(url))
-		if err != nil {
-			return err
-		}
-	} else {
-		/* ast := "*" */
-		pattern := network.RequestPattern{URLPattern: &url}
-		patterns := []network.RequestPattern{pattern}
-
-		f.cdpClient.Network.SetCacheDisabled(ctxTimeout, network.NewSetCacheDisabledArgs(true))
-
-		interArgs := network.NewSetRequestInterceptionArgs(patterns)
-		err = f.cdpClient.Network.SetRequestInterception(ctxTimeout, interArgs)
-		if err != nil {
-			return err
+	if max := maxRedirects(); len(resp.Redirects) > max {
+		return nil, errs.TooManyRedirects{URL: request.getURL(), Limit: max}
+	}
+	var body []byte
+	if resp.Kind != KindHTML && resp.requestID != "" {
+		if body, err = f.documentBody(ctx, resp); err != nil {
+			if _, ok := err.(errs.TooLarge); ok {
+				return nil, err
+			}
+			logger.Warn("Failed to get document body", zap.String("url", resp.URL), zap.Error(err))
+			resp.Kind = KindHTML
 		}
-
-		kill := make(chan bool)
-		go f.interceptRequest(ctxTimeout, url, formData, kill)
-		_, err = pageClient.Navigate(ctxTimeout, page.NewNavigateArgs(url))
-		if err != nil {
-			return err
+	}
+	if body == nil {
+		if err = f.checkDOMSize(ctx, resp.URL); err != nil {
+			return nil, err
 		}
-		kill <- true
-	}
-	select {
-	// case <-exceptionThrown.Ready():
-	// 	ev, err := exceptionThrown.Recv()
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// 	return errs.StatusError{400, errors.New(ev.ExceptionDetails.Error())}
-	case <-loadEventFired.Ready():
-		_, err = loadEventFired.Recv()
+		// Fetch the document root node. We can pass nil here
+		// since this method only takes optional arguments.
+		doc, err := f.cdpClient.DOM.GetDocument(ctx, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
-	case <-loadingFailed.Ready():
-		reply, err := loadingFailed.Recv()
+
+		// Get the outer HTML for the page.
+		result, err := f.cdpClient.DOM.GetOuterHTML(ctx, &dom.GetOuterHTMLArgs{
+			NodeID: &doc.Root.NodeID,
+		})
 		if err != nil {
-			return err
-		}
-		canceled := reply.Canceled != nil && *reply.Canceled
-		if !canceled && reply.Type == network.ResourceTypeDocument {
-			return errs.StatusError{400, errors.New(reply.ErrorText)}
+			return nil, err
 		}
-	case <-ctx.Done():
-		cancelTimeout()
-		return nil /*
-			case <-ctxTimeout.Done():
-				return errs.StatusError{400, errors.New("Fetch timeout")} */
+		body = []byte(result.OuterHTML)
 	}
-	return nil
+	resp.Actions = actions
+	resp.Network = recorded
+	resp.Console = messages
+	if resp.HAR, err = f.har(harRecorder, request.getURL()); err != nil {
+		return nil, err
+	}
+	if resp.Captures, err = f.capture(ctx, request); err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.Timings.Start = start
+	resp.Timings.Total = time.Since(start)
+	return resp, nil
+
 }
 
+func (f *ChromeFetcher) setCookieJar(jar http.CookieJar) {
+	f.client.Jar = jar
+}
+
+func (f *ChromeFetcher) getCookieJar() http.CookieJar {
+	return f.client.Jar
+}
+
+// Static type assertion
+var _ Fetcher = &ChromeFetcher{}
+
 func (f *ChromeFetcher) setCookies(u *url.URL, cookies []*http.Cookie) error {
 	f.cookies = cookies
 	return nil
 }
 
-func (f *ChromeFetcher) loadCookies() error {
-	/* 	u, err := url.Parse(cookiesURL)
-	   	if err != nil {
-	   		return err
-	   	} */
+//loadCookies sets session cookies in the browser context of the tab. Host only cookies are set for the request URL.
+func (f *ChromeFetcher) loadCookies(ctx context.Context, requestURL string) error {
 	for _, c := range f.cookies {
 		c1 := network.SetCookieArgs{
-			Name:  c.Name,
-			Value: c.Value,
-			Path:  &c.Path,
-			/* Expires:  expire, */
-			Domain:   &c.Domain,
+			Name:     c.Name,
+			Value:    c.Value,
 			HTTPOnly: &c.HttpOnly,
 			Secure:   &c.Secure,
 		}
+		if c.Path != "" {
+			c1.Path = &c.Path
+		}
+		if c.Domain != "" {
+			c1.Domain = &c.Domain
+		} else {
+			c1.URL = &requestURL
+		}
+		switch c.SameSite {
+		case http.SameSiteStrictMode:
+			c1.SameSite = network.CookieSameSiteStrict
+		case http.SameSiteLaxMode:
+			c1.SameSite = network.CookieSameSiteLax
+		}
 		if !c.Expires.IsZero() {
 			duration := c.Expires.Sub(time.Unix(0, 0))
 			c1.Expires = network.TimeSinceEpoch(duration / time.Second)
 		}
-		_, err := f.cdpClient.Network.SetCookie(context.Background(), &c1)
+		_, err := f.cdpClient.Network.SetCookie(ctx, &c1)
 		if err != nil {
 			return err
 		}
@@ -527,95 +614,161 @@ func (f *ChromeFetcher) getCookies(u *url.URL) ([]*http.Cookie, error) {
 	return f.cookies, nil
 }
 
-func (f *ChromeFetcher) saveCookies(u *url.URL, ctx *context.Context) ([]*http.Cookie, error) {
-	ncookies, err := f.cdpClient.Network.GetCookies(*ctx, &network.GetCookiesArgs{URLs: []string{u.String()}})
+func (f *ChromeFetcher) setProxy(p *proxy) {
+	f.proxy = p
+}
+
+//saveCookies returns cookies of the browser context sent to the host of u. Chrome cookies of the host without leading dot in domain are host only ones. They are returned with empty Domain as Base fetcher does.
+func (f *ChromeFetcher) saveCookies(ctx context.Context, u *url.URL) ([]*http.Cookie, error) {
+	ncookies, err := f.cdpClient.Network.GetAllCookies(ctx)
 	if err != nil {
 		return nil, err
 	}
+	host := strings.ToLower(u.Hostname())
 	cookies := []*http.Cookie{}
 	for _, c := range ncookies.Cookies {
-
+		domain := strings.ToLower(c.Domain)
+		switch {
+		case !strings.HasPrefix(domain, "."):
+			if domain != host {
+				continue
+			}
+			domain = ""
+		case !domainMatch(host, domain[1:]):
+			continue
+		default:
+			domain = domain[1:]
+		}
 		c1 := http.Cookie{
-			Name:  c.Name,
-			Value: c.Value,
-			Path:  c.Path,
-			/* Expires:  expire, */
-			Domain:   c.Domain,
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   domain,
 			HttpOnly: c.HTTPOnly,
 			Secure:   c.Secure,
 		}
-		if c.Expires > -1 {
+		switch c.SameSite {
+		case network.CookieSameSiteStrict:
+			c1.SameSite = http.SameSiteStrictMode
+		case network.CookieSameSiteLax:
+			c1.SameSite = http.SameSiteLaxMode
+		}
+		if !c.Session && c.Expires > -1 {
 			sec, dec := math.Modf(c.Expires)
-			expire := time.Unix(int64(sec), int64(dec*(1e9)))
-			/* logger.Info(expire.String())
-			logger.Info(expire.Format("2006-01-02 15:04:05")) */
-			c1.Expires = expire
+			c1.Expires = time.Unix(int64(sec), int64(dec*(1e9)))
 		}
 		cookies = append(cookies, &c1)
-		domain := string(c1.Domain)
-		Url := u.String()
-		f.cdpClient.Network.DeleteCookies(*ctx, &network.DeleteCookiesArgs{Name: c.Name, Domain: &domain, URL: &Url, Path: &c1.Path})
 	}
 	return cookies, nil
 }
 
-func (f *ChromeFetcher) interceptRequest(ctx context.Context, originURL string, formData string, kill chan bool) {
-	var sig = false
+//setRequestInterception intercepts requests made by the page to send the request with its method and body, to add Auth header to requests to the origin of the request and to answer proxy authentication challenges. Interception lasts until ctx is done.
+func (f *ChromeFetcher) setRequestInterception(ctx context.Context, originURL string, body *requestBody, auth *Auth) error {
+	_, _, proxyAuth := f.proxy.credentials()
+	if body.method == http.MethodGet && len(body.data) == 0 {
+		body = nil
+	}
+	authName, authValue, err := auth.header()
+	if err != nil {
+		return err
+	}
+	if body == nil && !proxyAuth && authName == "" {
+		return nil
+	}
+	urlPattern := originURL
+	if proxyAuth || authName != "" {
+		urlPattern = "*"
+	}
+	patterns := []network.RequestPattern{network.RequestPattern{URLPattern: &urlPattern}}
+	f.cdpClient.Network.SetCacheDisabled(ctx, network.NewSetCacheDisabledArgs(true))
 	cl, err := f.cdpClient.Network.RequestIntercepted(ctx)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if err = f.cdpClient.Network.SetRequestInterception(ctx, network.NewSetRequestInterceptionArgs(patterns)); err != nil {
+		cl.Close()
+		return err
+	}
+	var authHeader map[string]string
+	if authName != "" {
+		authHeader = map[string]string{authName: authValue}
 	}
+	go f.interceptRequest(ctx, cl, originURL, body, authHeader)
+	return nil
+}
+
+//interceptRequest overrides method, body and content type of the request to originURL. Chrome sends the body as text, so binary file parts of multipart body are reliably sent by Base fetcher only. Auth header is added to requests to the origin of originURL only, so credentials are not sent to third party scripts, XHR and frames.
+func (f *ChromeFetcher) interceptRequest(ctx context.Context, cl network.RequestInterceptedClient, originURL string, body *requestBody, authHeader map[string]string) {
 	defer cl.Close()
 	for {
-		if sig {
-			return
-		}
 		select {
 		case <-cl.Ready():
 			r, err := cl.Recv()
 			if err != nil {
 				logger.Error(err.Error())
-				sig = true
-				continue
+				return
 			}
-
-			lengthFormData := len(formData)
-			if lengthFormData > 0 && r.Request.URL == originURL && r.RedirectURL == nil {
-
-				interceptedArgs := network.NewContinueInterceptedRequestArgs(r.InterceptionID).
-					SetMethod("POST").
-					SetPostData(formData)
-
-				headers, _ := json.Marshal(map[string]string{
-					"Content-Type":   "application/x-www-form-urlencoded",
-					"Content-Length": strconv.Itoa(lengthFormData),
-				})
-				interceptedArgs.Headers = headers
-
-				if err = f.cdpClient.Network.ContinueInterceptedRequest(ctx, interceptedArgs); err != nil {
-					logger.Error(err.Error())
-					sig = true
-					continue
+			var interceptedArgs *network.ContinueInterceptedRequestArgs
+			switch {
+			case r.AuthChallenge != nil:
+				interceptedArgs = network.NewContinueInterceptedRequestArgs(r.InterceptionID).
+					SetAuthChallengeResponse(f.authChallengeResponse(r.AuthChallenge))
+			case body != nil && r.Request.URL == originURL && r.RedirectURL == nil:
+				interceptedArgs = network.NewContinueInterceptedRequestArgs(r.InterceptionID).
+					SetMethod(body.method)
+				set := map[string]string{}
+				for k, v := range authHeader {
+					set[k] = v
 				}
-			} else {
-				interceptedArgs := network.NewContinueInterceptedRequestArgs(r.InterceptionID)
+				if len(body.data) > 0 {
+					interceptedArgs.SetPostData(string(body.data))
+					set["Content-Type"] = body.contentType
+					set["Content-Length"] = strconv.Itoa(len(body.data))
+				}
+				if len(set) > 0 {
+					interceptedArgs.Headers = withHeaders(r.Request.Headers, set)
+				}
+				//the body is sent only once
+				body = nil
+			default:
+				interceptedArgs = network.NewContinueInterceptedRequestArgs(r.InterceptionID)
 				if r.ResourceType == network.ResourceTypeImage || r.ResourceType == network.ResourceTypeStylesheet || isExclude(r.Request.URL) {
 					interceptedArgs.SetErrorReason(network.ErrorReasonAborted)
+					f.setAborted(r.Request.URL)
+				} else if len(authHeader) > 0 && sameOrigin(r.Request.URL, originURL) {
+					interceptedArgs.Headers = withHeaders(r.Request.Headers, authHeader)
 				}
-				if err = f.cdpClient.Network.ContinueInterceptedRequest(ctx, interceptedArgs); err != nil {
-					logger.Error(err.Error())
-					sig = true
-					continue
-				}
-				continue
 			}
-		case <-kill:
-			sig = true
-			break
+			if err = f.cdpClient.Network.ContinueInterceptedRequest(ctx, interceptedArgs); err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
+func (f *ChromeFetcher) setAborted(url string) {
+	f.abortedMx.Lock()
+	defer f.abortedMx.Unlock()
+	if f.aborted == nil {
+		f.aborted = map[string]bool{}
+	}
+	f.aborted[url] = true
+}
+
+//abortedURLs returns a copy of aborted resources URLs.
+func (f *ChromeFetcher) abortedURLs() map[string]bool {
+	f.abortedMx.Lock()
+	defer f.abortedMx.Unlock()
+	aborted := map[string]bool{}
+	for url := range f.aborted {
+		aborted[url] = true
+	}
+	return aborted
+}
+
 func isExclude(origin string) bool {
 	excludeRes := viper.GetStringSlice("EXCLUDERES")
 	for _, res := range excludeRes {
@@ -626,10 +779,10 @@ func isExclude(origin string) bool {
 	return false
 }
 
-func (f ChromeFetcher) RunJSFromFile(ctx context.Context, path string, entryPointFunction string) error {
+func (f *ChromeFetcher) RunJSFromFile(ctx context.Context, path string, entryPointFunction string) error {
 	exp, err := ioutil.ReadFile(path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	exp = append(exp, entryPointFunction...)
@@ -639,15 +792,24 @@ func (f ChromeFetcher) RunJSFromFile(ctx context.Context, path string, entryPoin
 		PersistScript: true,
 	})
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if compileReply.ExceptionDetails != nil {
+		return compileReply.ExceptionDetails
 	}
 	awaitPromise := true
 
-	_, err = f.cdpClient.Runtime.RunScript(ctx, &runtime.RunScriptArgs{
+	runReply, err := f.cdpClient.Runtime.RunScript(ctx, &runtime.RunScriptArgs{
 		ScriptID:     *compileReply.ScriptID,
 		AwaitPromise: &awaitPromise,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if runReply.ExceptionDetails != nil {
+		return runReply.ExceptionDetails
+	}
+	return nil
 }
 
 // removeNodes deletes all provided nodeIDs from the DOM.
@@ -2,10 +2,16 @@ package fetch
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,7 +37,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 		URL:    tsURL + "/hello",
 		Method: "GET",
 	}
-	html, err := fetcher.Fetch(req)
+	html, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err, "Expected no error")
 	data, err := ioutil.ReadAll(html)
 	assert.NoError(t, err, "Expected no error")
@@ -41,7 +47,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 	req = Request{
 		URL: tsURL,
 	}
-	content, err := fetcher.Fetch(req)
+	content, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, content, "Expected content not nil")
 
@@ -52,7 +58,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 		FormData: "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=user&ips_password=userpassword&rememberMe=1",
 	}
 
-	content, err = fetcher.Fetch(req)
+	content, err = fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, content, "Expected content not nil")
 
@@ -71,7 +77,7 @@ func TestBaseFetcher_Fetch(t *testing.T) {
 	assert.Error(t, err)
 
 	//fetch robots.txt data
-	robots, _ := fetcher.Fetch(Request{
+	robots, _ := fetcher.Fetch(context.Background(), Request{
 		URL:    tsURL + "/robots.txt",
 		Method: "GET",
 	})
@@ -88,7 +94,7 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		Type: "chrome",
 		URL:  "http://testserver:12345",
 	}
-	resp, err := fetcher.Fetch(req)
+	resp, err := fetcher.Fetch(context.Background(), req)
 	assert.Nil(t, err, "Expected no error")
 	assert.NotNil(t, resp, "Expected resp not nil")
 
@@ -100,7 +106,7 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		FormData: "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=user&ips_password=userpassword&rememberMe=1",
 	}
 
-	resp, err = fetcher.Fetch(req)
+	resp, err = fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 	assert.NotNil(t, resp, "Expected content not nil")
 
@@ -126,19 +132,26 @@ func TestChromeFetcher_Fetch(t *testing.T) {
 		URL:  "http://testserver:12345/status/200",
 		//InfiniteScroll: true,
 	}
-	resp, err = fetcher.Fetch(req)
+	resp, err = fetcher.Fetch(context.Background(), req)
 	assert.Nil(t, err, "Expected no error")
 	assert.NotNil(t, resp, "Expected resp not nil")
 }
 func Test_parseFormData(t *testing.T) {
 	formData := "auth_key=880ea6a14ea49e853634fbdc5015a024&referer=http%3A%2F%2Fexample.com%2F&ips_username=usr&ips_password=passw&rememberMe=0"
-	values := parseFormData(formData)
+	values, err := parseFormData(formData)
+	assert.NoError(t, err)
 	assert.Equal(t,
 		url.Values{"auth_key": []string{"880ea6a14ea49e853634fbdc5015a024"},
-			"referer": []string{"http%3A%2F%2Fexample.com%2F"}, "ips_username": []string{"usr"},
+			"referer": []string{"http://example.com/"}, "ips_username": []string{"usr"},
 			"ips_password": []string{"passw"},
 			"rememberMe":   []string{"0"}},
 		values)
+	//pairs without value do not panic
+	values, err = parseFormData("flag&q=a+b")
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"flag": []string{""}, "q": []string{"a b"}}, values)
+	_, err = parseFormData("q=%zz")
+	assert.Error(t, err)
 }
 
 func TestInvalidFetcher(t *testing.T) {
@@ -167,7 +180,7 @@ func TestAuthFetcher(t *testing.T) {
 		FormData: "username=" + username + "&password=123",
 	}
 
-	content, err := fetcher.Fetch(req)
+	content, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err)
 
 	pageContent, err := ioutil.ReadAll(content)
@@ -186,7 +199,7 @@ func Test_BaseFetcher_Utf8Encoding(t *testing.T) {
 		//URL: "https://www.tvojlekar.sk/lekari.php",
 		Method: "GET",
 	}
-	html, err := fetcher.Fetch(req)
+	html, err := fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err, "Expected no error")
 	data, err := ioutil.ReadAll(html)
 	t.Log(string(data))
@@ -195,8 +208,135 @@ func Test_BaseFetcher_Utf8Encoding(t *testing.T) {
 		URL:    tsURL + "/static/html/win1250.html",
 		Method: "GET",
 	}
-	html, err = fetcher.Fetch(req)
+	html, err = fetcher.Fetch(context.Background(), req)
 	assert.NoError(t, err, "Expected no error")
 	data, err = ioutil.ReadAll(html)
 	t.Log(string(data))
 }
+
+func TestBaseFetcher_Headers(t *testing.T) {
+	viper.Set("PROXY", "")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("User-Agent") + "|" + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+	fetcher := newFetcher(Base)
+	html, err := fetcher.Fetch(context.Background(), Request{
+		URL:     ts.URL,
+		Headers: map[string]string{"Accept-Language": "de-DE", "User-Agent": "HeaderAgent"},
+	})
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(html)
+	assert.NoError(t, err)
+	assert.Equal(t, "HeaderAgent|de-DE", string(data))
+
+	//UserAgent takes precedence over User-Agent header
+	html, err = fetcher.Fetch(context.Background(), Request{
+		URL:       ts.URL,
+		Headers:   map[string]string{"User-Agent": "HeaderAgent"},
+		UserAgent: "FieldAgent",
+	})
+	assert.NoError(t, err)
+	data, err = ioutil.ReadAll(html)
+	assert.NoError(t, err)
+	assert.Equal(t, "FieldAgent|", string(data))
+}
+
+func TestUserAgentsRotation(t *testing.T) {
+	f, err := ioutil.TempFile("", "user-agents")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("# comment\nAgent1\n\nAgent2\n")
+	f.Close()
+	list, err := loadUserAgents(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Agent1", "Agent2"}, list)
+	_, err = loadUserAgents("nonexistent_file")
+	assert.Error(t, err)
+}
+
+func TestBaseFetcher_Response(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Header().Set("X-Custom", "value")
+		w.Write([]byte("<html>\xcf\xf0\xe8\xe2\xe5\xf2</html>"))
+	}))
+	defer ts.Close()
+	resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/start"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ts.URL+"/final", resp.URL)
+	assert.Equal(t, []string{ts.URL + "/start"}, resp.Redirects)
+	assert.Equal(t, "text/html; charset=windows-1251", resp.ContentType)
+	assert.Equal(t, "windows-1251", resp.Charset)
+	assert.Equal(t, "value", resp.Header.Get("X-Custom"))
+	assert.True(t, resp.Timings.Total > 0)
+	assert.Equal(t, KindHTML, resp.Kind)
+	body, err := ioutil.ReadAll(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "<html>Привет</html>", string(body))
+}
+
+func TestBaseFetcher_Binary(t *testing.T) {
+	pdf := "%PDF-1.4\n\xcf\xf0\xe8\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte(pdf))
+	}))
+	defer ts.Close()
+	resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL})
+	assert.NoError(t, err)
+	assert.Equal(t, KindPDF, resp.Kind)
+	assert.Equal(t, "", resp.Charset)
+	body, err := ioutil.ReadAll(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, pdf, string(body))
+}
+
+func TestBaseFetcher_Canceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := newBaseFetcher().Fetch(ctx, Request{URL: ts.URL})
+	assert.Error(t, err)
+}
+
+func TestBaseFetcher_SharedTransport(t *testing.T) {
+	var mx sync.Mutex
+	conns := 0
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mx.Lock()
+			conns++
+			mx.Unlock()
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+	//keep-alive connection of the first fetch is reused by the next ones
+	for i := 0; i < 3; i++ {
+		_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL})
+		assert.NoError(t, err)
+	}
+	mx.Lock()
+	assert.Equal(t, 1, conns)
+	mx.Unlock()
+
+	p := &proxy{URL: &url.URL{Scheme: "http", Host: "127.0.0.1:3128"}}
+	assert.True(t, sharedTransport(nil, nil) == sharedTransport(nil, nil))
+	assert.True(t, sharedTransport(p, nil) == sharedTransport(&proxy{URL: &url.URL{Scheme: "http", Host: "127.0.0.1:3128"}}, nil))
+	assert.False(t, sharedTransport(p, nil) == sharedTransport(nil, nil))
+	proxyURL, err := sharedTransport(p, nil).Proxy(httptest.NewRequest("GET", ts.URL, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, p.URL, proxyURL)
+}
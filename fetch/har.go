@@ -0,0 +1,479 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/rpcc"
+)
+
+//HAR is HTTP Archive 1.2 document describing Chrome network session of the fetch. See http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+//HARLog is the root of HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Pages   []HARPage  `json:"pages"`
+	Entries []HAREntry `json:"entries"`
+}
+
+//HARCreator describes the application created HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+//HARPage describes the fetched page.
+type HARPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     HARPageTimings `json:"pageTimings"`
+}
+
+//HARPageTimings contains milliseconds elapsed from the page start until DOMContentLoaded and load events. -1 means the event was not fired.
+type HARPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+//HAREntry describes a request made by the page and its response.
+type HAREntry struct {
+	Pageref         string      `json:"pageref"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+//HARRequest describes a request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+//HARResponse describes a response. Status is 0 for requests failed or aborted before the response was received.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	//Error is the network error of failed request.
+	Error string `json:"_error,omitempty"`
+}
+
+//HARNameValue is a header, a cookie or a query string parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+//HARPostData describes posted data.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+//HARContent describes response content.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+//HARTimings contains durations of request phases in milliseconds. -1 means the phase does not apply to the request.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+const harPageID = "page_1"
+
+//harRecorder listens for Network and Page events of the fetch.
+type harRecorder struct {
+	requestWillBeSent    network.RequestWillBeSentClient
+	responseReceived     network.ResponseReceivedClient
+	loadingFinished      network.LoadingFinishedClient
+	loadingFailed        network.LoadingFailedClient
+	domContentEventFired page.DOMContentEventFiredClient
+	loadEventFired       page.LoadEventFiredClient
+}
+
+//newHARRecorder starts listening for events making up HAR document.
+func (f *ChromeFetcher) newHARRecorder(ctx context.Context) (r *harRecorder, err error) {
+	r = &harRecorder{}
+	defer func() {
+		if err != nil {
+			r.Close()
+		}
+	}()
+	if r.requestWillBeSent, err = f.cdpClient.Network.RequestWillBeSent(ctx); err != nil {
+		return nil, err
+	}
+	if r.responseReceived, err = f.cdpClient.Network.ResponseReceived(ctx); err != nil {
+		return nil, err
+	}
+	if r.loadingFinished, err = f.cdpClient.Network.LoadingFinished(ctx); err != nil {
+		return nil, err
+	}
+	if r.loadingFailed, err = f.cdpClient.Network.LoadingFailed(ctx); err != nil {
+		return nil, err
+	}
+	if r.domContentEventFired, err = f.cdpClient.Page.DOMContentEventFired(ctx); err != nil {
+		return nil, err
+	}
+	if r.loadEventFired, err = f.cdpClient.Page.LoadEventFired(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *harRecorder) Close() {
+	if r == nil {
+		return
+	}
+	for _, s := range []rpcc.Stream{r.requestWillBeSent, r.responseReceived, r.loadingFinished, r.loadingFailed, r.domContentEventFired, r.loadEventFired} {
+		if s != nil {
+			s.Close()
+		}
+	}
+}
+
+//ready returns true if stream has an event to receive.
+func ready(s rpcc.Stream) bool {
+	select {
+	case <-s.Ready():
+		return true
+	default:
+		return false
+	}
+}
+
+//har builds HAR document from events received so far. Streams are processed one after another as every kind of event only refers to requests announced by requestWillBeSent.
+func (f *ChromeFetcher) har(r *harRecorder, pageURL string) (*HAR, error) {
+	if r == nil {
+		return nil, nil
+	}
+	b := newHARBuilder(f.abortedURLs())
+	for ready(r.requestWillBeSent) {
+		ev, err := r.requestWillBeSent.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.requestWillBeSent(ev)
+	}
+	for ready(r.responseReceived) {
+		ev, err := r.responseReceived.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.responseReceived(ev)
+	}
+	for ready(r.loadingFinished) {
+		ev, err := r.loadingFinished.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.loadingFinished(ev)
+	}
+	for ready(r.loadingFailed) {
+		ev, err := r.loadingFailed.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.loadingFailed(ev)
+	}
+	if ready(r.domContentEventFired) {
+		ev, err := r.domContentEventFired.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.onContentLoad = ev.Timestamp
+	}
+	if ready(r.loadEventFired) {
+		ev, err := r.loadEventFired.Recv()
+		if err != nil {
+			return nil, err
+		}
+		b.onLoad = ev.Timestamp
+	}
+	return b.build(pageURL), nil
+}
+
+//harBuilder assembles HAR entries from CDP Network events.
+type harBuilder struct {
+	entries []*harEntry
+	//current keeps the last entry of the request. Redirects create new entries for the same request ID.
+	current       map[network.RequestID]*harEntry
+	aborted       map[string]bool
+	pageStart     network.MonotonicTime
+	pageWallTime  time.Time
+	onContentLoad network.MonotonicTime
+	onLoad        network.MonotonicTime
+}
+
+//harEntry keeps HAR entry along with raw timing data.
+type harEntry struct {
+	HAREntry
+	start    network.MonotonicTime
+	end      network.MonotonicTime
+	finished bool
+	timing   *network.ResourceTiming
+}
+
+func newHARBuilder(aborted map[string]bool) *harBuilder {
+	return &harBuilder{current: map[network.RequestID]*harEntry{}, aborted: aborted}
+}
+
+func (b *harBuilder) requestWillBeSent(ev *network.RequestWillBeSentReply) {
+	if prev, ok := b.current[ev.RequestID]; ok && ev.RedirectResponse != nil {
+		b.setResponse(prev, ev.RedirectResponse)
+		prev.Response.RedirectURL = ev.Request.URL
+		b.finish(ev.RequestID, prev, ev.Timestamp, ev.RedirectResponse.EncodedDataLength)
+	}
+	wallTime := ev.WallTime.Time()
+	if len(b.entries) == 0 {
+		b.pageStart = ev.Timestamp
+		b.pageWallTime = wallTime
+	}
+	e := &harEntry{start: ev.Timestamp}
+	e.Pageref = harPageID
+	e.StartedDateTime = wallTime.UTC().Format(time.RFC3339Nano)
+	e.Request = HARRequest{
+		Method:      ev.Request.Method,
+		URL:         ev.Request.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(ev.Request.Headers),
+		QueryString: harQueryString(ev.Request.URL),
+		HeadersSize: -1,
+	}
+	if ev.Request.PostData != nil {
+		e.Request.PostData = &HARPostData{
+			MimeType: harHeader(e.Request.Headers, "Content-Type"),
+			Text:     *ev.Request.PostData,
+		}
+		e.Request.BodySize = len(*ev.Request.PostData)
+	}
+	e.Response = HARResponse{
+		HTTPVersion: "",
+		Cookies:     []HARNameValue{},
+		Headers:     []HARNameValue{},
+		Content:     HARContent{MimeType: "x-unknown"},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	b.entries = append(b.entries, e)
+	b.current[ev.RequestID] = e
+}
+
+func (b *harBuilder) responseReceived(ev *network.ResponseReceivedReply) {
+	if e, ok := b.current[ev.RequestID]; ok {
+		b.setResponse(e, &ev.Response)
+	}
+}
+
+func (b *harBuilder) loadingFinished(ev *network.LoadingFinishedReply) {
+	if e, ok := b.current[ev.RequestID]; ok {
+		b.finish(ev.RequestID, e, ev.Timestamp, ev.EncodedDataLength)
+	}
+}
+
+func (b *harBuilder) loadingFailed(ev *network.LoadingFailedReply) {
+	e, ok := b.current[ev.RequestID]
+	if !ok {
+		return
+	}
+	e.Response.Error = ev.ErrorText
+	switch {
+	case b.aborted[e.Request.URL]:
+		e.Comment = "aborted by request interception"
+	case ev.BlockedReason != "":
+		e.Comment = "blocked: " + string(ev.BlockedReason)
+	case ev.Canceled != nil && *ev.Canceled:
+		e.Comment = "canceled"
+	}
+	b.finish(ev.RequestID, e, ev.Timestamp, 0)
+}
+
+func (b *harBuilder) setResponse(e *harEntry, r *network.Response) {
+	e.Response.Status = r.Status
+	e.Response.StatusText = r.StatusText
+	e.Response.Headers = harHeaders(r.Headers)
+	e.Response.Content.MimeType = r.MimeType
+	if r.Protocol != nil {
+		e.Response.HTTPVersion = strings.ToUpper(*r.Protocol)
+		e.Request.HTTPVersion = e.Response.HTTPVersion
+	}
+	if len(r.RequestHeaders) > 0 {
+		//headers actually sent over the network
+		e.Request.Headers = harHeaders(r.RequestHeaders)
+	}
+	if r.RemoteIPAddress != nil {
+		e.ServerIPAddress = *r.RemoteIPAddress
+	}
+	e.timing = r.Timing
+}
+
+func (b *harBuilder) finish(id network.RequestID, e *harEntry, end network.MonotonicTime, encodedDataLength float64) {
+	e.end = end
+	e.finished = true
+	if encodedDataLength > 0 {
+		e.Response.BodySize = int(encodedDataLength)
+		e.Response.Content.Size = int(encodedDataLength)
+	}
+	if b.current[id] == e {
+		delete(b.current, id)
+	}
+}
+
+//build returns HAR document. Requests which have not finished yet are reported with "pending" comment.
+func (b *harBuilder) build(pageURL string) *HAR {
+	sinceStart := func(t network.MonotonicTime) float64 {
+		if t == 0 {
+			return -1
+		}
+		return ms(float64(t-b.pageStart) * 1000)
+	}
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "Dataflow Kit", Version: "1.0"},
+		Pages: []HARPage{{
+			StartedDateTime: b.pageWallTime.UTC().Format(time.RFC3339Nano),
+			ID:              harPageID,
+			Title:           pageURL,
+			PageTimings: HARPageTimings{
+				OnContentLoad: sinceStart(b.onContentLoad),
+				OnLoad:        sinceStart(b.onLoad),
+			},
+		}},
+		Entries: []HAREntry{},
+	}}
+	for _, e := range b.entries {
+		if !e.finished && e.Comment == "" {
+			e.Comment = "pending"
+		}
+		e.Timings, e.Time = harTimings(e)
+		har.Log.Entries = append(har.Log.Entries, e.HAREntry)
+	}
+	return har
+}
+
+//harTimings splits the entry duration into request phases according to Chrome resource timing.
+func harTimings(e *harEntry) (HARTimings, float64) {
+	total := 0.0
+	if e.end > e.start {
+		total = float64(e.end-e.start) * 1000
+	}
+	t := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	tm := e.timing
+	if tm == nil {
+		//request failed or was served without network
+		t.Wait = ms(total)
+		return t, ms(total)
+	}
+	//resource timing is relative to its own request time
+	offset := math.Max(0, (tm.RequestTime-float64(e.start))*1000)
+	blocked := tm.SendStart
+	for _, start := range []float64{tm.ConnectStart, tm.DNSStart} {
+		if start >= 0 {
+			blocked = start
+		}
+	}
+	t.Blocked = ms(offset + math.Max(0, blocked))
+	if tm.DNSStart >= 0 {
+		t.DNS = ms(tm.DNSEnd - tm.DNSStart)
+	}
+	if tm.ConnectStart >= 0 {
+		t.Connect = ms(tm.ConnectEnd - tm.ConnectStart)
+	}
+	if tm.SSLStart >= 0 {
+		t.SSL = ms(tm.SSLEnd - tm.SSLStart)
+	}
+	t.Send = ms(math.Max(0, tm.SendEnd-tm.SendStart))
+	t.Wait = ms(math.Max(0, tm.ReceiveHeadersEnd-tm.SendEnd))
+	t.Receive = ms(math.Max(0, total-offset-tm.ReceiveHeadersEnd))
+	sum := 0.0
+	for _, v := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if v > 0 {
+			sum += v
+		}
+	}
+	return t, ms(sum)
+}
+
+//ms rounds milliseconds to microseconds.
+func ms(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+//harHeaders converts Chrome headers to HAR headers sorted by name. Chrome joins multiple header values with new line.
+func harHeaders(h network.Headers) []HARNameValue {
+	headers := []HARNameValue{}
+	m := map[string]string{}
+	if err := json.Unmarshal(h, &m); err != nil {
+		return headers
+	}
+	for name, values := range m {
+		for _, v := range strings.Split(values, "\n") {
+			headers = append(headers, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+//harHeader returns value of the header.
+func harHeader(headers []HARNameValue, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func harQueryString(rawURL string) []HARNameValue {
+	qs := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return qs
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			qs = append(qs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(qs, func(i, j int) bool { return qs[i].Name < qs[j].Name })
+	return qs
+}
@@ -0,0 +1,71 @@
+package fetch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARBuilder(t *testing.T) {
+	b := newHARBuilder(map[string]bool{"http://example.com/ads.js": true})
+	post := "q=1"
+	b.requestWillBeSent(&network.RequestWillBeSentReply{
+		RequestID: "1", Timestamp: 100, WallTime: 1500000000,
+		Request: network.Request{Method: "POST", URL: "http://example.com/?a=1&b=2", PostData: &post,
+			Headers: network.Headers(`{"Content-Type":"application/x-www-form-urlencoded"}`)},
+	})
+	//redirect creates a new entry for the same request
+	b.requestWillBeSent(&network.RequestWillBeSentReply{
+		RequestID: "1", Timestamp: 100.1, WallTime: 1500000000.1,
+		Request:          network.Request{Method: "GET", URL: "http://example.com/home", Headers: network.Headers(`{}`)},
+		RedirectResponse: &network.Response{Status: 302, StatusText: "Found", Headers: network.Headers(`{"Location":"/home"}`)},
+	})
+	b.requestWillBeSent(&network.RequestWillBeSentReply{
+		RequestID: "2", Timestamp: 100.2, WallTime: 1500000000.2,
+		Request: network.Request{Method: "GET", URL: "http://example.com/ads.js", Headers: network.Headers(`{}`)},
+	})
+	b.requestWillBeSent(&network.RequestWillBeSentReply{
+		RequestID: "3", Timestamp: 100.3, WallTime: 1500000000.3,
+		Request: network.Request{Method: "GET", URL: "http://example.com/poll", Headers: network.Headers(`{}`)},
+	})
+	protocol := "http/1.1"
+	b.responseReceived(&network.ResponseReceivedReply{
+		RequestID: "1",
+		Response: network.Response{Status: 200, StatusText: "OK", MimeType: "text/html", Protocol: &protocol,
+			Headers: network.Headers(`{"Set-Cookie":"a=1\nb=2"}`),
+			Timing: &network.ResourceTiming{RequestTime: 100.1, DNSStart: 0, DNSEnd: 10, ConnectStart: 10, ConnectEnd: 30,
+				SSLStart: -1, SSLEnd: -1, SendStart: 30, SendEnd: 31, ReceiveHeadersEnd: 81}},
+	})
+	b.loadingFinished(&network.LoadingFinishedReply{RequestID: "1", Timestamp: 100.2, EncodedDataLength: 1024})
+	b.loadingFailed(&network.LoadingFailedReply{RequestID: "2", Timestamp: 100.25, ErrorText: "net::ERR_ABORTED"})
+	b.onLoad = 100.5
+
+	har := b.build("http://example.com")
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Equal(t, 500.0, har.Log.Pages[0].PageTimings.OnLoad)
+	assert.Equal(t, -1.0, har.Log.Pages[0].PageTimings.OnContentLoad)
+	entries := har.Log.Entries
+	assert.Len(t, entries, 4)
+
+	assert.Equal(t, 302, entries[0].Response.Status)
+	assert.Equal(t, "http://example.com/home", entries[0].Response.RedirectURL)
+	assert.Equal(t, &HARPostData{MimeType: "application/x-www-form-urlencoded", Text: "q=1"}, entries[0].Request.PostData)
+	assert.Equal(t, []HARNameValue{{"a", "1"}, {"b", "2"}}, entries[0].Request.QueryString)
+
+	assert.Equal(t, 200, entries[1].Response.Status)
+	assert.Equal(t, "HTTP/1.1", entries[1].Response.HTTPVersion)
+	assert.Equal(t, []HARNameValue{{"Set-Cookie", "a=1"}, {"Set-Cookie", "b=2"}}, entries[1].Response.Headers)
+	assert.Equal(t, 1024, entries[1].Response.Content.Size)
+	assert.Equal(t, HARTimings{Blocked: 0, DNS: 10, Connect: 20, SSL: -1, Send: 1, Wait: 50, Receive: 19}, entries[1].Timings)
+	assert.Equal(t, 100.0, entries[1].Time)
+
+	assert.Equal(t, "net::ERR_ABORTED", entries[2].Response.Error)
+	assert.Equal(t, "aborted by request interception", entries[2].Comment)
+	assert.Equal(t, 0, entries[2].Response.Status)
+	assert.Equal(t, "pending", entries[3].Comment)
+
+	_, err := json.Marshal(har)
+	assert.NoError(t, err)
+}
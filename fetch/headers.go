@@ -0,0 +1,167 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mafredri/cdp/protocol/emulation"
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/spf13/viper"
+)
+
+//userAgents keeps the list of User-Agents loaded from USER_AGENTS_FILE. User-Agents are rotated for requests without User-Agent specified.
+var userAgents = struct {
+	once sync.Once
+	mx   sync.Mutex
+	list []string
+	next int
+}{}
+
+//loadUserAgents reads User-Agents from the file. Every line contains one User-Agent. Empty lines and lines starting with # are skipped.
+func loadUserAgents(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	list := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		ua := strings.TrimSpace(scanner.Text())
+		if ua == "" || strings.HasPrefix(ua, "#") {
+			continue
+		}
+		list = append(list, ua)
+	}
+	return list, scanner.Err()
+}
+
+//nextUserAgent returns the next User-Agent from USER_AGENTS_FILE in round-robin order. Empty string is returned if no User-Agents are configured.
+func nextUserAgent() string {
+	userAgents.once.Do(func() {
+		path := viper.GetString("USER_AGENTS_FILE")
+		if path == "" {
+			return
+		}
+		list, err := loadUserAgents(path)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		userAgents.list = list
+	})
+	userAgents.mx.Lock()
+	defer userAgents.mx.Unlock()
+	if len(userAgents.list) == 0 {
+		return ""
+	}
+	ua := userAgents.list[userAgents.next%len(userAgents.list)]
+	userAgents.next++
+	return ua
+}
+
+//userAgent returns User-Agent of the request. UserAgent field takes precedence over User-Agent passed in Headers.
+func (req Request) userAgent() string {
+	if req.UserAgent != "" {
+		return req.UserAgent
+	}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, "User-Agent") {
+			return v
+		}
+	}
+	if req.Emulation != nil {
+		return req.Emulation.UserAgent
+	}
+	return ""
+}
+
+//setHeaders adds request Headers, Auth, User-Agent and emulated Accept-Language to HTTP request.
+func (req Request) setHeaders(r *http.Request) {
+	for k, v := range req.Headers {
+		r.Header.Set(k, v)
+	}
+	if name, value, _ := req.Auth.header(); name != "" {
+		r.Header.Set(name, value)
+	}
+	if ua := req.userAgent(); ua != "" {
+		r.Header.Set("User-Agent", ua)
+	}
+	if lang := req.acceptLanguage(); lang != "" {
+		r.Header.Set("Accept-Language", lang)
+	}
+}
+
+//setHeaders passes request Headers, User-Agent and Accept-Language to Headless Chrome. They are applied to all subsequent requests made by the page. Auth header is added by request interception to requests to the origin of the page only.
+func (f *ChromeFetcher) setHeaders(ctx context.Context, req Request) error {
+	headers := map[string]string{}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, "User-Agent") {
+			continue
+		}
+		headers[k] = v
+	}
+	if len(headers) > 0 {
+		h, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+		err = f.cdpClient.Network.SetExtraHTTPHeaders(ctx, network.NewSetExtraHTTPHeadersArgs(h))
+		if err != nil {
+			return err
+		}
+	}
+	ua, lang := req.userAgent(), req.acceptLanguage()
+	//navigator.language is overridden along with User-Agent only
+	if ua == "" && lang != "" {
+		version, err := f.cdpClient.Browser.GetVersion(ctx)
+		if err != nil {
+			return err
+		}
+		ua = version.UserAgent
+	}
+	if ua != "" {
+		args := emulation.NewSetUserAgentOverrideArgs(ua)
+		if lang != "" {
+			args.SetAcceptLanguage(lang)
+		}
+		return f.cdpClient.Emulation.SetUserAgentOverride(ctx, args)
+	}
+	return nil
+}
+
+//sameOrigin reports whether URLs have the same scheme and host.
+func sameOrigin(u1, u2 string) bool {
+	a, err := url.Parse(u1)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(u2)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+//withHeaders returns headers of intercepted request with the given headers set. Headers set by the page and by Network.setExtraHTTPHeaders are kept.
+func withHeaders(headers network.Headers, set map[string]string) network.Headers {
+	headersMap := map[string]interface{}{}
+	json.Unmarshal(headers, &headersMap)
+	for k, v := range set {
+		//names are case insensitive
+		for name := range headersMap {
+			if strings.EqualFold(name, k) {
+				delete(headersMap, name)
+			}
+		}
+		headersMap[k] = v
+	}
+	h, _ := json.Marshal(headersMap)
+	return h
+}
@@ -3,9 +3,10 @@ package fetch
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/slotix/dataflowkit/errs"
 )
 
 // NewHTTPClient returns an Fetch Service backed by an HTTP server living at the
@@ -41,6 +43,7 @@ func NewHTTPClient(instance string) (Service, error) {
 			copyURL(u, "/fetch"),
 			encodeRequest,
 			decodeFetcherContent,
+			httptransport.ClientBefore(httptransport.SetRequestHeader("Accept", "application/json")),
 		).Endpoint()
 	}
 
@@ -67,13 +70,23 @@ func decodeFetcherContent(ctx context.Context, r *http.Response) (interface{}, e
 	if r.StatusCode != http.StatusOK {
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(r.Body)
-		return nil, errors.New(buf.String())
+		//Keep status code returned by Fetch service so callers can decide whether to retry.
+		msg := strings.TrimPrefix(strings.TrimSpace(buf.String()), fmt.Sprintf("Status: %d. ", r.StatusCode))
+		return nil, errs.StatusError{Code: r.StatusCode, Err: errors.New(msg)}
 	}
-	data, err := ioutil.ReadAll(r.Body)
-	if err != nil {
+	envelope := responseEnvelope{Response: &Response{}}
+	if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
 		return nil, err
 	}
-	return data, nil
+	body := []byte(envelope.Body)
+	if envelope.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(envelope.Body); err != nil {
+			return nil, err
+		}
+	}
+	envelope.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return envelope.Response, nil
 }
 
 func copyURL(base *url.URL, path string) *url.URL {
@@ -82,14 +95,12 @@ func copyURL(base *url.URL, path string) *url.URL {
 	return &next
 }
 
-func (e endpoints) Fetch(req Request) (io.ReadCloser, error) {
-	ctx := context.Background()
+func (e endpoints) Fetch(ctx context.Context, req Request) (*Response, error) {
 	var resp interface{}
 	var err error
 	resp, err = e.fetchEndpoint(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	readCloser := ioutil.NopCloser(bytes.NewReader(resp.([]byte)))
-	return readCloser, nil
+	return resp.(*Response), nil
 }
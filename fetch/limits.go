@@ -0,0 +1,137 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+)
+
+//defaultMaxRedirects is used if MAX_REDIRECTS is not set.
+const defaultMaxRedirects = 10
+
+//maxBodySize returns maximum size of fetched document in bytes. Zero means no limit.
+func maxBodySize() int64 {
+	return viper.GetInt64("MAX_BODY_SIZE")
+}
+
+//maxRedirects returns maximum number of redirects followed by fetchers.
+func maxRedirects() int {
+	if n := viper.GetInt("MAX_REDIRECTS"); n > 0 {
+		return n
+	}
+	return defaultMaxRedirects
+}
+
+//maxDOMSize returns maximum size of outer HTML of the page rendered by Chrome in characters. Zero means no limit.
+func maxDOMSize() int64 {
+	return viper.GetInt64("MAX_DOM_SIZE")
+}
+
+//seconds returns duration of the setting given in seconds.
+func seconds(key string) time.Duration {
+	return time.Duration(viper.GetInt(key)) * time.Second
+}
+
+//setTimeouts applies CONNECT_TIMEOUT, TLS_TIMEOUT and HEADER_TIMEOUT settings to the transport. Transport defaults are kept for settings which are not set.
+func setTimeouts(t *http.Transport) {
+	if d := seconds("CONNECT_TIMEOUT"); d > 0 {
+		t.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if d := seconds("TLS_TIMEOUT"); d > 0 {
+		t.TLSHandshakeTimeout = d
+	}
+	if d := seconds("HEADER_TIMEOUT"); d > 0 {
+		t.ResponseHeaderTimeout = d
+	}
+}
+
+//checkRedirect stops following redirects after MAX_REDIRECTS.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if max := maxRedirects(); len(via) > max {
+		return errs.TooManyRedirects{URL: via[0].URL.String(), Limit: max}
+	}
+	return nil
+}
+
+//phaseTimeout converts connect, TLS handshake and response header timeouts of the request to errs.Timeout. Nil is returned for other errors.
+func phaseTimeout(req *http.Request, err error) error {
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() || req.Context().Err() != nil {
+		return nil
+	}
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return errs.Timeout{URL: req.URL.String(), Phase: "connect", Timeout: seconds("CONNECT_TIMEOUT")}
+	case strings.Contains(err.Error(), "TLS handshake timeout"):
+		return errs.Timeout{URL: req.URL.String(), Phase: "tls", Timeout: seconds("TLS_TIMEOUT")}
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return errs.Timeout{URL: req.URL.String(), Phase: "header", Timeout: seconds("HEADER_TIMEOUT")}
+	}
+	return nil
+}
+
+//totalTimeout returns errs.Timeout if the fetch failed because its overall time limit is exceeded. Other errors are returned as is.
+func totalTimeout(ctx context.Context, req Request, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errs.Timeout{URL: req.getURL(), Phase: "total", Timeout: req.timeout()}
+	}
+	return err
+}
+
+//readBody reads the document up to MAX_BODY_SIZE bytes. Larger documents are truncated if TRUNCATE_BODY is set, otherwise errs.TooLarge is returned. The second return value reports truncation.
+func readBody(body io.Reader, rawurl string) ([]byte, bool, error) {
+	limit := maxBodySize()
+	if limit <= 0 {
+		data, err := ioutil.ReadAll(body)
+		return data, false, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) <= limit {
+		return data, false, nil
+	}
+	if !viper.GetBool("TRUNCATE_BODY") {
+		return nil, false, errs.TooLarge{URL: rawurl, Limit: limit}
+	}
+	return data[:limit], true, nil
+}
+
+//checkContentLength rejects documents declaring Content-Length over MAX_BODY_SIZE before they are read. Such documents are read and truncated if TRUNCATE_BODY is set.
+func checkContentLength(resp *http.Response, rawurl string) error {
+	if limit := maxBodySize(); limit > 0 && resp.ContentLength > limit && !viper.GetBool("TRUNCATE_BODY") {
+		return errs.TooLarge{URL: rawurl, Limit: limit}
+	}
+	return nil
+}
+
+//checkDOMSize returns errs.DOMTooLarge if outer HTML of the page exceeds MAX_DOM_SIZE. The size is measured in the browser so that huge pages are not transferred to fetch.d.
+func (f *ChromeFetcher) checkDOMSize(ctx context.Context, pageURL string) error {
+	limit := maxDOMSize()
+	if limit <= 0 {
+		return nil
+	}
+	value, err := f.evaluate(ctx, "document.documentElement ? document.documentElement.outerHTML.length : 0")
+	if err != nil {
+		return err
+	}
+	var size int64
+	if err = json.Unmarshal(value, &size); err != nil {
+		return err
+	}
+	if size > limit {
+		return errs.DOMTooLarge{URL: pageURL, Size: size, Limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseFetcher_MaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+	viper.Set("MAX_BODY_SIZE", 10)
+	defer viper.Set("MAX_BODY_SIZE", 0)
+
+	for _, u := range []string{ts.URL, ts.URL + "?chunked=1"} {
+		_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: u})
+		assert.Error(t, err)
+		assert.Equal(t, errs.TooLarge{URL: u, Limit: 10}, err)
+	}
+
+	viper.Set("TRUNCATE_BODY", true)
+	defer viper.Set("TRUNCATE_BODY", false)
+	resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL})
+	assert.NoError(t, err)
+	assert.True(t, resp.Truncated)
+	body, _ := ioutil.ReadAll(resp)
+	assert.Equal(t, "aaaaaaaaaa", string(body))
+}
+
+func TestBaseFetcher_MaxBodySizeCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("STORAGE_TYPE", "Diskv")
+	viper.Set("DISKV_BASE_DIR", dir)
+	viper.Set("HTTP_CACHE", true)
+	defer viper.Set("HTTP_CACHE", false)
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+	viper.Set("MAX_BODY_SIZE", 10)
+	defer viper.Set("MAX_BODY_SIZE", 0)
+
+	for _, u := range []string{ts.URL, ts.URL + "?chunked=1"} {
+		_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: u})
+		assert.Equal(t, errs.TooLarge{URL: u, Limit: 10}, err)
+	}
+
+	//truncated documents are not cached
+	viper.Set("TRUNCATE_BODY", true)
+	defer viper.Set("TRUNCATE_BODY", false)
+	for i := 0; i < 2; i++ {
+		resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "?chunked=1"})
+		assert.NoError(t, err)
+		assert.True(t, resp.Truncated)
+		body, _ := ioutil.ReadAll(resp)
+		assert.Equal(t, "aaaaaaaaaa", string(body))
+	}
+	assert.Equal(t, 4, hits)
+}
+
+func TestBaseFetcher_MaxRedirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+	viper.Set("MAX_REDIRECTS", 3)
+	defer viper.Set("MAX_REDIRECTS", 0)
+
+	resp, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/3"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(resp.Redirects))
+
+	_, err = newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/4"})
+	assert.Equal(t, errs.TooManyRedirects{URL: ts.URL + "/4", Limit: 3}, err)
+	assert.Equal(t, 508, err.(errs.Error).Status())
+}
+
+func TestBaseFetcher_Timeouts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-body" {
+			w.Write([]byte("<html>"))
+			w.(http.Flusher).Flush()
+		}
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	viper.Set("HEADER_TIMEOUT", 1)
+	_, err := newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/slow-header"})
+	viper.Set("HEADER_TIMEOUT", 0)
+	assert.Equal(t, errs.Timeout{URL: ts.URL + "/slow-header", Phase: "header", Timeout: time.Second}, err)
+	assert.Equal(t, 504, err.(errs.Error).Status())
+
+	_, err = newBaseFetcher().Fetch(context.Background(), Request{URL: ts.URL + "/slow-body", Timeout: 1})
+	assert.Equal(t, errs.Timeout{URL: ts.URL + "/slow-body", Phase: "total", Timeout: time.Second}, err)
+	assert.True(t, isProxyFailure(err))
+}
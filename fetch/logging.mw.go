@@ -1,7 +1,7 @@
 package fetch
 
 import (
-	"io"
+	"context"
 	"time"
 
 	"go.uber.org/zap"
@@ -21,10 +21,10 @@ type loggingMiddleware struct {
 	logger *zap.Logger
 }
 
-func (mw loggingMiddleware) Fetch(req Request) (out io.ReadCloser, err error) {
+func (mw loggingMiddleware) Fetch(ctx context.Context, req Request) (out *Response, err error) {
 	defer func(begin time.Time) {
 		url := req.getURL()
-		out, err = mw.Service.Fetch(req)
+		out, err = mw.Service.Fetch(ctx, req)
 		if err == nil {
 			mw.logger.Info("Fetch",
 				zap.String("URL", url),
@@ -0,0 +1,167 @@
+package fetch
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/slotix/dataflowkit/errs"
+	"go.uber.org/zap"
+)
+
+//NetworkFilter selects XHR and fetch responses recorded by Chrome fetcher while the page is rendered. Response is recorded if both URL and MimeType match.
+type NetworkFilter struct {
+	//Name identifies recorded responses. It must not contain dots. Defaults to "xhr".
+	Name string `json:"name,omitempty"`
+	//URL is a regular expression matched against response URL.
+	URL string `json:"url,omitempty"`
+	//MimeType is matched against the beginning of response MIME type, e.g. "application/json".
+	MimeType string `json:"mimeType,omitempty"`
+	urlRe    *regexp.Regexp
+}
+
+//NetworkResponse is XHR or fetch response recorded by Chrome fetcher.
+type NetworkResponse struct {
+	//Name is the name of the filter matched the response.
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	MimeType   string `json:"mimeType"`
+	Body       string `json:"body"`
+}
+
+//validate checks the filter and fills default values.
+func (nf *NetworkFilter) validate() error {
+	if nf.URL == "" && nf.MimeType == "" {
+		return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("network filter requires url or mimeType")}
+	}
+	if nf.Name == "" {
+		nf.Name = "xhr"
+	}
+	if strings.Contains(nf.Name, ".") {
+		return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("network filter name %s must not contain dots", nf.Name)}
+	}
+	if nf.URL != "" {
+		var err error
+		if nf.urlRe, err = regexp.Compile(nf.URL); err != nil {
+			return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("invalid network filter url: %s", err)}
+		}
+	}
+	return nil
+}
+
+func (nf *NetworkFilter) match(url, mimeType string) bool {
+	if nf.urlRe != nil && !nf.urlRe.MatchString(url) {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(mimeType), strings.ToLower(nf.MimeType))
+}
+
+//networkRecorder collects responses matching filters.
+type networkRecorder struct {
+	filters          []NetworkFilter
+	responseReceived network.ResponseReceivedClient
+	loadingFinished  network.LoadingFinishedClient
+}
+
+//newNetworkRecorder starts listening for network responses. Nothing is recorded if there are no filters.
+func (f *ChromeFetcher) newNetworkRecorder(ctx context.Context, filters []NetworkFilter) (*networkRecorder, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	r := &networkRecorder{filters: make([]NetworkFilter, len(filters))}
+	copy(r.filters, filters)
+	for i := range r.filters {
+		if err := r.filters[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if r.responseReceived, err = f.cdpClient.Network.ResponseReceived(ctx); err != nil {
+		return nil, err
+	}
+	if r.loadingFinished, err = f.cdpClient.Network.LoadingFinished(ctx); err != nil {
+		r.responseReceived.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *networkRecorder) Close() {
+	if r == nil {
+		return
+	}
+	r.responseReceived.Close()
+	r.loadingFinished.Close()
+}
+
+//recordedResponses returns recorded responses which have finished loading so far. Bodies are taken with Network.getResponseBody.
+func (f *ChromeFetcher) recordedResponses(ctx context.Context, r *networkRecorder) ([]NetworkResponse, error) {
+	if r == nil {
+		return nil, nil
+	}
+	matched := map[network.RequestID]*NetworkResponse{}
+	order := []network.RequestID{}
+	finished := map[network.RequestID]bool{}
+	for {
+		select {
+		case <-r.responseReceived.Ready():
+			ev, err := r.responseReceived.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if ev.Type != network.ResourceTypeXHR && ev.Type != network.ResourceTypeFetch {
+				continue
+			}
+			for _, filter := range r.filters {
+				if filter.match(ev.Response.URL, ev.Response.MimeType) {
+					matched[ev.RequestID] = &NetworkResponse{
+						Name:       filter.Name,
+						URL:        ev.Response.URL,
+						StatusCode: ev.Response.Status,
+						MimeType:   ev.Response.MimeType,
+					}
+					order = append(order, ev.RequestID)
+					break
+				}
+			}
+			continue
+		case <-r.loadingFinished.Ready():
+			ev, err := r.loadingFinished.Recv()
+			if err != nil {
+				return nil, err
+			}
+			finished[ev.RequestID] = true
+			continue
+		default:
+		}
+		break
+	}
+	responses := []NetworkResponse{}
+	for _, id := range order {
+		if !finished[id] {
+			continue
+		}
+		resp := matched[id]
+		reply, err := f.cdpClient.Network.GetResponseBody(ctx, network.NewGetResponseBodyArgs(id))
+		if err != nil {
+			//body may be evicted from Chrome buffer
+			logger.Warn("Failed to get response body", zap.String("url", resp.URL), zap.Error(err))
+			continue
+		}
+		resp.Body = reply.Body
+		if reply.Base64Encoded {
+			body, err := base64.StdEncoding.DecodeString(reply.Body)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = string(body)
+		}
+		responses = append(responses, *resp)
+	}
+	return responses, nil
+}
@@ -0,0 +1,27 @@
+package fetch
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkFilter(t *testing.T) {
+	nf := NetworkFilter{URL: `/api/products\?page=\d+`}
+	assert.NoError(t, nf.validate())
+	assert.Equal(t, "xhr", nf.Name)
+	assert.True(t, nf.match("http://example.com/api/products?page=2", "application/json"))
+	assert.False(t, nf.match("http://example.com/api/users", "application/json"))
+
+	nf = NetworkFilter{Name: "json", MimeType: "application/json"}
+	assert.NoError(t, nf.validate())
+	assert.True(t, nf.match("http://example.com/any", "Application/JSON"))
+	assert.False(t, nf.match("http://example.com/any", "text/html"))
+
+	for _, nf := range []NetworkFilter{{}, {URL: "("}, {Name: "a.b", URL: "api"}} {
+		err := nf.validate()
+		assert.Equal(t, http.StatusBadRequest, err.(errs.StatusError).Code, nf)
+	}
+}
@@ -0,0 +1,160 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+)
+
+//Factory creates a new Fetcher for every fetch.
+//
+//Fetcher interface has unexported methods managing session cookies, proxy and TLS settings. Custom fetchers embed *BaseFetcher created by NewBaseFetcher to inherit them and override Fetch, e.g. to sign requests to an API before passing them to the embedded fetcher.
+type Factory func() Fetcher
+
+//Capabilities declare request features supported by a fetcher. Requests are validated against them by fetch.d and by the scrape layer before they are sent.
+type Capabilities struct {
+	//JavaScript fetcher renders pages running their scripts. Wait conditions, Viewport and Console require it.
+	JavaScript bool `json:"javascript"`
+	//Actions fetcher performs Request.Actions on the page.
+	Actions bool `json:"actions"`
+	//Screenshots fetcher takes screenshots and PDFs listed in Request.Captures.
+	Screenshots bool `json:"screenshots"`
+	//Network fetcher records XHR and fetch responses selected by Request.Network and HAR.
+	Network bool `json:"network"`
+}
+
+//Check returns an error describing the first feature of the request the fetcher is unable to provide.
+func (c Capabilities) Check(req Request) error {
+	unsupported := ""
+	switch {
+	case !c.JavaScript && (req.Wait != nil || req.Viewport != nil):
+		unsupported = "wait conditions and viewport"
+	case !c.JavaScript && req.Console != nil:
+		unsupported = "console messages"
+	case !c.Actions && strings.TrimSpace(req.Actions) != "":
+		unsupported = "actions"
+	case !c.Screenshots && len(req.Captures) > 0:
+		unsupported = "screenshots and PDFs"
+	case !c.Network && (len(req.Network) > 0 || req.HAR):
+		unsupported = "network responses and HAR"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s fetcher does not support %s", req.fetcherName(), unsupported)
+}
+
+type registration struct {
+	factory      Factory
+	capabilities Capabilities
+}
+
+var (
+	registryMx sync.RWMutex
+	registry   = map[string]registration{}
+)
+
+func init() {
+	Register(string(Base), func() Fetcher { return newBaseFetcher() }, Capabilities{})
+	Register(string(Chrome), func() Fetcher { return newChromeFetcher() }, Capabilities{JavaScript: true, Actions: true, Screenshots: true, Network: true})
+	//replayed documents are the ones recorded by base fetcher
+	Register(string(Replay), func() Fetcher {
+		return newReplayFetcher(ReplayMode, viper.GetString("CASSETTE_DIR"), nil)
+	}, Capabilities{})
+}
+
+//Register makes a fetcher available under the given name. Requests select it by "type" field. Names are case insensitive. Register panics if the name is empty, factory is nil or the name is already registered, so it is meant to be called from init functions of the packages providing fetchers.
+func Register(name string, factory Factory, capabilities Capabilities) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		panic("fetch: Register fetcher with empty name")
+	}
+	if factory == nil {
+		panic("fetch: Register fetcher " + name + " with nil factory")
+	}
+	registryMx.Lock()
+	defer registryMx.Unlock()
+	if _, dup := registry[key]; dup {
+		panic("fetch: Register called twice for fetcher " + name)
+	}
+	registry[key] = registration{factory: factory, capabilities: capabilities}
+}
+
+//Lookup returns capabilities of the registered fetcher. Empty name stands for base fetcher.
+func Lookup(name string) (Capabilities, bool) {
+	r, ok := lookup(name)
+	return r.capabilities, ok
+}
+
+//Fetchers returns sorted names of registered fetchers.
+func Fetchers() []string {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(name string) (registration, bool) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		key = strings.ToLower(string(Base))
+	}
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	r, ok := registry[key]
+	return r, ok
+}
+
+//fetcherName returns the name of the fetcher serving the request. Requests of base type with actions, wait conditions, viewport, console, captures, network filters or HAR are served by Chrome fetcher.
+func (req Request) fetcherName() string {
+	name := strings.ToLower(strings.TrimSpace(req.Type))
+	if name == "" {
+		name = strings.ToLower(string(Base))
+	}
+	if name == strings.ToLower(string(Base)) && req.NeedsChrome() {
+		name = strings.ToLower(string(Chrome))
+	}
+	return name
+}
+
+//NeedsChrome returns true if the request asks for features of Chrome fetcher.
+func (req Request) NeedsChrome() bool {
+	return strings.TrimSpace(req.Actions) != "" || req.Wait != nil || req.Viewport != nil || req.Console != nil ||
+		len(req.Captures) > 0 || len(req.Network) > 0 || req.HAR
+}
+
+//CheckRequest validates the request against capabilities of the fetcher selected by its type. Unknown fetcher type and invalid captures are reported as errors.
+func CheckRequest(req Request) error {
+	r, ok := lookup(req.fetcherName())
+	if !ok {
+		return fmt.Errorf("unknown fetcher type %s. Registered fetchers: %s", req.Type, strings.Join(Fetchers(), ", "))
+	}
+	if err := r.capabilities.Check(req); err != nil {
+		return err
+	}
+	if err := req.checkCaptures(); err != nil {
+		//status is set by callers of CheckRequest
+		if se, ok := err.(errs.StatusError); ok {
+			return se.Err
+		}
+		return err
+	}
+	return nil
+}
+
+//fetcherFor creates the fetcher serving the request.
+func fetcherFor(req Request) (Fetcher, error) {
+	if err := CheckRequest(req); err != nil {
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: err}
+	}
+	r, _ := lookup(req.fetcherName())
+	return r.factory(), nil
+}
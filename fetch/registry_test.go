@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+//signedFetcher adds signature header to requests sent by the embedded base fetcher.
+type signedFetcher struct {
+	*BaseFetcher
+}
+
+func (f signedFetcher) Fetch(ctx context.Context, req Request) (*Response, error) {
+	req.Headers = map[string]string{"X-Signature": "signed " + req.getURL()}
+	return f.BaseFetcher.Fetch(ctx, req)
+}
+
+func TestRegister(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Signature")))
+	}))
+	defer ts.Close()
+
+	//registry is global, the fetcher is registered once for repeated test runs
+	if _, ok := Lookup("signed"); !ok {
+		Register("Signed", func() Fetcher { return signedFetcher{NewBaseFetcher()} }, Capabilities{Actions: true})
+	}
+	assert.Panics(t, func() { Register("signed", func() Fetcher { return nil }, Capabilities{}) })
+	assert.Panics(t, func() { Register("", func() Fetcher { return nil }, Capabilities{}) })
+	assert.Panics(t, func() { Register("nil", nil, Capabilities{}) })
+	assert.Equal(t, []string{"base", "chrome", "replay", "signed"}, Fetchers())
+
+	resp, err := FetchService{}.Fetch(context.Background(), Request{Type: "signed", URL: ts.URL})
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp)
+	assert.Equal(t, "signed "+ts.URL, string(body))
+
+	caps, ok := Lookup("")
+	assert.True(t, ok)
+	assert.Equal(t, Capabilities{}, caps)
+	caps, ok = Lookup("CHROME")
+	assert.True(t, ok)
+	assert.True(t, caps.JavaScript)
+	_, ok = Lookup("unknown")
+	assert.False(t, ok)
+
+	assert.NoError(t, CheckRequest(Request{Type: "signed", Actions: `[{"click":{"element":"a"}}]`}))
+	//base requests with Chrome features are served by Chrome
+	for _, req := range []Request{
+		{Captures: []Capture{{Type: Screenshot}}},
+		{Type: "base", Actions: `[{"click":{"element":"a"}}]`},
+		{Wait: &Wait{Delay: 100}},
+		{Viewport: &Viewport{Width: 800, Height: 600}},
+		{Console: &Console{}},
+	} {
+		assert.NoError(t, CheckRequest(req))
+		assert.Equal(t, "chrome", req.fetcherName())
+	}
+	assert.EqualError(t, CheckRequest(Request{Type: "signed", Captures: []Capture{{Type: Screenshot}}}), "signed fetcher does not support screenshots and PDFs")
+	assert.EqualError(t, CheckRequest(Request{Type: "replay", Actions: `[{"click":{"element":"a"}}]`}), "replay fetcher does not support actions")
+	assert.EqualError(t, CheckRequest(Request{Type: "replay", Wait: &Wait{Delay: 100}}), "replay fetcher does not support wait conditions and viewport")
+	assert.Error(t, CheckRequest(Request{Type: "unknown"}))
+
+	_, err = FetchService{}.Fetch(context.Background(), Request{Type: "unknown", URL: ts.URL})
+	assert.Equal(t, 400, err.(errs.Error).Status())
+}
@@ -0,0 +1,271 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+)
+
+// Replay modes
+const (
+	//RecordMode fetches documents with Base or Chrome fetcher and saves them to the cassette directory.
+	RecordMode = "record"
+	//ReplayMode serves documents from the cassette directory. Requests missing in the cassette fail.
+	ReplayMode = "replay"
+)
+
+// ReplayFetcher records request→response pairs of the wrapped fetcher to the cassette directory or replays them without network access. It makes fetches deterministic for offline tests.
+type ReplayFetcher struct {
+	mode string
+	dir  string
+	//fetcher is the wrapped Base or Chrome fetcher used in record mode.
+	fetcher Fetcher
+	//jar keeps session cookies in replay mode.
+	jar *sessionJar
+}
+
+// cassette is a recorded fetch. Either response or error is recorded.
+type cassette struct {
+	Request cassetteKey `json:"request"`
+	//Fetcher is the name of the fetcher the response is recorded with.
+	Fetcher  string            `json:"fetcher,omitempty"`
+	Response *responseEnvelope `json:"response,omitempty"`
+	Error    *cassetteError    `json:"error,omitempty"`
+	//Cookies are cookies of the session with the host after the fetch.
+	Cookies []Cookie `json:"cookies,omitempty"`
+}
+
+// cassetteKey contains request fields identifying the recorded fetch. Headers, cookies, proxy and other settings not affecting the document are ignored. Fetcher type is ignored as well, so requests parsed again with Chrome fetcher after base one and requests of replay type are served from the same recording.
+type cassetteKey struct {
+	Method    string          `json:"method,omitempty"`
+	URL       string          `json:"url"`
+	FormData  string          `json:"formData,omitempty"`
+	Body      string          `json:"body,omitempty"`
+	JSON      json.RawMessage `json:"json,omitempty"`
+	Multipart []FormPart      `json:"multipart,omitempty"`
+	Actions   string          `json:"actions,omitempty"`
+}
+
+type cassetteError struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// newReplayFetcher creates ReplayFetcher. Fetcher is required in record mode only.
+func newReplayFetcher(mode, dir string, fetcher Fetcher) *ReplayFetcher {
+	if dir == "" {
+		dir = "cassettes"
+	}
+	f := &ReplayFetcher{mode: strings.ToLower(mode), dir: dir, fetcher: fetcher}
+	if f.mode != RecordMode {
+		f.mode = ReplayMode
+		f.fetcher = nil
+		f.jar, _ = newSessionJar()
+	}
+	return f
+}
+
+// replayMode returns REPLAY_MODE setting of fetch.d. Empty string means that documents are fetched from the network.
+func replayMode() string {
+	switch mode := strings.ToLower(viper.GetString("REPLAY_MODE")); mode {
+	case RecordMode, ReplayMode:
+		return mode
+	}
+	return ""
+}
+
+func newCassetteKey(req Request) cassetteKey {
+	k := cassetteKey{
+		Method:    strings.ToUpper(req.Method),
+		URL:       strings.TrimSpace(req.getURL()),
+		FormData:  req.FormData,
+		Body:      req.Body,
+		JSON:      req.JSON,
+		Multipart: req.Multipart,
+		Actions:   req.Actions,
+	}
+	if k.Method == "" {
+		k.Method = "GET"
+		if req.hasBody() {
+			k.Method = "POST"
+		}
+	}
+	return k
+}
+
+// path returns the cassette file of the request. Cassettes are grouped by host.
+func (f *ReplayFetcher) path(k cassetteKey) (string, error) {
+	u, err := url.Parse(k.URL)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+	host := strings.Replace(u.Host, ":", "_", -1)
+	return filepath.Join(f.dir, host, fmt.Sprintf("%x.json", sha1.Sum(data))), nil
+}
+
+// Fetch records the response of the wrapped fetcher or replays it from the cassette.
+func (f *ReplayFetcher) Fetch(ctx context.Context, request Request) (*Response, error) {
+	key := newCassetteKey(request)
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if f.mode == RecordMode {
+		return f.record(ctx, request, key, path)
+	}
+	return f.replay(key, path)
+}
+
+func (f *ReplayFetcher) record(ctx context.Context, request Request, key cassetteKey, path string) (*Response, error) {
+	resp, fetchErr := f.fetcher.Fetch(ctx, request)
+	//cancelled fetches are not recorded
+	if ctx.Err() != nil {
+		return resp, fetchErr
+	}
+	c := cassette{Request: key, Fetcher: request.fetcherName()}
+	if fetchErr != nil {
+		c.Error = &cassetteError{Message: fetchErr.Error()}
+		if e, ok := fetchErr.(errs.Error); ok {
+			c.Error.Code = e.Status()
+		}
+	} else {
+		body, err := ioutil.ReadAll(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		c.Response = &responseEnvelope{Response: resp, Body: string(body)}
+		if IsBinary(resp.Kind) {
+			c.Response.Body = base64.StdEncoding.EncodeToString(body)
+			c.Response.Encoding = "base64"
+		}
+	}
+	if u, err := url.Parse(key.URL); err == nil {
+		if cookies, err := f.fetcher.getCookies(u); err == nil && len(cookies) > 0 {
+			c.Cookies = sessionCookies(cookies, u.Host)
+		}
+	}
+	if err := writeCassette(path, c); err != nil {
+		return nil, err
+	}
+	return resp, fetchErr
+}
+
+func (f *ReplayFetcher) replay(key cassetteKey, path string) (*Response, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errs.StatusError{Code: http.StatusNotFound, Err: fmt.Errorf("no cassette recorded for %s %s", key.Method, key.URL)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := cassette{Response: &responseEnvelope{Response: &Response{}}}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if u, err := url.Parse(key.URL); err == nil && len(c.Cookies) > 0 {
+		cookies := []*http.Cookie{}
+		for _, rc := range c.Cookies {
+			if cookie, err := rc.httpCookie(hostname(u.Host)); err == nil && cookie != nil {
+				cookies = append(cookies, cookie)
+			}
+		}
+		f.jar.SetCookies(u, cookies)
+	}
+	if c.Error != nil {
+		if c.Error.Code != 0 {
+			msg := strings.TrimPrefix(c.Error.Message, fmt.Sprintf("Status: %d. ", c.Error.Code))
+			return nil, errs.StatusError{Code: c.Error.Code, Err: errors.New(msg)}
+		}
+		return nil, errors.New(c.Error.Message)
+	}
+	body := []byte(c.Response.Body)
+	if c.Response.Encoding == "base64" {
+		if body, err = base64.StdEncoding.DecodeString(c.Response.Body); err != nil {
+			return nil, err
+		}
+	}
+	resp := c.Response.Response
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// writeCassette saves the cassette replacing the previous recording of the request.
+func writeCassette(path string, c cassette) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *ReplayFetcher) getCookieJar() http.CookieJar {
+	if f.fetcher != nil {
+		return f.fetcher.getCookieJar()
+	}
+	return f.jar
+}
+
+func (f *ReplayFetcher) setCookieJar(jar http.CookieJar) {
+	if f.fetcher != nil {
+		f.fetcher.setCookieJar(jar)
+		return
+	}
+	if j, ok := jar.(*sessionJar); ok {
+		f.jar = j
+	}
+}
+
+func (f *ReplayFetcher) getCookies(u *url.URL) ([]*http.Cookie, error) {
+	if f.fetcher != nil {
+		return f.fetcher.getCookies(u)
+	}
+	return f.jar.all(u), nil
+}
+
+func (f *ReplayFetcher) setCookies(u *url.URL, cookies []*http.Cookie) error {
+	if f.fetcher != nil {
+		return f.fetcher.setCookies(u, cookies)
+	}
+	f.jar.SetCookies(u, cookies)
+	return nil
+}
+
+func (f *ReplayFetcher) setProxy(p *proxy) {
+	if f.fetcher != nil {
+		f.fetcher.setProxy(p)
+	}
+}
+
+func (f *ReplayFetcher) setTLS(s *tlsSettings) {
+	if f.fetcher != nil {
+		f.fetcher.setTLS(s)
+	}
+}
+
+// Static type assertion
+var _ Fetcher = &ReplayFetcher{}
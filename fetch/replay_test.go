@@ -0,0 +1,73 @@
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayFetcher(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0, 1, 2})
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+			w.Write([]byte("<html><body>" + r.Method + " " + r.URL.Path + "</body></html>"))
+		}
+	}))
+	dir, err := ioutil.TempDir("", "cassettes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder := newReplayFetcher(RecordMode, dir, newBaseFetcher())
+	recorded := map[string][]byte{}
+	for _, path := range []string{"/page", "/image.png"} {
+		resp, err := recorder.Fetch(context.Background(), Request{URL: ts.URL + path})
+		assert.NoError(t, err)
+		recorded[path], err = ioutil.ReadAll(resp)
+		assert.NoError(t, err)
+	}
+	_, err = recorder.Fetch(context.Background(), Request{URL: ts.URL + "/missing"})
+	assert.Error(t, err)
+	ts.Close()
+
+	player := newFetcher(Replay).(*ReplayFetcher)
+	player.dir = dir
+	for path, body := range recorded {
+		resp, err := player.Fetch(context.Background(), Request{URL: ts.URL + path})
+		assert.NoError(t, err)
+		actual, err := ioutil.ReadAll(resp)
+		assert.NoError(t, err)
+		assert.Equal(t, body, actual)
+		assert.Equal(t, ts.URL+path, resp.URL)
+	}
+	u, _ := url.Parse(ts.URL)
+	cookies, err := player.getCookies(u)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cookies))
+
+	_, err = player.Fetch(context.Background(), Request{URL: ts.URL + "/missing"})
+	assert.Equal(t, 404, err.(errs.Error).Status())
+
+	//POST request is not the recorded GET one
+	_, err = player.Fetch(context.Background(), Request{URL: ts.URL + "/page", FormData: "a=1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no cassette recorded for POST")
+	//recordings are shared by fetchers, e.g. pages parsed again with Chrome fetcher are not fetched from the network
+	resp, err := player.Fetch(context.Background(), Request{URL: ts.URL + "/page", Type: "chrome"})
+	assert.NoError(t, err)
+	actual, err := ioutil.ReadAll(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, recorded["/page"], actual)
+}
@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/mafredri/cdp/protocol/network"
+)
+
+//Response is returned by fetchers. It carries fetched document along with response metadata. Response implements io.ReadCloser to read the document body.
+type Response struct {
+	//Body is the document content. Text documents are converted to UTF-8, binary ones are returned as is.
+	Body io.ReadCloser `json:"-"`
+	//StatusCode is the HTTP status code of the final response.
+	StatusCode int `json:"statusCode"`
+	//Header contains headers of the final response.
+	Header http.Header `json:"header"`
+	//URL is the final URL after redirects.
+	URL string `json:"url"`
+	//Redirects lists URLs which were redirected in the order they were requested.
+	Redirects []string `json:"redirects,omitempty"`
+	//ContentType is the Content-Type of the document.
+	ContentType string `json:"contentType"`
+	//Charset is the original encoding of the document. It is empty for binary documents.
+	Charset string `json:"charset"`
+	//Kind is the kind of the document: "html", "json", "xml", "pdf", "image", "text" or "binary".
+	Kind string `json:"kind"`
+	//Timings contains timing information of the request.
+	Timings Timings `json:"timings"`
+	//Actions reports results of actions performed by Chrome fetcher.
+	Actions []ActionResult `json:"actions,omitempty"`
+	//Network contains XHR and fetch responses recorded according to Request.Network filters.
+	Network []NetworkResponse `json:"network,omitempty"`
+	//Console contains console messages and uncaught JavaScript errors of the page requested by Request.Console.
+	Console []ConsoleMessage `json:"console,omitempty"`
+	//HAR is HTTP Archive of Chrome network session requested by Request.HAR.
+	HAR *HAR `json:"har,omitempty"`
+	//Captures contains screenshots and PDFs requested by Request.Captures.
+	Captures []CaptureData `json:"captures,omitempty"`
+	//Truncated is set if the document is cut to MAX_BODY_SIZE.
+	Truncated bool `json:"truncated,omitempty"`
+	//Cookies contains cookies of the user session with the host after the fetch. They are returned for requests with UserToken.
+	Cookies []Cookie `json:"cookies,omitempty"`
+	//requestID is the ID of the main frame document request made by Chrome.
+	requestID network.RequestID
+}
+
+//Timings contains durations of request phases. Phases which did not happen, like DNS lookup for reused connections, are zero.
+type Timings struct {
+	//Start is the time the request was started.
+	Start time.Time `json:"start"`
+	//DNS is the duration of DNS lookup.
+	DNS time.Duration `json:"dns"`
+	//Connect is the duration of TCP connection establishment.
+	Connect time.Duration `json:"connect"`
+	//TLS is the duration of TLS handshake.
+	TLS time.Duration `json:"tls"`
+	//FirstByte is the time elapsed from the start until the response headers were received.
+	FirstByte time.Duration `json:"firstByte"`
+	//Total is the total duration of the fetch.
+	Total time.Duration `json:"total"`
+}
+
+//Read reads the document body.
+func (r *Response) Read(p []byte) (int, error) {
+	return r.Body.Read(p)
+}
+
+//Close closes the document body.
+func (r *Response) Close() error {
+	return r.Body.Close()
+}
+
+//newResponse creates Response from http.Response. Redirect chain is restored from requests preceding the final one.
+func newResponse(resp *http.Response, body io.ReadCloser, kind, charset string, timings Timings) *Response {
+	r := &Response{
+		Body:        body,
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		ContentType: resp.Header.Get("Content-Type"),
+		Charset:     charset,
+		Kind:        kind,
+		Timings:     timings,
+	}
+	r.URL, r.Redirects = redirectChain(resp)
+	return r
+}
+
+//redirectChain returns the final URL of the response and URLs redirected before it.
+func redirectChain(resp *http.Response) (string, []string) {
+	if resp.Request == nil {
+		return "", nil
+	}
+	var redirects []string
+	for prev := resp.Request.Response; prev != nil && prev.Request != nil; prev = prev.Request.Response {
+		redirects = append([]string{prev.Request.URL.String()}, redirects...)
+	}
+	return resp.Request.URL.String(), redirects
+}
+
+//withTimingsTrace returns context collecting request timings.
+func withTimingsTrace(ctx context.Context, t *Timings) context.Context {
+	var dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.DNS = time.Since(dnsStart) },
+		ConnectStart: func(network, addr string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() { t.FirstByte = time.Since(t.Start) },
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
+
+//documentResponse builds Response from Network events of the main frame document. Redirects are collected from requestWillBeSent events, the final response is the last document response received by the main frame.
+func (f *ChromeFetcher) documentResponse(ctx context.Context, requestWillBeSent network.RequestWillBeSentClient, responseReceived network.ResponseReceivedClient) (*Response, error) {
+	tree, err := f.cdpClient.Page.GetFrameTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mainFrame := tree.FrameTree.Frame.ID
+	r := &Response{Header: http.Header{}}
+	for {
+		select {
+		case <-requestWillBeSent.Ready():
+			ev, err := requestWillBeSent.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if ev.Type == network.ResourceTypeDocument && ev.FrameID != nil && *ev.FrameID == mainFrame && ev.RedirectResponse != nil {
+				r.Redirects = append(r.Redirects, ev.RedirectResponse.URL)
+			}
+			continue
+		case <-responseReceived.Ready():
+			ev, err := responseReceived.Recv()
+			if err != nil {
+				return nil, err
+			}
+			if ev.Type == network.ResourceTypeDocument && ev.FrameID != nil && *ev.FrameID == mainFrame {
+				r.setNetworkResponse(ev.Response)
+				r.requestID = ev.RequestID
+			}
+			continue
+		default:
+		}
+		break
+	}
+	return r, nil
+}
+
+//setNetworkResponse fills Response metadata with response data received from Chrome.
+func (r *Response) setNetworkResponse(resp network.Response) {
+	r.StatusCode = resp.Status
+	r.URL = resp.URL
+	r.Header = http.Header{}
+	headers := map[string]string{}
+	json.Unmarshal(resp.Headers, &headers)
+	for k, v := range headers {
+		//Chrome joins multiple header values with new line
+		for _, value := range strings.Split(v, "\n") {
+			r.Header.Add(k, value)
+		}
+	}
+	r.ContentType = r.Header.Get("Content-Type")
+	if r.ContentType == "" {
+		r.ContentType = resp.MimeType
+	}
+	if _, params, err := mime.ParseMediaType(r.ContentType); err == nil {
+		r.Charset = strings.ToLower(params["charset"])
+	}
+	r.Kind = ContentKind(r.ContentType, nil)
+	if t := resp.Timing; t != nil {
+		ms := func(start, end float64) time.Duration {
+			if start < 0 || end < 0 {
+				return 0
+			}
+			return time.Duration((end - start) * float64(time.Millisecond))
+		}
+		r.Timings.DNS = ms(t.DNSStart, t.DNSEnd)
+		r.Timings.Connect = ms(t.ConnectStart, t.ConnectEnd)
+		r.Timings.TLS = ms(t.SSLStart, t.SSLEnd)
+		r.Timings.FirstByte = ms(0, t.ReceiveHeadersEnd)
+	}
+}
+
+//documentBody returns the original content of non-HTML document as Chrome renders JSON, XML and text documents wrapped into HTML. Kind is detected again by the content.
+func (f *ChromeFetcher) documentBody(ctx context.Context, r *Response) ([]byte, error) {
+	reply, err := f.cdpClient.Network.GetResponseBody(ctx, network.NewGetResponseBodyArgs(r.requestID))
+	if err != nil {
+		return nil, err
+	}
+	body := []byte(reply.Body)
+	if reply.Base64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(reply.Body); err != nil {
+			return nil, err
+		}
+	}
+	if body, r.Truncated, err = readBody(bytes.NewReader(body), r.URL); err != nil {
+		return nil, err
+	}
+	r.Kind = ContentKind(r.ContentType, body)
+	if IsBinary(r.Kind) {
+		r.Charset = ""
+	}
+	return body, nil
+}
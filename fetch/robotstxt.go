@@ -1,23 +1,42 @@
 package fetch
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
 	"github.com/temoto/robotstxt"
 )
 
+//DefaultRobotsAgent is the agent name used for matching robots.txt groups if ROBOTSTXT_AGENT is not set.
+const DefaultRobotsAgent = "Dataflow Kit"
+
+//robotsAgent returns the agent name used for matching robots.txt groups.
+func robotsAgent() string {
+	if agent := viper.GetString("ROBOTSTXT_AGENT"); agent != "" {
+		return agent
+	}
+	return DefaultRobotsAgent
+}
+
 //isRobotsTxt returns true if resource is robots.txt file
 func isRobotsTxt(url string) bool {
 	return strings.HasSuffix(url, "/robots.txt")
 }
 
 //fetchRobots is used for getting robots.txt files.
-func fetchRobots(req Request) (*http.Response, error) {
+func fetchRobots(ctx context.Context, req Request) (*http.Response, error) {
 	fetcher := newBaseFetcher()
-	return fetcher.response(req)
+	return fetcher.response(ctx, req)
 }
 
 //AssembleRobotstxtURL robots.txt URL from URL
@@ -31,8 +50,8 @@ func AssembleRobotstxtURL(rawurl string) (string, error) {
 	return rob.String(), nil
 }
 
-//RobotstxtData generates robots.txt url, retrieves its content through API fetch endpoint.
-func RobotstxtData(url string) (robotsData *robotstxt.RobotsData, err error) {
+//RobotstxtData generates robots.txt url, retrieves its content through API fetch endpoint. Server errors (5xx) return robots data disallowing everything along with errs.StatusError, callers should treat them as temporary and retrieve robots.txt again later.
+func RobotstxtData(ctx context.Context, url string) (robotsData *robotstxt.RobotsData, err error) {
 	robotsURL, err := AssembleRobotstxtURL(url)
 	if err != nil {
 		return nil, err
@@ -40,8 +59,16 @@ func RobotstxtData(url string) (robotsData *robotstxt.RobotsData, err error) {
 	r := Request{URL: robotsURL, Method: "GET"}
 
 	//response, err := fetchRobots(r)
-	response, err := fetchRobots(r)
+	response, err := fetchRobots(ctx, r)
 
+	if err != nil {
+		if se, ok := err.(errs.StatusError); ok && se.Code >= http.StatusInternalServerError {
+			robotsData, _ = robotstxt.FromStatusAndBytes(se.Code, nil)
+		}
+		return robotsData, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -56,11 +83,33 @@ func RobotstxtData(url string) (robotsData *robotstxt.RobotsData, err error) {
 	// From Google's spec:
 	// Server errors (5xx) are seen as temporary errors that result in a "full
 	// disallow" of crawling.
-	robotsData, err = robotstxt.FromResponse(response)
-	//robotsData, err = robotstxt.FromStatusAndBytes(response.StatusCode, []byte(response.HTML))
+	robotsData, err = robotstxt.FromStatusAndBytes(response.StatusCode, requestRateToCrawlDelay(body))
 	return
 }
 
+var requestRateRe = regexp.MustCompile(`(?im)^([ \t]*)request-rate[ \t]*:[ \t]*(\d+)[ \t]*/[ \t]*(\d+)[ \t]*([smhd]?)`)
+
+//requestRateToCrawlDelay rewrites non-standard Request-rate directives like "Request-rate: 1/5s" to the equivalent Crawl-delay directives as robotstxt package handles Crawl-delay only.
+func requestRateToCrawlDelay(body []byte) []byte {
+	return requestRateRe.ReplaceAllFunc(body, func(line []byte) []byte {
+		m := requestRateRe.FindSubmatch(line)
+		requests, _ := strconv.ParseFloat(string(m[2]), 64)
+		if requests == 0 {
+			return line
+		}
+		period, _ := strconv.ParseFloat(string(m[3]), 64)
+		switch string(bytes.ToLower(m[4])) {
+		case "m":
+			period *= 60
+		case "h":
+			period *= 3600
+		case "d":
+			period *= 86400
+		}
+		return []byte(fmt.Sprintf("%sCrawl-delay: %g", m[1], period/requests))
+	})
+}
+
 //AllowedByRobots checks if scraping of specified URL is allowed by robots.txt
 func AllowedByRobots(rawurl string, robotsData *robotstxt.RobotsData) bool {
 	if robotsData == nil {
@@ -70,13 +119,13 @@ func AllowedByRobots(rawurl string, robotsData *robotstxt.RobotsData) bool {
 	if err != nil {
 		logger.Error("error parsing URL")
 	}
-	return robotsData.TestAgent(parsedURL.Path, "Dataflow Kit")
+	return robotsData.TestAgent(parsedURL.Path, robotsAgent())
 }
 
-//getCrawlDelay retrieves Crawl-delay directive from robots.txt. Crawl-delay is not in the standard robots.txt protocol, and according to Wikipedia, some bots have different interpretations for this value. That's why maybe many websites don't even bother defining the rate limits in robots.txt. Crawl-delay value does not have an effect on delays between consecutive requests to the same domain for the moment. FetchDelay and RandomizeFetchDelay from ScrapeOptions are used for throttling a crawler speed.
+//GetCrawlDelay retrieves Crawl-delay directive from robots.txt. Crawl-delay is not in the standard robots.txt protocol, and according to Wikipedia, some bots have different interpretations for this value. That's why maybe many websites don't even bother defining the rate limits in robots.txt. Request-rate directive is converted to the equivalent Crawl-delay. Crawl-delay value is used by the parse task as a delay between consecutive requests to the same domain. FetchDelay and RandomizeFetchDelay from Payload are used for hosts without Crawl-delay.
 func GetCrawlDelay(r *robotstxt.RobotsData) time.Duration {
 	if r != nil {
-		group := r.FindGroup("Dataflow Kit")
+		group := r.FindGroup(robotsAgent())
 		return group.CrawlDelay
 	}
 	return 0
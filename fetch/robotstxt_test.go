@@ -1,9 +1,13 @@
 package fetch
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/slotix/dataflowkit/errs"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/temoto/robotstxt"
@@ -31,13 +35,40 @@ func TestRobotstxtData(t *testing.T) {
 	htmlServer := Start(serverCfg)
 
 	////////
-	rd, err := RobotstxtData(tsURL)
+	rd, err := RobotstxtData(context.Background(), tsURL)
 
 	assert.NoError(t, err, "No error returned")
 	assert.NotNil(t, rd, "Not nil returned")
 
-	_, err = RobotstxtData("invalid_host")
+	_, err = RobotstxtData(context.Background(), "invalid_host")
 	assert.Error(t, err, "error returned")
 
 	htmlServer.Stop()
 }
+
+func TestRobotstxtServerError(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	//server errors disallow everything
+	rd, err := RobotstxtData(context.Background(), ts.URL+"/page")
+	assert.Error(t, err)
+	assert.Equal(t, status, err.(errs.StatusError).Code)
+	assert.False(t, AllowedByRobots(ts.URL+"/page", rd))
+	//missing robots.txt allows everything
+	status = http.StatusNotFound
+	rd, err = RobotstxtData(context.Background(), ts.URL+"/page")
+	assert.Error(t, err)
+	assert.True(t, AllowedByRobots(ts.URL+"/page", rd))
+}
+
+func TestRequestRate(t *testing.T) {
+	robots, err := robotstxt.FromBytes(requestRateToCrawlDelay([]byte("User-agent: *\nRequest-rate: 1/5s\nDisallow: /private")))
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, GetCrawlDelay(robots))
+	robots, err = robotstxt.FromBytes(requestRateToCrawlDelay([]byte("User-agent: *\nrequest-rate: 2/1m")))
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, GetCrawlDelay(robots))
+}
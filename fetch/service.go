@@ -1,10 +1,9 @@
 package fetch
 
 import (
-	"encoding/json"
-	"io"
-	"net/http"
+	"context"
 	"net/url"
+	"strings"
 
 	"github.com/slotix/dataflowkit/storage"
 	"github.com/spf13/viper"
@@ -13,7 +12,7 @@ import (
 
 // Service defines Fetch service interface
 type Service interface {
-	Fetch(req Request) (io.ReadCloser, error)
+	Fetch(ctx context.Context, req Request) (*Response, error)
 }
 
 // FetchService implements service with empty struct
@@ -23,90 +22,71 @@ type FetchService struct {
 // ServiceMiddleware defines a middleware for a Fetch service
 type ServiceMiddleware func(Service) Service
 
-// Fetch method implements fetching content from web page with Base or Chrome fetcher.
-func (fs FetchService) Fetch(req Request) (io.ReadCloser, error) {
-	var fetcher Fetcher
-	switch req.Type {
-	case "chrome":
-		fetcher = newFetcher(Chrome)
-	default:
-		fetcher = newFetcher(Base)
-	}
-	var (
-		//jar     http.CookieJar
-		cookies []byte
-		cArr    []*http.Cookie
-		s       storage.Store
-	)
-
-	/* jarOpts := &cookiejar.Options{PublicSuffixList: publicsuffix.List}
-	jar, err := cookiejar.New(jarOpts)
+// Fetch method implements fetching content from web page with the fetcher registered for the request type. Fetching is aborted when ctx is done.
+func (fs FetchService) Fetch(ctx context.Context, req Request) (*Response, error) {
+	fetcher, err := fetcherFor(req)
 	if err != nil {
-		logger.Error("failed to create Cookie Jar")
-
-	} */
+		return nil, err
+	}
+	//REPLAY_MODE records fetched documents to CASSETTE_DIR or serves them from it
+	if mode := replayMode(); mode != "" && req.fetcherName() != strings.ToLower(string(Replay)) {
+		fetcher = newReplayFetcher(mode, viper.GetString("CASSETTE_DIR"), fetcher)
+	}
+	var s storage.Store
 	u, err := url.Parse(req.getURL())
 	if err != nil {
 		return nil, err
 	}
+	if _, _, err = req.Auth.header(); err != nil {
+		return nil, err
+	}
+	if err = req.resolveEmulation(); err != nil {
+		return nil, err
+	}
+	if req.userAgent() == "" {
+		req.UserAgent = nextUserAgent()
+	}
+	pool, err := getProxyPool()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := pool.choose(req, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.setProxy(proxy)
+	tlsSettings, err := getTLSSettings(req.TLSProfile)
+	if err != nil {
+		return nil, err
+	}
+	fetcher.setTLS(tlsSettings)
 	if req.UserToken != "" {
-		storageType := viper.GetString("STORAGE_TYPE")
-		s = storage.NewStore(storageType)
+		s = storage.NewStore(viper.GetString("STORAGE_TYPE"))
 		defer s.Close()
-		cookies, err = s.Read(storage.Record{
-			Type: storage.COOKIES,
-			Key:  req.UserToken + u.Host,
-		})
+		cookies, err := loadSession(s, req.UserToken, u.Host)
 		if err != nil {
-			logger.Warn(err.Error(),
-				zap.String("User Token", req.UserToken))
-
+			return nil, err
 		}
-		cArr = []*http.Cookie{}
 		if len(cookies) != 0 {
-			err = json.Unmarshal(cookies, &cArr)
-			if err != nil {
-				return nil, err
-			}
-
-			/* tempCarr := []*http.Cookie{}
-			for i := 0; i < len(cArr); i++ {
-				c := cArr[i]
-				if u.Host == c.Domain {
-					tempCarr = append(tempCarr, c)
-					cArr = append(cArr[:i], cArr[i+1:]...)
-					i--
-				}
-			}
-			jar.SetCookies(u, tempCarr) */
-			fetcher.setCookies(u, cArr)
+			fetcher.setCookies(u, cookies)
 		}
 	}
-	//fetcher.setCookieJar(jar)
-	res, err := fetcher.Fetch(req)
+	res, err := fetcher.Fetch(ctx, req)
+	//cancelled fetches tell nothing about proxy health
+	if ctx.Err() == nil {
+		pool.report(proxy, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 	if req.UserToken != "" {
-		//jar = fetcher.getCookieJar()
-		cooks, err := fetcher.getCookies(u)
+		cookies, err := fetcher.getCookies(u)
 		if err != nil {
 			logger.Warn(err.Error())
 			return res, nil
 		}
-		//cArr = append(cArr, cooks...)
-		cookies, err = json.Marshal(cooks)
-		if err != nil {
-			return nil, err
-		}
-		err = s.Write(storage.Record{
-			Type:    storage.COOKIES,
-			Key:     req.UserToken + u.Host,
-			Value:   cookies,
-			ExpTime: 0,
-		})
-
-		if err != nil {
+		res.Cookies = sessionCookies(cookies, u.Host)
+		if err = saveSession(s, req.UserToken, u.Host, cookies); err != nil {
 			logger.Warn(
 				"Failed to write cookie. ",
 				zap.String("User Token", req.UserToken),
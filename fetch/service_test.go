@@ -2,6 +2,7 @@ package fetch
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -70,7 +71,7 @@ func TestFetchServiceMW(t *testing.T) {
 		t.Log(err)
 	}
 
-	data, err := svc.Fetch(Request{
+	data, err := svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL + "/hello",
 		Method:    "GET",
@@ -81,7 +82,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.NotNil(t, data, "Expected response is not nil")
 
 	//read cookies
-	data, err = svc.Fetch(Request{
+	data, err = svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL,
 		Method:    "GET",
@@ -110,13 +111,13 @@ func TestFetchServiceMW(t *testing.T) {
 			Type: "base",
 			URL:  url,
 		}
-		_, err := svc.Fetch(req)
+		_, err := svc.Fetch(context.Background(), req)
 		t.Log(err)
 		assert.Error(t, err, fmt.Sprintf("%T", err)+"error returned")
 	}
 
 	//invalid URL
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:   "base",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -124,7 +125,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//invalid Fetcher type
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:   "invalid",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -132,7 +133,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//disallowed by robots
-	_, err = svc.Fetch(Request{
+	_, err = svc.Fetch(context.Background(), Request{
 		Type:      "base",
 		URL:       tsURL + "/disallowed",
 		Method:    "GET",
@@ -142,7 +143,7 @@ func TestFetchServiceMW(t *testing.T) {
 	assert.Error(t, err, "Expected error")
 
 	//disallowed by robots
-	// _, err = svc.Fetch(Request{
+	// _, err = svc.Fetch(context.Background(), Request{
 	// 	Type:      "base",
 	// 	URL:       tsURL + "/redirect",
 	// 	Method:    "GET",
@@ -174,7 +175,7 @@ func TestChromeFetchServiceMW(t *testing.T) {
 
 	//Test Chrome Fetcher
 	//svcChrome := FetchService{}
-	_, err := svc.Fetch(Request{
+	_, err := svc.Fetch(context.Background(), Request{
 		Type:      "chrome",
 		URL:       "http://testserver:12345",
 		FormData:  "",
@@ -184,7 +185,7 @@ func TestChromeFetchServiceMW(t *testing.T) {
 
 	svc1 := FetchService{}
 	//Pass invalid Fetcher type directly to service skipping NewHTTPClient
-	_, err = svc1.Fetch(Request{
+	_, err = svc1.Fetch(context.Background(), Request{
 		Type:   "invalid",
 		URL:    "invalid_addr",
 		Method: "GET",
@@ -194,7 +195,7 @@ func TestChromeFetchServiceMW(t *testing.T) {
 	//Test decodeChromeFetcherContent
 	//Chrome returns empty result for erroneous pages: <html><head></head><body></body></html>
 	//And returns no error
-	data, err := svc.Fetch(Request{
+	data, err := svc.Fetch(context.Background(), Request{
 		Type: "chrome",
 		URL:  "http://testserver:12345/status/404",
 		//URL:    "http://httpbin.org/status/404",
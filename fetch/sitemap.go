@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/temoto/robotstxt"
+	"go.uber.org/zap"
+	"golang.org/x/net/html/charset"
+)
+
+//maxSitemapSize is the maximum size of uncompressed sitemap allowed by sitemaps.org protocol.
+const maxSitemapSize = 50 * 1024 * 1024
+
+//maxSitemapDepth limits nesting of sitemap index files.
+const maxSitemapDepth = 3
+
+//SitemapURL is a page listed in the sitemap.
+type SitemapURL struct {
+	Loc string `xml:"loc" json:"loc"`
+	//LastMod is the date of last modification of the page in W3C Datetime format, e.g. "2019-04-01" or "2019-04-01T15:04:05+00:00".
+	LastMod string `xml:"lastmod" json:"lastmod,omitempty"`
+	//ChangeFreq is how frequently the page is likely to change: "always", "hourly", "daily", "weekly", "monthly", "yearly" or "never".
+	ChangeFreq string `xml:"changefreq" json:"changefreq,omitempty"`
+}
+
+//Modified returns parsed LastMod. False is returned if LastMod is missing or invalid.
+func (u SitemapURL) Modified() (time.Time, bool) {
+	lastMod := strings.TrimSpace(u.LastMod)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, lastMod); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//sitemapDocument is either urlset or sitemapindex document.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []SitemapURL `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+//AssembleSitemapURL returns default /sitemap.xml URL of the host of URL.
+func AssembleSitemapURL(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	return u.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String(), nil
+}
+
+//Sitemaps returns URLs of sitemaps listed by Sitemap directives of robots.txt. Default /sitemap.xml of the host of URL is returned if robots.txt lists no sitemaps.
+func Sitemaps(rawurl string, robotsData *robotstxt.RobotsData) ([]string, error) {
+	if robotsData != nil && len(robotsData.Sitemaps) > 0 {
+		return robotsData.Sitemaps, nil
+	}
+	sitemapURL, err := AssembleSitemapURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return []string{sitemapURL}, nil
+}
+
+//SitemapURLs retrieves sitemaps and returns pages listed in them. Sitemap index files are followed, gzipped sitemaps and plain text sitemaps listing one URL per line are supported. Only pages passing accept are returned, accept may be nil. Limit caps the number of returned pages if it is positive. Sitemaps which failed to download are skipped unless all of them failed.
+func SitemapURLs(ctx context.Context, sitemaps []string, accept func(SitemapURL) bool, limit int) ([]SitemapURL, error) {
+	s := sitemapReader{
+		accept:  accept,
+		limit:   limit,
+		visited: map[string]bool{},
+		pages:   map[string]bool{},
+		urls:    []SitemapURL{},
+	}
+	var lastErr error
+	read := 0
+	for _, sitemap := range sitemaps {
+		if err := s.read(ctx, sitemap, 0); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Warn("Failed to read sitemap", zap.String("URL", sitemap), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		read++
+	}
+	if read == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return s.urls, nil
+}
+
+//sitemapReader collects pages of sitemaps.
+type sitemapReader struct {
+	accept  func(SitemapURL) bool
+	limit   int
+	visited map[string]bool
+	pages   map[string]bool
+	urls    []SitemapURL
+}
+
+func (s *sitemapReader) full() bool {
+	return s.limit > 0 && len(s.urls) >= s.limit
+}
+
+func (s *sitemapReader) read(ctx context.Context, sitemap string, depth int) error {
+	if s.full() || s.visited[sitemap] {
+		return nil
+	}
+	s.visited[sitemap] = true
+	if depth > maxSitemapDepth {
+		return fmt.Errorf("sitemap index nesting exceeds %d levels", maxSitemapDepth)
+	}
+	data, err := fetchSitemap(ctx, sitemap)
+	if err != nil {
+		return err
+	}
+	urls, children, err := parseSitemap(data)
+	if err != nil {
+		return err
+	}
+	base, _ := url.Parse(sitemap)
+	for _, u := range urls {
+		if s.full() {
+			return nil
+		}
+		u.Loc = strings.TrimSpace(u.Loc)
+		if ref, err := url.Parse(u.Loc); err == nil && base != nil {
+			u.Loc = base.ResolveReference(ref).String()
+		}
+		if u.Loc == "" || s.pages[u.Loc] {
+			continue
+		}
+		if s.accept != nil && !s.accept(u) {
+			continue
+		}
+		s.pages[u.Loc] = true
+		s.urls = append(s.urls, u)
+	}
+	for _, child := range children {
+		if err := s.read(ctx, child, depth+1); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn("Failed to read sitemap", zap.String("URL", child), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+//fetchSitemap retrieves sitemap and decompresses it if it is gzipped.
+func fetchSitemap(ctx context.Context, sitemap string) ([]byte, error) {
+	resp, err := newBaseFetcher().response(ctx, Request{URL: sitemap, Method: "GET"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSitemapSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		if data, err = ioutil.ReadAll(io.LimitReader(zr, maxSitemapSize+1)); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > maxSitemapSize {
+		return nil, fmt.Errorf("sitemap exceeds %d bytes", maxSitemapSize)
+	}
+	return data, nil
+}
+
+//parseSitemap returns pages listed by urlset document or plain text sitemap and sitemaps listed by sitemapindex document.
+func parseSitemap(data []byte) ([]SitemapURL, []string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		urls := []SitemapURL{}
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				urls = append(urls, SitemapURL{Loc: line})
+			}
+		}
+		return urls, nil, scanner.Err()
+	}
+	doc := sitemapDocument{}
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		return doc.URLs, nil, nil
+	case "sitemapindex":
+		sitemaps := []string{}
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+		return nil, sitemaps, nil
+	}
+	return nil, nil, fmt.Errorf("unexpected sitemap root element %s", doc.XMLName.Local)
+}
@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/temoto/robotstxt"
+)
+
+func TestSitemapURLs(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>/products/2</loc><lastmod>2019-04-01</lastmod><changefreq>daily</changefreq></url>
+	<url><loc>/about</loc></url>
+</urlset>`))
+	zw.Close()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>http://` + r.Host + `/products.xml</loc></sitemap>
+	<sitemap><loc>http://` + r.Host + `/pages.xml.gz</loc></sitemap>
+	<sitemap><loc>http://` + r.Host + `/sitemap.xml</loc></sitemap>
+	<sitemap><loc>http://` + r.Host + `/missing.xml</loc></sitemap>
+</sitemapindex>`))
+	})
+	mux.HandleFunc("/products.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<urlset><url><loc>http://` + r.Host + `/products/1</loc><lastmod>2019-03-01T10:00:00+00:00</lastmod></url></urlset>`))
+	})
+	mux.HandleFunc("/pages.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(gz.Bytes())
+	})
+	mux.HandleFunc("/list.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("http://" + r.Host + "/products/1\n\nhttp://" + r.Host + "/products/3\n"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	urls, err := SitemapURLs(context.Background(), []string{ts.URL + "/sitemap.xml"}, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []SitemapURL{
+		{Loc: ts.URL + "/products/1", LastMod: "2019-03-01T10:00:00+00:00"},
+		{Loc: ts.URL + "/products/2", LastMod: "2019-04-01", ChangeFreq: "daily"},
+		{Loc: ts.URL + "/about"},
+	}, urls)
+
+	products := func(u SitemapURL) bool { return strings.Contains(u.Loc, "/products/") }
+	urls, err = SitemapURLs(context.Background(), []string{ts.URL + "/sitemap.xml", ts.URL + "/list.txt"}, products, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(urls))
+	assert.Equal(t, ts.URL+"/products/3", urls[2].Loc)
+
+	urls, err = SitemapURLs(context.Background(), []string{ts.URL + "/sitemap.xml"}, nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(urls))
+
+	_, err = SitemapURLs(context.Background(), []string{ts.URL + "/missing.xml"}, nil, 0)
+	assert.Error(t, err)
+}
+
+func TestSitemaps(t *testing.T) {
+	robots, err := robotstxt.FromString("User-agent: *\nDisallow:\nSitemap: http://example.com/sitemap_index.xml\n")
+	assert.NoError(t, err)
+	sitemaps, err := Sitemaps("http://example.com/products", robots)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://example.com/sitemap_index.xml"}, sitemaps)
+
+	sitemaps, err = Sitemaps("http://example.com/products", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://example.com/sitemap.xml"}, sitemaps)
+}
+
+func TestSitemapURLModified(t *testing.T) {
+	for lastMod, expected := range map[string]time.Time{
+		"2019-04-01":                time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+		"2019-04-01T10:30+02:00":    time.Date(2019, 4, 1, 8, 30, 0, 0, time.UTC),
+		"2019-04-01T10:30:15Z":      time.Date(2019, 4, 1, 10, 30, 15, 0, time.UTC),
+		" 2019-04-01T10:30:15.5Z\n": time.Date(2019, 4, 1, 10, 30, 15, 5e8, time.UTC),
+	} {
+		modified, ok := SitemapURL{LastMod: lastMod}.Modified()
+		assert.True(t, ok, lastMod)
+		assert.True(t, expected.Equal(modified), lastMod)
+	}
+	_, ok := SitemapURL{LastMod: "yesterday"}.Modified()
+	assert.False(t, ok)
+}
@@ -0,0 +1,173 @@
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mafredri/cdp/protocol/security"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//TLSProfile describes TLS settings of the fetch. Named profiles are read from TLS_PROFILES_FILE of fetch.d and selected by Request.TLSProfile. Requests without profile use the default one built from TLS_* settings of fetch.d.
+type TLSProfile struct {
+	//CAFiles lists PEM files with root certificates trusted in addition to the system ones.
+	CAFiles []string `json:"caFiles,omitempty"`
+	//CertFile and KeyFile are PEM files with client certificate and its private key sent to servers requiring mutual TLS.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	//MinVersion is the minimum TLS version: "1.0", "1.1", "1.2" or "1.3".
+	MinVersion string `json:"minVersion,omitempty"`
+	//InsecureSkipVerify disables verification of server certificates. It is intended for staging hosts only.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+//tlsSettings is a loaded TLS profile.
+type tlsSettings struct {
+	name    string
+	profile TLSProfile
+	config  *tls.Config
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+//config builds tls.Config of the profile.
+func (p TLSProfile) config() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify}
+	if p.MinVersion != "" {
+		v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(p.MinVersion), "tls")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %s", p.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+	if len(p.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range p.CAFiles {
+			pem, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", path)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	if p.CertFile != "" || p.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+//browserOnly returns true if the profile has no settings Chrome is unable to apply per fetch. Chrome trusts its own certificate store and selects client certificates by itself.
+func (p TLSProfile) browserOnly() bool {
+	return len(p.CAFiles) == 0 && p.CertFile == "" && p.KeyFile == "" && p.MinVersion == ""
+}
+
+var (
+	tlsProfilesOnce sync.Once
+	tlsProfiles     map[string]*tlsSettings
+	tlsProfilesErr  error
+)
+
+//defaultTLSProfile returns TLS profile built from TLS_CA_FILES, TLS_CERT_FILE, TLS_KEY_FILE, TLS_MIN_VERSION and TLS_INSECURE settings.
+func defaultTLSProfile() TLSProfile {
+	return TLSProfile{
+		CAFiles:            viper.GetStringSlice("TLS_CA_FILES"),
+		CertFile:           viper.GetString("TLS_CERT_FILE"),
+		KeyFile:            viper.GetString("TLS_KEY_FILE"),
+		MinVersion:         viper.GetString("TLS_MIN_VERSION"),
+		InsecureSkipVerify: viper.GetBool("TLS_INSECURE"),
+	}
+}
+
+//loadTLSProfiles loads default TLS profile and named profiles from JSON file mapping profile names to TLSProfile objects.
+func loadTLSProfiles(path string) (map[string]*tlsSettings, error) {
+	profiles := map[string]TLSProfile{}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(data, &profiles); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	if _, ok := profiles[""]; !ok {
+		profiles[""] = defaultTLSProfile()
+	}
+	settings := map[string]*tlsSettings{}
+	for name, p := range profiles {
+		cfg, err := p.config()
+		if err != nil {
+			return nil, fmt.Errorf("TLS profile %q: %s", name, err)
+		}
+		settings[name] = &tlsSettings{name: name, profile: p, config: cfg}
+	}
+	return settings, nil
+}
+
+//getTLSSettings returns TLS profile with the given name. Empty name stands for the default profile.
+func getTLSSettings(name string) (*tlsSettings, error) {
+	tlsProfilesOnce.Do(func() {
+		tlsProfiles, tlsProfilesErr = loadTLSProfiles(viper.GetString("TLS_PROFILES_FILE"))
+		if tlsProfilesErr != nil {
+			logger.Error("Failed to load TLS profiles", zap.Error(tlsProfilesErr))
+		}
+	})
+	if tlsProfilesErr != nil {
+		return nil, errs.StatusError{Code: http.StatusInternalServerError, Err: tlsProfilesErr}
+	}
+	s, ok := tlsProfiles[name]
+	if !ok {
+		return nil, errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unknown TLS profile %s", name)}
+	}
+	return s, nil
+}
+
+//setTLS selects the transport of TLS profile for requests of base fetcher.
+func (bf *BaseFetcher) setTLS(s *tlsSettings) {
+	if s != nil {
+		bf.tls = s
+	}
+}
+
+//setTLS keeps TLS profile to be applied to the Chrome tab.
+func (f *ChromeFetcher) setTLS(s *tlsSettings) {
+	f.tls = s
+}
+
+//applyTLS makes the Chrome tab ignore certificate errors for insecure TLS profile. Root CAs, client certificates and minimum TLS version are not configurable per tab, Chrome uses its own certificate store and settings for them.
+func (f *ChromeFetcher) applyTLS(ctx context.Context) error {
+	if f.tls == nil {
+		return nil
+	}
+	if f.tls.name != "" && !f.tls.profile.browserOnly() {
+		logger.Warn("Chrome fetcher applies insecureSkipVerify of TLS profile only", zap.String("profile", f.tls.name))
+	}
+	if !f.tls.profile.InsecureSkipVerify {
+		return nil
+	}
+	return f.cdpClient.Security.SetIgnoreCertificateErrors(ctx, security.NewSetIgnoreCertificateErrorsArgs(true))
+}
@@ -0,0 +1,59 @@
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSProfiles(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600))
+	profilesFile := filepath.Join(dir, "profiles.json")
+	assert.NoError(t, ioutil.WriteFile(profilesFile, []byte(`{
+		"partner": {"caFiles": ["`+filepath.ToSlash(caFile)+`"], "minVersion": "1.2"},
+		"staging": {"insecureSkipVerify": true}
+	}`), 0600))
+
+	profiles, err := loadTLSProfiles(profilesFile)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), profiles["partner"].config.MinVersion)
+	assert.NotNil(t, profiles[""])
+
+	fetch := func(s *tlsSettings) error {
+		f := newBaseFetcher()
+		f.setTLS(s)
+		_, err := f.Fetch(context.Background(), Request{URL: ts.URL})
+		return err
+	}
+	assert.Error(t, fetch(profiles[""]))
+	assert.NoError(t, fetch(profiles["partner"]))
+	assert.NoError(t, fetch(profiles["staging"]))
+
+	for _, p := range []TLSProfile{
+		{MinVersion: "2.0"},
+		{CAFiles: []string{profilesFile}},
+		{CAFiles: []string{filepath.Join(dir, "missing.pem")}},
+		{CertFile: caFile},
+	} {
+		_, err := p.config()
+		assert.Error(t, err)
+	}
+	assert.True(t, TLSProfile{InsecureSkipVerify: true}.browserOnly())
+	assert.False(t, TLSProfile{CAFiles: []string{caFile}}.browserOnly())
+}
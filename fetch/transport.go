@@ -2,10 +2,15 @@ package fetch
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-kit/kit/endpoint"
 	httptransport "github.com/go-kit/kit/transport/http"
@@ -20,14 +25,37 @@ func newHttpHandler(ctx context.Context, endpoint endpoints) http.Handler {
 	options := []httptransport.ServerOption{
 		//httptransport.ServerErrorLogger(logger),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(httptransport.PopulateRequestContext),
 	}
 	r.Methods("GET").Path("/ping").HandlerFunc(healthCheckHandler)
+	r.Methods("GET").Path("/cookies").HandlerFunc(listCookiesHandler)
+	r.Methods("POST").Path("/cookies").HandlerFunc(importCookiesHandler)
+	r.Methods("DELETE").Path("/cookies").HandlerFunc(deleteCookiesHandler)
+	r.Methods("GET").Path("/cookies/export").HandlerFunc(exportCookiesHandler)
 	r.Methods("POST").Path("/fetch").Handler(httptransport.NewServer(
 		endpoint.fetchEndpoint,
 		decodeRequest,
 		encodeFetcherContent,
 		options...,
 	))
+	r.Methods("POST").Path("/screenshot").Handler(httptransport.NewServer(
+		makeCaptureEndpoint(endpoint.fetchEndpoint, Screenshot),
+		decodeRequest,
+		encodeCapture,
+		options...,
+	))
+	r.Methods("POST").Path("/pdf").Handler(httptransport.NewServer(
+		makeCaptureEndpoint(endpoint.fetchEndpoint, PDF),
+		decodeRequest,
+		encodeCapture,
+		options...,
+	))
+	r.Methods("POST").Path("/har").Handler(httptransport.NewServer(
+		makeHAREndpoint(endpoint.fetchEndpoint),
+		decodeRequest,
+		encodeHAR,
+		options...,
+	))
 	return r
 }
 
@@ -40,15 +68,51 @@ func decodeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	return request, nil
 }
 
-//EncodeFetcherContent encodes HTML Content returned by fetcher
+//responseEnvelope is a JSON representation of Response returned to clients accepting application/json.
+type responseEnvelope struct {
+	*Response
+	//Body is the document content.
+	Body string `json:"body"`
+	//Encoding is set to "base64" for binary documents.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+//EncodeFetcherContent encodes HTML Content returned by fetcher. Response metadata is passed in X-Fetch-* headers. Clients sending "Accept: application/json" header receive JSON envelope with metadata and content instead.
 func encodeFetcherContent(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	fetcherContent, ok := response.(io.ReadCloser)
+	fetcherContent, ok := response.(*Response)
 	if !ok {
 		e := errors.New(http.StatusText(http.StatusBadGateway))
 		encodeError(ctx, e, w)
 		return nil
 	}
 	w.Header().Set("Access-Control-Allow-Origin", "*")
+	accept, _ := ctx.Value(httptransport.ContextKeyRequestAccept).(string)
+	if strings.Contains(accept, "application/json") {
+		body, err := ioutil.ReadAll(fetcherContent)
+		if err != nil {
+			encodeError(ctx, err, w)
+			return nil
+		}
+		envelope := responseEnvelope{Response: fetcherContent, Body: string(body)}
+		if IsBinary(fetcherContent.Kind) {
+			envelope.Body = base64.StdEncoding.EncodeToString(body)
+			envelope.Encoding = "base64"
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		return json.NewEncoder(w).Encode(envelope)
+	}
+	w.Header().Set("X-Fetch-Status", strconv.Itoa(fetcherContent.StatusCode))
+	w.Header().Set("X-Fetch-Url", fetcherContent.URL)
+	for _, redirect := range fetcherContent.Redirects {
+		w.Header().Add("X-Fetch-Redirect", redirect)
+	}
+	w.Header().Set("X-Fetch-Content-Type", fetcherContent.ContentType)
+	w.Header().Set("X-Fetch-Charset", fetcherContent.Charset)
+	w.Header().Set("X-Fetch-Kind", fetcherContent.Kind)
+	w.Header().Set("X-Fetch-Time", fetcherContent.Timings.Total.String())
+	if fetcherContent.Truncated {
+		w.Header().Set("X-Fetch-Truncated", "true")
+	}
 	_, err := io.Copy(w, fetcherContent)
 	if err != nil {
 		encodeError(ctx, err, w)
@@ -57,6 +121,33 @@ func encodeFetcherContent(ctx context.Context, w http.ResponseWriter, response i
 	return nil
 }
 
+//encodeCapture writes screenshot or PDF returned by capture endpoint.
+func encodeCapture(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	capture, ok := response.(CaptureData)
+	if !ok {
+		e := errors.New(http.StatusText(http.StatusBadGateway))
+		encodeError(ctx, e, w)
+		return nil
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", capture.ContentType)
+	_, err := w.Write(capture.Data)
+	return err
+}
+
+//encodeHAR writes HAR document returned by HAR endpoint. It may be saved to .har file and imported into browser devtools.
+func encodeHAR(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	har, ok := response.(*HAR)
+	if !ok {
+		e := errors.New(http.StatusText(http.StatusBadGateway))
+		encodeError(ctx, e, w)
+		return nil
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(har)
+}
+
 // encodeError encodes erroneous responses and writes http status header.
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -83,7 +174,50 @@ type endpoints struct {
 // MakeFetchEndpoint creates Fetch Endpoint
 func makeFetchEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		return svc.Fetch(request.(Request))
+		return svc.Fetch(ctx, request.(Request))
+	}
+}
+
+//makeCaptureEndpoint creates endpoint returning the first capture of the given type taken by Chrome fetcher. If Request contains no captures of the type, full page screenshot or PDF is taken.
+func makeCaptureEndpoint(fetchEndpoint endpoint.Endpoint, captureType string) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(Request)
+		found := false
+		for _, c := range req.Captures {
+			if strings.EqualFold(c.Type, captureType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			req.Captures = append(req.Captures, Capture{Type: captureType, FullPage: true})
+		}
+		resp, err := fetchEndpoint(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resp.(*Response).Captures {
+			if c.Type == captureType {
+				return c, nil
+			}
+		}
+		return nil, errs.StatusError{Code: http.StatusBadGateway, Err: fmt.Errorf("%s is not captured", captureType)}
+	}
+}
+
+//makeHAREndpoint creates endpoint returning HAR document of Chrome network session instead of the page content.
+func makeHAREndpoint(fetchEndpoint endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(Request)
+		req.HAR = true
+		resp, err := fetchEndpoint(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.(*Response).HAR == nil {
+			return nil, errs.StatusError{Code: http.StatusBadGateway, Err: errors.New("HAR is not recorded")}
+		}
+		return resp.(*Response).HAR, nil
 	}
 }
 
@@ -1,11 +1,14 @@
 package fetch
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -42,3 +45,57 @@ func TestQuery(t *testing.T) {
 		t.Errorf("query did not hit")
 	}
 }
+
+func TestEncodeFetcherContent(t *testing.T) {
+	resp := func() *Response {
+		return &Response{
+			Body:        ioutil.NopCloser(strings.NewReader("<html></html>")),
+			StatusCode:  http.StatusOK,
+			Header:      http.Header{"Content-Type": []string{"text/html"}},
+			URL:         "http://example.com/final",
+			Redirects:   []string{"http://example.com/start"},
+			ContentType: "text/html",
+			Charset:     "utf-8",
+		}
+	}
+	//metadata is passed in headers
+	w := httptest.NewRecorder()
+	assert.NoError(t, encodeFetcherContent(context.Background(), w, resp()))
+	assert.Equal(t, "200", w.Header().Get("X-Fetch-Status"))
+	assert.Equal(t, "http://example.com/final", w.Header().Get("X-Fetch-Url"))
+	assert.Equal(t, []string{"http://example.com/start"}, w.Header()["X-Fetch-Redirect"])
+	assert.Equal(t, "<html></html>", w.Body.String())
+
+	//JSON envelope is decoded by HTTP client
+	w = httptest.NewRecorder()
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAccept, "application/json")
+	assert.NoError(t, encodeFetcherContent(ctx, w, resp()))
+	decoded, err := decodeFetcherContent(context.Background(), w.Result())
+	assert.NoError(t, err)
+	r := decoded.(*Response)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, "http://example.com/final", r.URL)
+	assert.Equal(t, []string{"http://example.com/start"}, r.Redirects)
+	assert.Equal(t, "text/html", r.Header.Get("Content-Type"))
+	body, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "<html></html>", string(body))
+}
+
+func TestEncodeBinaryContent(t *testing.T) {
+	pdf := "%PDF-1.4\n\xe2\xe3\xcf\xd3\n"
+	w := httptest.NewRecorder()
+	ctx := context.WithValue(context.Background(), httptransport.ContextKeyRequestAccept, "application/json")
+	assert.NoError(t, encodeFetcherContent(ctx, w, &Response{
+		Body:        ioutil.NopCloser(strings.NewReader(pdf)),
+		StatusCode:  http.StatusOK,
+		ContentType: "application/pdf",
+		Kind:        KindPDF,
+	}))
+	assert.Contains(t, w.Body.String(), `"encoding":"base64"`)
+	decoded, err := decodeFetcherContent(context.Background(), w.Result())
+	assert.NoError(t, err)
+	r := decoded.(*Response)
+	assert.Equal(t, KindPDF, r.Kind)
+	body, _ := ioutil.ReadAll(r)
+	assert.Equal(t, pdf, string(body))
+}
@@ -9,14 +9,14 @@ import (
 	"golang.org/x/text/transform"
 )
 
-//readerToUtf8Encoding detects encoding of fetched document and convert it to utf8. It is used by Base Fetcher only. 
-func readerToUtf8Encoding(rc io.ReadCloser) (out io.ReadCloser, name string, certain bool, err error) {
+//readerToUtf8Encoding detects encoding of fetched document and convert it to utf8. Charset of contentType is taken into account if present. It is used by Base Fetcher only.
+func readerToUtf8Encoding(rc io.ReadCloser, contentType string) (out io.ReadCloser, name string, certain bool, err error) {
 
 	b, err := ioutil.ReadAll(rc)
 	if err != nil {
 		return
 	}
-	e, name, certain := charset.DetermineEncoding(b, "")
+	e, name, certain := charset.DetermineEncoding(b, contentType)
 	if err != nil {
 		return
 	}
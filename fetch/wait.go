@@ -0,0 +1,280 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/mafredri/cdp/protocol/runtime"
+	"github.com/mafredri/cdp/rpcc"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//Page lifecycle events Chrome fetcher may wait for after navigation.
+const (
+	//WaitLoad waits for the load event. It is the default event.
+	WaitLoad = "load"
+	//WaitDOMContentLoaded waits for DOMContentLoaded event.
+	WaitDOMContentLoaded = "domcontentloaded"
+)
+
+//pollInterval is the interval of checking selector and JavaScript expression conditions.
+var pollInterval = 100 * time.Millisecond
+
+//Wait describes conditions Chrome fetcher waits for after navigation before the page content is taken. Conditions are awaited in the following order: Event, NetworkIdle, Selector, Expression, Delay.
+type Wait struct {
+	//Event is the page lifecycle event: "load" or "domcontentloaded". Defaults to "load".
+	Event string `json:"event,omitempty"`
+	//NetworkIdle waits until there are no network requests in flight for the given number of milliseconds.
+	NetworkIdle int `json:"networkIdle,omitempty"`
+	//Selector waits for the element matching CSS selector to be attached to the DOM.
+	Selector string `json:"selector,omitempty"`
+	//Visible requires the element matching Selector to be visible.
+	Visible bool `json:"visible,omitempty"`
+	//Expression waits until JavaScript expression evaluates to true.
+	Expression string `json:"expression,omitempty"`
+	//Delay is a fixed delay in milliseconds applied after all the other conditions are met.
+	Delay int `json:"delay,omitempty"`
+}
+
+//defaultWait is used for requests without wait conditions.
+var defaultWait = Wait{Event: WaitLoad, Delay: 750}
+
+//validate checks wait conditions and fills default values.
+func (w *Wait) validate() error {
+	w.Event = strings.ToLower(w.Event)
+	switch w.Event {
+	case "":
+		w.Event = WaitLoad
+	case WaitLoad, WaitDOMContentLoaded:
+	default:
+		return errs.StatusError{Code: http.StatusBadRequest, Err: fmt.Errorf("unsupported wait event %s", w.Event)}
+	}
+	if w.NetworkIdle < 0 || w.Delay < 0 {
+		return errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("wait durations must not be negative")}
+	}
+	if w.Visible && w.Selector == "" {
+		return errs.StatusError{Code: http.StatusBadRequest, Err: errors.New("visible wait condition requires selector")}
+	}
+	return nil
+}
+
+//getWait returns wait conditions of the request.
+func (req Request) getWait() (Wait, error) {
+	if req.Wait == nil {
+		return defaultWait, nil
+	}
+	w := *req.Wait
+	return w, w.validate()
+}
+
+//timeout returns overall timeout of the request. Request.Timeout overrides FETCH_TIMEOUT setting.
+func (req Request) timeout() time.Duration {
+	if req.Timeout > 0 {
+		return time.Duration(req.Timeout) * time.Second
+	}
+	if t := viper.GetInt("FETCH_TIMEOUT"); t > 0 {
+		return time.Duration(t) * time.Second
+	}
+	return 60 * time.Second
+}
+
+//networkActivity tracks requests of the page in flight.
+type networkActivity struct {
+	requestWillBeSent network.RequestWillBeSentClient
+	loadingFinished   network.LoadingFinishedClient
+	loadingFailed     network.LoadingFailedClient
+	//inflight maps requests in flight to their URLs.
+	inflight map[network.RequestID]string
+	//frameID and loaderID identify navigation of the main frame, document is the request of the main frame document. Only its failure fails the fetch.
+	frameID  page.FrameID
+	loaderID network.LoaderID
+	document network.RequestID
+}
+
+func (f *ChromeFetcher) newNetworkActivity(ctx context.Context) (*networkActivity, error) {
+	n := &networkActivity{inflight: map[network.RequestID]string{}}
+	var err error
+	if n.requestWillBeSent, err = f.cdpClient.Network.RequestWillBeSent(ctx); err != nil {
+		return nil, err
+	}
+	if n.loadingFinished, err = f.cdpClient.Network.LoadingFinished(ctx); err != nil {
+		n.requestWillBeSent.Close()
+		return nil, err
+	}
+	if n.loadingFailed, err = f.cdpClient.Network.LoadingFailed(ctx); err != nil {
+		n.requestWillBeSent.Close()
+		n.loadingFinished.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *networkActivity) Close() {
+	n.requestWillBeSent.Close()
+	n.loadingFinished.Close()
+	n.loadingFailed.Close()
+}
+
+//navigated sets the main frame navigation. Chrome uses loader ID as the ID of the navigation request.
+func (n *networkActivity) navigated(reply *page.NavigateReply) {
+	n.frameID = reply.FrameID
+	if reply.LoaderID != nil {
+		n.loaderID = *reply.LoaderID
+		n.document = network.RequestID(*reply.LoaderID)
+	}
+}
+
+//wait processes network events until event is ready. If event is nil, it waits until there are no requests in flight for idle duration. Failed load of the main frame document is returned as an error. Failures of other requests including frame documents, e.g. blocked ad frames, are logged and recorded by HAR only.
+func (n *networkActivity) wait(ctx context.Context, event <-chan struct{}, idle time.Duration) error {
+	var idleC <-chan time.Time
+	for {
+		if event == nil && idleC == nil && len(n.inflight) == 0 {
+			idleC = time.After(idle)
+		}
+		select {
+		case <-event:
+			return nil
+		case <-idleC:
+			return nil
+		case <-n.requestWillBeSent.Ready():
+			ev, err := n.requestWillBeSent.Recv()
+			if err != nil {
+				return err
+			}
+			n.inflight[ev.RequestID] = ev.Request.URL
+			if ev.Type == network.ResourceTypeDocument && ev.FrameID != nil && *ev.FrameID == n.frameID && ev.LoaderID == n.loaderID {
+				n.document = ev.RequestID
+			}
+			idleC = nil
+		case <-n.loadingFinished.Ready():
+			ev, err := n.loadingFinished.Recv()
+			if err != nil {
+				return err
+			}
+			delete(n.inflight, ev.RequestID)
+		case <-n.loadingFailed.Ready():
+			ev, err := n.loadingFailed.Recv()
+			if err != nil {
+				return err
+			}
+			url := n.inflight[ev.RequestID]
+			delete(n.inflight, ev.RequestID)
+			canceled := ev.Canceled != nil && *ev.Canceled
+			if canceled || ev.Type != network.ResourceTypeDocument {
+				continue
+			}
+			if n.document != "" && ev.RequestID == n.document {
+				return errs.StatusError{Code: http.StatusBadRequest, Err: errors.New(ev.ErrorText)}
+			}
+			logger.Info("Failed to load frame document", zap.String("URL", url), zap.String("error", ev.ErrorText))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//navigate to the URL and wait for conditions. ctx deadline limits the time of waiting. An error is returned if timeout happens before conditions are met.
+func (f *ChromeFetcher) navigate(ctx context.Context, url string, wait Wait) error {
+	activity, err := f.newNetworkActivity(ctx)
+	if err != nil {
+		return err
+	}
+	defer activity.Close()
+
+	var event rpcc.Stream
+	if wait.Event == WaitDOMContentLoaded {
+		event, err = f.cdpClient.Page.DOMContentEventFired(ctx)
+	} else {
+		event, err = f.cdpClient.Page.LoadEventFired(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	defer event.Close()
+
+	reply, err := f.cdpClient.Page.Navigate(ctx, page.NewNavigateArgs(url))
+	if err != nil {
+		return waitError(ctx, "navigation", err)
+	}
+	activity.navigated(reply)
+	if err = activity.wait(ctx, event.Ready(), 0); err != nil {
+		return waitError(ctx, wait.Event+" event", err)
+	}
+	if wait.NetworkIdle > 0 {
+		if err = activity.wait(ctx, nil, time.Duration(wait.NetworkIdle)*time.Millisecond); err != nil {
+			return waitError(ctx, "network idle", err)
+		}
+	}
+	if wait.Selector != "" {
+		if err = f.waitForExpression(ctx, selectorExpression(wait.Selector, wait.Visible)); err != nil {
+			return waitError(ctx, "selector "+wait.Selector, err)
+		}
+	}
+	if wait.Expression != "" {
+		if err = f.waitForExpression(ctx, wait.Expression); err != nil {
+			return waitError(ctx, "expression "+wait.Expression, err)
+		}
+	}
+	if wait.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(wait.Delay) * time.Millisecond):
+		case <-ctx.Done():
+			return waitError(ctx, "delay", ctx.Err())
+		}
+	}
+	return nil
+}
+
+//waitForExpression polls JavaScript expression until it evaluates to true. Exceptions thrown by the expression are ignored as the page may be not ready yet.
+func (f *ChromeFetcher) waitForExpression(ctx context.Context, expression string) error {
+	args := runtime.NewEvaluateArgs(fmt.Sprintf("!!(%s)", expression)).SetReturnByValue(true)
+	for {
+		reply, err := f.cdpClient.Runtime.Evaluate(ctx, args)
+		if err != nil {
+			return err
+		}
+		if reply.ExceptionDetails == nil {
+			ok := false
+			if json.Unmarshal(reply.Result.Value, &ok) == nil && ok {
+				return nil
+			}
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//selectorExpression returns JavaScript expression checking the element matching selector is attached to the DOM or visible.
+func selectorExpression(selector string, visible bool) string {
+	sel, _ := json.Marshal(selector)
+	if !visible {
+		return fmt.Sprintf("document.querySelector(%s) !== null", sel)
+	}
+	return fmt.Sprintf(`(function() {
+		var el = document.querySelector(%s);
+		if (!el) return false;
+		var style = window.getComputedStyle(el);
+		var r = el.getBoundingClientRect();
+		return style.visibility !== 'hidden' && style.display !== 'none' && r.width > 0 && r.height > 0;
+	})()`, sel)
+}
+
+//waitError reports exceeded deadline as 504 Gateway Timeout.
+func waitError(ctx context.Context, condition string, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errs.StatusError{Code: http.StatusGatewayTimeout, Err: fmt.Errorf("timeout waiting for %s", condition)}
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mafredri/cdp/protocol/network"
+	"github.com/mafredri/cdp/protocol/page"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestWait(t *testing.T) {
+	w, err := Request{}.getWait()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultWait, w)
+
+	w, err = Request{Wait: &Wait{Selector: ".price", Visible: true, NetworkIdle: 500}}.getWait()
+	assert.NoError(t, err)
+	assert.Equal(t, Wait{Event: WaitLoad, Selector: ".price", Visible: true, NetworkIdle: 500}, w)
+
+	w, err = Request{Wait: &Wait{Event: "DOMContentLoaded"}}.getWait()
+	assert.NoError(t, err)
+	assert.Equal(t, WaitDOMContentLoaded, w.Event)
+
+	for _, wait := range []Wait{{Event: "idle"}, {Delay: -1}, {Visible: true}} {
+		_, err = Request{Wait: &wait}.getWait()
+		assert.Equal(t, http.StatusBadRequest, err.(errs.StatusError).Code, wait)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	viper.Set("FETCH_TIMEOUT", 20)
+	defer viper.Set("FETCH_TIMEOUT", 60)
+	assert.Equal(t, 20*time.Second, Request{}.timeout())
+	assert.Equal(t, 5*time.Second, Request{Timeout: 5}.timeout())
+}
+
+//networkEvents replays events of Chrome network domain.
+type networkEvents struct {
+	sent     []*network.RequestWillBeSentReply
+	finished []*network.LoadingFinishedReply
+	failed   []*network.LoadingFailedReply
+}
+
+type requestWillBeSentStream struct{ *networkEvents }
+
+func (s requestWillBeSentStream) Ready() <-chan struct{}      { return pending(len(s.sent)) }
+func (s requestWillBeSentStream) RecvMsg(m interface{}) error { return nil }
+func (s requestWillBeSentStream) Close() error                { return nil }
+func (s requestWillBeSentStream) Recv() (*network.RequestWillBeSentReply, error) {
+	ev := s.sent[0]
+	s.sent = s.sent[1:]
+	return ev, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type loadingFinishedStream struct{ *networkEvents }
+
+//requests are finished and failed once they are sent
+func (s loadingFinishedStream) Ready() <-chan struct{} {
+	return pending(len(s.finished) * btoi(len(s.sent) == 0))
+}
+func (s loadingFinishedStream) RecvMsg(m interface{}) error { return nil }
+func (s loadingFinishedStream) Close() error                { return nil }
+func (s loadingFinishedStream) Recv() (*network.LoadingFinishedReply, error) {
+	ev := s.finished[0]
+	s.finished = s.finished[1:]
+	return ev, nil
+}
+
+type loadingFailedStream struct{ *networkEvents }
+
+func (s loadingFailedStream) Ready() <-chan struct{} {
+	return pending(len(s.failed) * btoi(len(s.sent) == 0))
+}
+func (s loadingFailedStream) RecvMsg(m interface{}) error { return nil }
+func (s loadingFailedStream) Close() error                { return nil }
+func (s loadingFailedStream) Recv() (*network.LoadingFailedReply, error) {
+	ev := s.failed[0]
+	s.failed = s.failed[1:]
+	return ev, nil
+}
+
+func TestNetworkActivityFailures(t *testing.T) {
+	mainFrame, adFrame := page.FrameID("main"), page.FrameID("ad")
+	loader := network.LoaderID("L1")
+	activity := func(failed ...*network.LoadingFailedReply) *networkActivity {
+		events := &networkEvents{
+			sent: []*network.RequestWillBeSentReply{
+				{RequestID: "L1", LoaderID: loader, Type: network.ResourceTypeDocument, FrameID: &mainFrame, Request: network.Request{URL: "http://example.com"}},
+				{RequestID: "F1", LoaderID: "L2", Type: network.ResourceTypeDocument, FrameID: &adFrame, Request: network.Request{URL: "http://ads.com/frame"}},
+			},
+			failed: failed,
+		}
+		n := &networkActivity{
+			requestWillBeSent: requestWillBeSentStream{events},
+			loadingFinished:   loadingFinishedStream{events},
+			loadingFailed:     loadingFailedStream{events},
+			inflight:          map[network.RequestID]string{},
+		}
+		n.navigated(&page.NavigateReply{FrameID: mainFrame, LoaderID: &loader})
+		return n
+	}
+	//blocked frames do not fail the fetch
+	n := activity(
+		&network.LoadingFailedReply{RequestID: "F1", Type: network.ResourceTypeDocument, ErrorText: "net::ERR_BLOCKED_BY_CLIENT"},
+		&network.LoadingFailedReply{RequestID: "L1", Type: network.ResourceTypeDocument, ErrorText: "net::ERR_ABORTED", Canceled: new(bool)},
+	)
+	*n.loadingFailed.(loadingFailedStream).failed[1].Canceled = true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, n.wait(ctx, nil, 10*time.Millisecond))
+
+	n = activity(&network.LoadingFailedReply{RequestID: "L1", Type: network.ResourceTypeDocument, ErrorText: "net::ERR_NAME_NOT_RESOLVED"})
+	err := n.wait(ctx, nil, time.Second)
+	assert.EqualError(t, err, "Status: 400. net::ERR_NAME_NOT_RESOLVED")
+}
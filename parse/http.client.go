@@ -81,8 +81,7 @@ func copyURL(base *url.URL, path string) *url.URL {
 }
 
 // Parse method is used for sending payload requests to parse service.
-func (e Endpoints) Parse(p scrape.Payload) (io.ReadCloser, error) {
-	ctx := context.Background()
+func (e Endpoints) Parse(ctx context.Context, p scrape.Payload) (io.ReadCloser, error) {
 	resp, err := e.ParseEndpoint(ctx, p)
 	if err != nil {
 		return nil, err
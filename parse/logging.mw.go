@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -23,9 +24,9 @@ type loggingMiddleware struct {
 }
 
 // Logging Parse Service
-func (mw loggingMiddleware) Parse(payload scrape.Payload) (output io.ReadCloser, err error) {
+func (mw loggingMiddleware) Parse(ctx context.Context, payload scrape.Payload) (output io.ReadCloser, err error) {
 	defer func(begin time.Time) {
-		output, err = mw.Service.Parse(payload)
+		output, err = mw.Service.Parse(ctx, payload)
 		url := payload.Request.URL
 		if err != nil {
 			mw.logger.Info("Parse",
@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -9,12 +10,12 @@ import (
 )
 
 // Implement service functions and add label method for our metrics
-func (mw metricsMiddleware) Parse(payload scrape.Payload) (output io.ReadCloser, err error) {
+func (mw metricsMiddleware) Parse(ctx context.Context, payload scrape.Payload) (output io.ReadCloser, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "Parse"}
 		mw.requestCount.With(lvs...).Add(1)
 		mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
-		output, err = mw.Service.Parse(payload)
+		output, err = mw.Service.Parse(ctx, payload)
 	}(time.Now())
 	return
 }
@@ -9,7 +9,7 @@ import (
 
 // Service defines Parse service interface
 type Service interface {
-	Parse(scrape.Payload) (io.ReadCloser, error)
+	Parse(context.Context, scrape.Payload) (io.ReadCloser, error)
 }
 
 // ParseService implements service with empty struct
@@ -19,10 +19,10 @@ type ParseService struct {
 // ServiceMiddleware defines a middleware for a Parse service
 type ServiceMiddleware func(Service) Service
 
-//Parse service processes fetched page following the rules from Payload.
-func (ps ParseService) Parse(p scrape.Payload) (io.ReadCloser, error) {
+//Parse service processes fetched page following the rules from Payload. Parsing along with in-flight fetches is cancelled when ctx is done.
+func (ps ParseService) Parse(ctx context.Context, p scrape.Payload) (io.ReadCloser, error) {
 	task := scrape.NewTask()
-	r, err := task.Parse(context.Background(), p)
+	r, err := task.Parse(ctx, p)
 	if err != nil {
 		return nil, err
 	}
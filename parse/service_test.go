@@ -1,6 +1,7 @@
 package parse
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -64,6 +65,8 @@ func init() {
 	viper.Set("CHROME", "http://127.0.0.1:9222")
 	viper.Set("PAYLOAD_POOL_SIZE", 50)
 	viper.Set("PAYLOAD_WORKERS_NUM", 100)
+	//test server disallows everything in robots.txt
+	viper.Set("IGNORE_ROBOTSTXT", true)
 }
 func Test_service(t *testing.T) {
 	//start fetch server
@@ -76,7 +79,7 @@ func Test_service(t *testing.T) {
 	defer fetchServer.Stop()
 	time.Sleep(500 * time.Millisecond)
 	svc := ParseService{}
-	result, err := svc.Parse(payloadBase)
+	result, err := svc.Parse(context.Background(), payloadBase)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 
@@ -91,7 +94,7 @@ func Test_service(t *testing.T) {
 
 	//create HTTPClient to send requests.
 	svc1, _ := NewHTTPClient(parseServerAddr)
-	result, err = svc1.Parse(payloadChrome)
+	result, err = svc1.Parse(context.Background(), payloadChrome)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 
@@ -99,7 +102,7 @@ func Test_service(t *testing.T) {
 	invPayload := scrape.Payload{
 		Name: "invalid payload",
 	}
-	_, err = svc1.Parse(invPayload)
+	_, err = svc1.Parse(context.Background(), invPayload)
 	assert.Error(t, err)
 
 	//Invalid Payload - no fields
@@ -110,7 +113,7 @@ func Test_service(t *testing.T) {
 		},
 	}
 
-	_, err = svc.Parse(invPayload)
+	_, err = svc.Parse(context.Background(), invPayload)
 	assert.Error(t, err)
 
 }
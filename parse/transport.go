@@ -68,7 +68,7 @@ type Endpoints struct {
 // MakeParseEndpoint creates Parse Endpoint
 func MakeParseEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		v, err := svc.Parse(request.(scrape.Payload))
+		v, err := svc.Parse(ctx, request.(scrape.Payload))
 		if err != nil {
 			return nil, err
 		}
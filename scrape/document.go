@@ -0,0 +1,216 @@
+package scrape
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/slotix/dataflowkit/utils"
+)
+
+//Extractors of field values. Extractor is chosen by the kind of fetched document. Field.Target restricts the field to documents processed by the given extractor.
+const (
+	//CSSExtractor processes HTML and text documents with CSS selectors.
+	CSSExtractor = "css"
+	//JSONPathExtractor processes JSON documents with JSONPath expressions.
+	JSONPathExtractor = "jsonpath"
+	//XPathExtractor processes XML documents with XPath expressions.
+	XPathExtractor = "xpath"
+	//PDFExtractor processes text of PDF documents with regular expressions.
+	PDFExtractor = "pdf"
+)
+
+//document is parsed JSON, XML or PDF document. Blocks of the document are found in the same way as blocks of HTML pages: the common part of field selectors selects blocks and the rest of every selector is applied to each block.
+type document interface {
+	//split returns blocks of the document and selectors relative to the blocks.
+	split(selectors []string) ([]interface{}, []string, error)
+	//values returns values of attr of nodes selected within the block.
+	values(block interface{}, selector, attr string) ([]string, error)
+}
+
+//extractorForKind returns extractor processing documents of the kind. Empty string is returned for images and other binary documents. Only response metadata is extracted from them.
+func extractorForKind(kind string) string {
+	switch kind {
+	case fetch.KindJSON:
+		return JSONPathExtractor
+	case fetch.KindXML:
+		return XPathExtractor
+	case fetch.KindPDF:
+		return PDFExtractor
+	case fetch.KindImage, fetch.KindBinary:
+		return ""
+	}
+	return CSSExtractor
+}
+
+//newDocument parses the body with extractor.
+func newDocument(extractor string, body []byte) (document, error) {
+	switch extractor {
+	case JSONPathExtractor:
+		return newJSONDocument(body)
+	case XPathExtractor:
+		return newXMLDocument(body)
+	case PDFExtractor:
+		return newPDFDocument(body)
+	}
+	return nil, fmt.Errorf("unsupported extractor %s", extractor)
+}
+
+//checkSelector compiles selector of the field declaring extractor explicitly.
+func (f *Field) checkSelector() error {
+	switch f.target() {
+	case "", CSSExtractor:
+		return nil
+	case JSONPathExtractor:
+		_, err := parseJSONPath(f.CSSSelector)
+		return err
+	case XPathExtractor:
+		if f.CSSSelector == "" {
+			return nil
+		}
+		_, err := compileXPath(f.CSSSelector)
+		return err
+	case PDFExtractor:
+		_, err := regexp.Compile(f.CSSSelector)
+		return err
+	}
+	return fmt.Errorf("unsupported extractor %s", f.Target)
+}
+
+func (f *Field) target() string {
+	return strings.ToLower(f.Target)
+}
+
+//targets returns true if the field extracts values with extractor. Fields without Target are applied to any document.
+func (f *Field) targets(extractor string) bool {
+	return f.Target == "" || f.target() == extractor
+}
+
+//documentBlocks splits the document into blocks by selectors of fields targeting the extractor.
+func documentBlocks(doc document, extractor string, fields []Field) ([]interface{}, map[string]string, error) {
+	selectors := []string{}
+	for _, f := range fields {
+		if f.responseOnly() || !f.targets(extractor) {
+			continue
+		}
+		if f.CSSSelector == "" && extractor != PDFExtractor {
+			continue
+		}
+		selectors = append(selectors, f.CSSSelector)
+	}
+	blocks, relative, err := doc.split(selectors)
+	if err != nil {
+		return nil, nil, err
+	}
+	relativeSelectors := map[string]string{}
+	for i, sel := range selectors {
+		relativeSelectors[sel] = relative[i]
+	}
+	return blocks, relativeSelectors, nil
+}
+
+//splitDocument parses the response body and returns blocks of the document. Images and other binary documents make up a single block without content.
+func splitDocument(resp *fetch.Response, extractor string, fields []Field) ([]pageBlock, error) {
+	if extractor == "" {
+		return []pageBlock{{response: resp}}, nil
+	}
+	body, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := newDocument(extractor, body)
+	if err != nil {
+		return nil, err
+	}
+	nodes, selectors, err := documentBlocks(doc, extractor, fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("No blocks found")
+	}
+	blocks := make([]pageBlock, len(nodes))
+	for i, node := range nodes {
+		blocks[i] = pageBlock{response: resp, extractor: extractor, doc: doc, node: node, selectors: selectors}
+	}
+	return blocks, nil
+}
+
+//nextDocumentPage extracts the link to the next page from JSON, XML or PDF document with paginator field. The body of the response is restored to be parsed afterwards.
+func (task *Task) nextDocumentPage(resp *fetch.Response, paginator Field, results *map[string]interface{}) error {
+	extractor := extractorForKind(resp.Kind)
+	if extractor == "" {
+		return errs.NotError{Message: "No paginator in binary document."}
+	}
+	body, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	doc, err := newDocument(extractor, body)
+	if err != nil {
+		return err
+	}
+	nodes, selectors, err := documentBlocks(doc, extractor, []Field{paginator})
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errs.NotError{Message: "No paginator found."}
+	}
+	return paginator.extractDocument(pageBlock{extractor: extractor, doc: doc, node: nodes[0], selectors: selectors}, results, task.templateRequest.URL)
+}
+
+//extractDocument extracts values from the block of JSON, XML or PDF document. Values of "href", "src" and "path" attributes are resolved against baseURL.
+func (f *Field) extractDocument(b pageBlock, results *map[string]interface{}, baseURL string) error {
+	if b.doc == nil || !f.targets(b.extractor) {
+		return nil
+	}
+	selector, ok := b.selectors[f.CSSSelector]
+	if !ok {
+		return nil
+	}
+	for _, attr := range f.Attrs {
+		if isResponseAttr(attr) {
+			continue
+		}
+		name := strings.ToLower(attr)
+		if name == "path" {
+			name = "href"
+		}
+		values, err := b.doc.values(b.node, selector, name)
+		if err != nil {
+			return err
+		}
+		for i, value := range values {
+			for _, filter := range f.Filters {
+				filteredValue, err := filter.Apply(value)
+				if err != nil {
+					logger.Sugar().Error(err)
+					continue
+				}
+				value = filteredValue
+			}
+			if name == "href" || name == "src" {
+				if value, err = utils.RelUrl(baseURL, value); err != nil {
+					return err
+				}
+			}
+			values[i] = value
+		}
+		switch len(values) {
+		case 0:
+			return errs.NotError{Message: "No selectors found in current block. Thats OK."}
+		case 1:
+			(*results)[f.Name+"_"+attr] = values[0]
+		default:
+			(*results)[f.Name+"_"+attr] = values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package scrape
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+//extractRecords extracts records from the document in the same way as divide and parse do.
+func extractRecords(t *testing.T, kind, body string, fields []Field) []map[string]interface{} {
+	resp := &fetch.Response{Kind: kind, Body: ioutil.NopCloser(strings.NewReader(body))}
+	blocks, err := splitDocument(resp, extractorForKind(kind), fields)
+	assert.NoError(t, err)
+	records := []map[string]interface{}{}
+	for _, b := range blocks {
+		record := map[string]interface{}{}
+		for _, f := range fields {
+			f.extractDocument(b, &record, "http://example.com/feed")
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{} = map[string]interface{}{
+		"store": map[string]interface{}{
+			"name": "Books",
+			"book": []interface{}{
+				map[string]interface{}{"title": "A", "price": 10.0},
+				map[string]interface{}{"title": "B", "price": 20.0, "author": map[string]interface{}{"price": 1.0}},
+			},
+		},
+	}
+	eval := func(expr string) []interface{} {
+		path, err := parseJSONPath(expr)
+		assert.NoError(t, err, expr)
+		return path.eval(doc)
+	}
+	assert.Equal(t, []interface{}{"Books"}, eval("$.store.name"))
+	assert.Equal(t, []interface{}{"Books"}, eval("$['store']['name']"))
+	assert.Equal(t, []interface{}{"A", "B"}, eval("$.store.book[*].title"))
+	assert.Equal(t, []interface{}{"B"}, eval("$.store.book[-1].title"))
+	assert.Equal(t, []interface{}{10.0, 20.0, 1.0}, eval("$..price"))
+	assert.Equal(t, []interface{}{"A"}, eval("store.book[0].title"))
+	assert.Empty(t, eval("$.store.book[5]"))
+	for _, expr := range []string{"$.", "$[1", "$[a]", "$..", "$.a b[0]x"} {
+		_, err := parseJSONPath(expr)
+		assert.Error(t, err, expr)
+	}
+	path, _ := parseJSONPath("$..book[*]['title']")
+	assert.Equal(t, "@..book[*]['title']", path.String())
+}
+
+func TestJSONDocument(t *testing.T) {
+	body := `{"next":"/api?page=2","items":[{"title":"A","link":"/a","tags":["x","y"]},{"title":"B","link":"http://other.com/b"}]}`
+	fields := []Field{
+		{Name: "title", CSSSelector: "$.items[*].title", Attrs: []string{"text"}},
+		{Name: "link", CSSSelector: "$.items[*].link", Attrs: []string{"href"}},
+		{Name: "tags", CSSSelector: "$.items[*].tags[*]", Attrs: []string{"text"}},
+		{Name: "html", CSSSelector: "div.title", Attrs: []string{"text"}, Target: CSSExtractor},
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"title_text": "A", "link_href": "http://example.com/a", "tags_text": []string{"x", "y"}},
+		{"title_text": "B", "link_href": "http://other.com/b"},
+	}, extractRecords(t, fetch.KindJSON, body, fields))
+
+	//paginator link is taken from JSON document keeping the body for parsing
+	resp := &fetch.Response{Kind: fetch.KindJSON, Body: ioutil.NopCloser(strings.NewReader(body))}
+	paginator := map[string]interface{}{}
+	task := &Task{}
+	task.templateRequest.URL = "http://example.com/api"
+	assert.NoError(t, task.nextDocumentPage(resp, Field{Name: "paginator", CSSSelector: "$.next", Attrs: []string{"href"}}, &paginator))
+	assert.Equal(t, "http://example.com/api?page=2", paginator["paginator_href"])
+	restored, _ := ioutil.ReadAll(resp)
+	assert.Equal(t, body, string(restored))
+}
+
+func TestXPath(t *testing.T) {
+	root, err := parseXML([]byte(`<?xml version="1.0"?>
+<catalog xmlns:g="http://base.google.com/ns/1.0">
+	<book id="1" type="novel"><title>A</title><g:price>10</g:price></book>
+	<book id="2"><title>B</title><author>X</author><g:price>20</g:price></book>
+	<shelf><book id="3" type="novel"><title>C &amp; D</title></book></shelf>
+</catalog>`))
+	assert.NoError(t, err)
+	eval := func(expr string) []string {
+		path, err := compileXPath(expr)
+		assert.NoError(t, err, expr)
+		nodes, values, err := evalXPath(path, root)
+		assert.NoError(t, err, expr)
+		for _, n := range nodes {
+			values = append(values, n.text())
+		}
+		return values
+	}
+	assert.Equal(t, []string{"A", "B"}, eval("/catalog/book/title"))
+	assert.Equal(t, []string{"A", "B", "C & D"}, eval("//book/title"))
+	assert.Equal(t, []string{"1", "2", "3"}, eval("//book/@id"))
+	assert.Equal(t, []string{"A", "C & D"}, eval("//book[@type='novel']/title/text()"))
+	assert.Equal(t, []string{"B"}, eval("//book[author]/title"))
+	assert.Equal(t, []string{"B"}, eval("//book[title='B']/title"))
+	assert.Equal(t, []string{"B"}, eval("/catalog/book[2]/title"))
+	assert.Equal(t, []string{"B", "C & D"}, eval("//book[last()]/title"))
+	assert.Equal(t, []string{"10", "20"}, eval("//g:price"))
+	assert.Equal(t, []string{"2"}, eval("//title[contains(., 'B')]/../@id"))
+	assert.Equal(t, []string{"1", "3"}, eval("//book[@type!='x]']/@id"))
+	assert.Equal(t, []string{"3"}, eval("//book[@type='novel' and not(g:price)]/@id"))
+	assert.Equal(t, []string{"2", "3"}, eval("//book[author or title='C & D']/@id"))
+	assert.Equal(t, []string{"B"}, eval("/catalog/book[position()>1]/title"))
+	assert.Equal(t, []string{"C & D"}, eval("//title[starts-with(., 'C')]"))
+	assert.Equal(t, []string{"1"}, eval("//book[normalize-space(title)='A']/@id"))
+	assert.Equal(t, []string{"3"}, eval("count(//book)"))
+	for _, expr := range []string{"", "//book[1", "//book[@type='novel'", "//book[unknown()]"} {
+		_, err := compileXPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestXMLDocument(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+	<title>Feed</title>
+	<item><title>First</title><link>/first</link><enclosure url="/1.mp3"/></item>
+	<item><title>Second</title><link>http://other.com/second</link></item>
+</channel></rss>`
+	fields := []Field{
+		{Name: "title", CSSSelector: "//item/title", Attrs: []string{"text"}},
+		{Name: "link", CSSSelector: "//item/link", Attrs: []string{"href"}},
+		{Name: "audio", CSSSelector: "//item/enclosure", Attrs: []string{"url"}},
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"title_text": "First", "link_href": "http://example.com/first", "audio_url": "/1.mp3"},
+		{"title_text": "Second", "link_href": "http://other.com/second"},
+	}, extractRecords(t, fetch.KindXML, body, fields))
+}
+
+//buildPDF returns PDF document of the objects numbered from 1 with cross-reference table. The first object is the document catalog.
+func buildPDF(objects ...string) string {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, obj := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.String()
+}
+
+func TestPDFDocument(t *testing.T) {
+	var content bytes.Buffer
+	w := zlib.NewWriter(&content)
+	w.Write([]byte("BT /F1 12 Tf 72 712 Td (Invoice No. 12345) Tj 0 -14 Td [(Total:) -300 (99.50 \\(EUR\\))] TJ ET"))
+	w.Close()
+	//stream end is found by /Length, not by "endstream" keyword shown as text
+	shown := "BT /F1 12 Tf 72 600 Td <4e6f7465> Tj ( endstream ) Tj ET"
+	pdf := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents [5 0 R 6 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(shown), shown),
+	)
+	text, err := pdfText([]byte(pdf))
+	assert.NoError(t, err)
+	assert.Equal(t, "Invoice No. 12345\nTotal: 99.50 (EUR)\nNote endstream", text)
+	_, err = pdfText([]byte("1 0 obj\n<< /Length 10 >>\nstream\n<4142> Tj\nendstream"))
+	assert.Error(t, err)
+
+	//printed by headless Chrome 77 with Page.printToPDF. The text is shown with Type0 Identity-H font mapped to Unicode by ToUnicode CMap.
+	chrome, err := ioutil.ReadFile("../testdata/scrape/chrome_print.pdf")
+	assert.NoError(t, err)
+	text, err = pdfText(chrome)
+	assert.NoError(t, err)
+	lines := strings.Split(text, "\n")
+	assert.Equal(t, 16, len(lines))
+	assert.Equal(t, "ĀāĂăĄąĆćĈĉĊċČčĎď", lines[0])
+	assert.Equal(t, "ǰǱǲǳǴǵǶǷǸǹǺǻǼǽǾǿ", lines[15])
+
+	fields := []Field{
+		{Name: "invoice", CSSSelector: `Invoice No\. (\d+)`, Attrs: []string{"text"}, Target: PDFExtractor},
+		{Name: "text", Attrs: []string{"text"}, Target: PDFExtractor},
+	}
+	assert.Equal(t, []map[string]interface{}{
+		{"invoice_text": "12345", "text_text": "Invoice No. 12345\nTotal: 99.50 (EUR)\nNote endstream"},
+	}, extractRecords(t, fetch.KindPDF, pdf, fields))
+
+	task := &Task{}
+	p := Payload{Format: "json", Fields: fields}
+	assert.NoError(t, task.checkPayload(&p))
+	p.Fields = []Field{{Name: "bad", CSSSelector: "$.items[", Attrs: []string{"text"}, Target: JSONPathExtractor}}
+	assert.Error(t, task.checkPayload(&p))
+	p.Fields = []Field{{Name: "bad", CSSSelector: "a", Attrs: []string{"text"}, Target: "yaml"}}
+	assert.Error(t, task.checkPayload(&p))
+}
@@ -0,0 +1,241 @@
+package scrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//jsonPathToken is a single step of JSONPath expression.
+type jsonPathToken struct {
+	//kind is one of "key", "index", "wildcard" or "descend"
+	kind  string
+	key   string
+	index int
+	//raw is the token as written in the expression.
+	raw string
+}
+
+//jsonPath is a parsed JSONPath expression. The following subset of JSONPath is supported: "$" (root), "@" (current block), ".key", "['key']", "[n]" (negative indexes count from the end), "[*]", ".*" and ".." (recursive descent), e.g. "$.store.book[*].title" or "$..price".
+type jsonPath []jsonPathToken
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimSpace(expr)
+	if strings.HasPrefix(s, "$") || strings.HasPrefix(s, "@") {
+		s = s[1:]
+	}
+	path := jsonPath{}
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			path = append(path, jsonPathToken{kind: "descend", raw: ".."})
+			s = s[2:]
+			if len(s) == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %s", expr)
+			}
+			if s[0] != '[' {
+				name := s[:jsonPathNameEnd(s)]
+				if name == "" {
+					return nil, fmt.Errorf("invalid JSONPath %s", expr)
+				}
+				path = append(path, nameToken(name, name))
+				s = s[len(name):]
+			}
+		case s[0] == '.':
+			name := s[1 : jsonPathNameEnd(s[1:])+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %s", expr)
+			}
+			path = append(path, nameToken(name, "."+name))
+			s = s[len(name)+1:]
+		case s[0] == '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %s: missing ]", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			raw := s[:end+1]
+			switch {
+			case inner == "*":
+				path = append(path, jsonPathToken{kind: "wildcard", raw: raw})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, jsonPathToken{kind: "key", key: inner[1 : len(inner)-1], raw: raw})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %s: unsupported subscript %s", expr, inner)
+				}
+				path = append(path, jsonPathToken{kind: "index", index: i, raw: raw})
+			}
+			s = s[end+1:]
+		default:
+			//keys of relative paths may be written without leading dot
+			if len(path) > 0 {
+				return nil, fmt.Errorf("invalid JSONPath %s", expr)
+			}
+			name := s[:jsonPathNameEnd(s)]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %s", expr)
+			}
+			path = append(path, nameToken(name, "."+name))
+			s = s[len(name):]
+		}
+	}
+	return path, nil
+}
+
+func nameToken(name, raw string) jsonPathToken {
+	if name == "*" {
+		return jsonPathToken{kind: "wildcard", raw: raw}
+	}
+	return jsonPathToken{kind: "key", key: name, raw: raw}
+}
+
+//jsonPathNameEnd returns the length of the key name at the beginning of s.
+func jsonPathNameEnd(s string) int {
+	if i := strings.IndexAny(s, ".["); i >= 0 {
+		return i
+	}
+	return len(s)
+}
+
+//String returns JSONPath expression relative to the current block.
+func (p jsonPath) String() string {
+	s := "@"
+	for _, t := range p {
+		s += t.raw
+	}
+	return s
+}
+
+//eval returns values selected by the path.
+func (p jsonPath) eval(v interface{}) []interface{} {
+	nodes := []interface{}{v}
+	for _, t := range p {
+		next := []interface{}{}
+		for _, n := range nodes {
+			switch t.kind {
+			case "key":
+				if obj, ok := n.(map[string]interface{}); ok {
+					if value, ok := obj[t.key]; ok {
+						next = append(next, value)
+					}
+				}
+			case "index":
+				if arr, ok := n.([]interface{}); ok {
+					i := t.index
+					if i < 0 {
+						i += len(arr)
+					}
+					if i >= 0 && i < len(arr) {
+						next = append(next, arr[i])
+					}
+				}
+			case "wildcard":
+				next = append(next, jsonChildren(n)...)
+			case "descend":
+				next = append(next, jsonDescendants(n)...)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+//jsonChildren returns array elements or object values ordered by keys.
+func jsonChildren(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		return t
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, 0, len(t))
+		for _, k := range keys {
+			children = append(children, t[k])
+		}
+		return children
+	}
+	return nil
+}
+
+//jsonDescendants returns the value itself followed by all nested values.
+func jsonDescendants(v interface{}) []interface{} {
+	nodes := []interface{}{v}
+	for _, c := range jsonChildren(v) {
+		nodes = append(nodes, jsonDescendants(c)...)
+	}
+	return nodes
+}
+
+//jsonDocument is JSON document processed with JSONPath extractor.
+type jsonDocument struct {
+	root interface{}
+}
+
+func newJSONDocument(body []byte) (*jsonDocument, error) {
+	d := &jsonDocument{}
+	if err := json.Unmarshal(body, &d.root); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//split selects blocks with the common part of selectors up to the last wildcard, e.g. "$.items[*]" for "$.items[*].title" and "$.items[*].price".
+func (d *jsonDocument) split(selectors []string) ([]interface{}, []string, error) {
+	paths := make([]jsonPath, len(selectors))
+	var common jsonPath
+	for i, sel := range selectors {
+		path, err := parseJSONPath(sel)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths[i] = path
+		if i == 0 {
+			common = path
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(path) && common[n].raw == path[n].raw {
+			n++
+		}
+		common = common[:n]
+	}
+	prefix := 0
+	for i, t := range common {
+		if t.kind == "wildcard" {
+			prefix = i + 1
+		}
+	}
+	relative := make([]string, len(paths))
+	for i, path := range paths {
+		relative[i] = path[prefix:].String()
+	}
+	if len(paths) == 0 {
+		return []interface{}{d.root}, relative, nil
+	}
+	return paths[0][:prefix].eval(d.root), relative, nil
+}
+
+//values returns values selected within the block. Values are converted to strings, objects and arrays are returned as JSON.
+func (d *jsonDocument) values(block interface{}, selector, attr string) ([]string, error) {
+	path, err := parseJSONPath(selector)
+	if err != nil {
+		return nil, err
+	}
+	values := []string{}
+	for _, v := range path.eval(block) {
+		if _, ok := v.([]interface{}); ok {
+			b, _ := json.Marshal(v)
+			values = append(values, string(b))
+			continue
+		}
+		values = append(values, jsonValues(v, nil)...)
+	}
+	return values, nil
+}
@@ -0,0 +1,154 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"go.uber.org/zap"
+)
+
+//Login describes the step performed before scraping pages which require authentication. Cookies set by the login page are kept in the user session identified by Request.UserToken and sent with all subsequent page, paginator and details requests of the task.
+type Login struct {
+	//Request submits credentials, e.g. FormData posted to the login form or Actions filling it in with Chrome fetcher. UserToken, Headers and UserAgent of the payload request are used if omitted. Auth of the payload request is used if the login page is on the same host.
+	Request fetch.Request `json:"request"`
+	//Selector is a CSS selector of the element present on the page returned after successful login, e.g. ".logout".
+	Selector string `json:"selector,omitempty"`
+	//Cookie is the name of the cookie present in the session after successful login.
+	Cookie string `json:"cookie,omitempty"`
+}
+
+//check validates login step of the payload.
+func (l *Login) check() error {
+	if l.Request.URL == "" {
+		return errors.New("Bad payload: Login has no URL")
+	}
+	if _, err := url.ParseRequestURI(l.Request.URL); err != nil {
+		return fmt.Errorf("Bad payload: Login: %s", err)
+	}
+	return nil
+}
+
+//request returns login request sharing the session and credentials with the payload request. Login responses are never taken from cache.
+func (l *Login) request(template fetch.Request) fetch.Request {
+	req := l.Request
+	if req.UserToken == "" {
+		req.UserToken = template.UserToken
+	}
+	if req.Auth == nil {
+		req.Auth = templateAuth(template, req)
+	}
+	if req.Headers == nil {
+		req.Headers = template.Headers
+	}
+	if req.UserAgent == "" {
+		req.UserAgent = template.UserAgent
+	}
+	if req.Type == "" {
+		req.Type = template.Type
+	}
+	req.NoCache = true
+	return req
+}
+
+//succeeded returns error if login response does not pass the success check.
+func (l *Login) succeeded(resp *fetch.Response) error {
+	defer resp.Close()
+	if l.Cookie != "" && !hasCookie(resp.Cookies, l.Cookie) {
+		return fmt.Errorf("cookie %s is not set", l.Cookie)
+	}
+	if l.Selector == "" {
+		return nil
+	}
+	doc, err := goquery.NewDocumentFromReader(resp)
+	if err != nil {
+		return err
+	}
+	if doc.Find(l.Selector).Length() == 0 {
+		return fmt.Errorf("%s is not found", l.Selector)
+	}
+	return nil
+}
+
+//expired returns true if the page response or fetch error shows that the user session has expired. Such pages are answered with 401 or 403 status, redirected to the login page or returned without the session cookie.
+func (l *Login) expired(resp *fetch.Response, err error) bool {
+	if err != nil {
+		var statusErr errs.StatusError
+		if errors.As(err, &statusErr) {
+			return statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden
+		}
+		return false
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if len(resp.Redirects) > 0 && samePage(resp.URL, l.Request.URL) {
+		return true
+	}
+	return l.Cookie != "" && resp.Cookies != nil && !hasCookie(resp.Cookies, l.Cookie)
+}
+
+//hasCookie returns true if not empty cookie with the name is in the list.
+func hasCookie(cookies []fetch.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+//samePage compares host and path of URLs ignoring query and fragment.
+func samePage(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host) && strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/")
+}
+
+//login performs login step of the task. Session generation is incremented on success so that pages fetched with the expired session may detect that re-login has already been done by another worker. Login is skipped if the session has been renewed since gen.
+func (task *Task) login(ctx context.Context, gen int) error {
+	task.loginMx.Lock()
+	defer task.loginMx.Unlock()
+	if task.loginGen != gen {
+		return nil
+	}
+	req := task.loginStep.request(task.templateRequest)
+	if err := task.scheduler.wait(ctx, req); err != nil {
+		return err
+	}
+	task.mx.Lock()
+	task.requestCount++
+	task.mx.Unlock()
+	resp, err := fetchContent(ctx, req)
+	if err != nil {
+		return errs.ParseError{URL: req.URL, Err: fmt.Errorf("login failed: %s", err)}
+	}
+	task.mx.Lock()
+	task.responseCount++
+	task.mx.Unlock()
+	if err := task.loginStep.succeeded(resp); err != nil {
+		return errs.ParseError{URL: req.URL, Err: fmt.Errorf("login failed: %s", err)}
+	}
+	task.loginGen++
+	logger.Info("Logged in", zap.String("URL", req.URL), zap.Int("session", task.loginGen))
+	return nil
+}
+
+//session returns current session generation.
+func (task *Task) session() int {
+	task.loginMx.Lock()
+	defer task.loginMx.Unlock()
+	return task.loginGen
+}
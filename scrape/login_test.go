@@ -0,0 +1,60 @@
+package scrape
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginRequest(t *testing.T) {
+	l := &Login{Request: fetch.Request{URL: "http://example.com/login", FormData: "user=u&pass=p"}}
+	assert.NoError(t, l.check())
+	assert.Error(t, (&Login{}).check())
+
+	template := fetch.Request{
+		URL:       "http://example.com/items",
+		UserToken: "token",
+		Auth:      &fetch.Auth{Token: "abc"},
+		UserAgent: "agent",
+	}
+	req := l.request(template)
+	assert.Equal(t, "http://example.com/login", req.URL)
+	assert.Equal(t, "user=u&pass=p", req.FormData)
+	assert.Equal(t, "token", req.UserToken)
+	assert.Equal(t, template.Auth, req.Auth)
+	assert.Equal(t, "agent", req.UserAgent)
+	assert.True(t, req.NoCache)
+
+	//credentials are not sent to other hosts
+	l.Request.URL = "https://accounts.example.org/login"
+	assert.Nil(t, l.request(template).Auth)
+	assert.Equal(t, template.Auth, templateAuth(template, fetch.Request{URL: "http://EXAMPLE.com/items?page=2"}))
+	assert.Nil(t, templateAuth(template, fetch.Request{URL: "http://other.com/items/1"}))
+}
+
+func TestLoginSucceeded(t *testing.T) {
+	page := func(body string, cookies ...fetch.Cookie) *fetch.Response {
+		return &fetch.Response{Body: ioutil.NopCloser(strings.NewReader(body)), Cookies: cookies}
+	}
+	l := &Login{Selector: ".logout", Cookie: "sid"}
+	assert.NoError(t, l.succeeded(page(`<a class="logout">Logout</a>`, fetch.Cookie{Name: "sid", Value: "1"})))
+	assert.Error(t, l.succeeded(page(`<a class="logout">Logout</a>`)))
+	assert.Error(t, l.succeeded(page(`<form id="login"></form>`, fetch.Cookie{Name: "sid", Value: "1"})))
+	assert.NoError(t, (&Login{}).succeeded(page("")))
+}
+
+func TestLoginExpired(t *testing.T) {
+	l := &Login{Request: fetch.Request{URL: "http://example.com/login"}, Cookie: "sid"}
+	sid := []fetch.Cookie{{Name: "sid", Value: "1"}}
+	assert.True(t, l.expired(nil, errs.StatusError{Code: http.StatusUnauthorized, Err: errors.New("Unauthorized")}))
+	assert.False(t, l.expired(nil, errs.StatusError{Code: http.StatusNotFound, Err: errors.New("Not Found")}))
+	assert.True(t, l.expired(&fetch.Response{StatusCode: http.StatusOK, URL: "http://example.com/login/?next=items", Redirects: []string{"http://example.com/items"}, Cookies: sid}, nil))
+	assert.True(t, l.expired(&fetch.Response{StatusCode: http.StatusOK, URL: "http://example.com/items", Cookies: []fetch.Cookie{}}, nil))
+	assert.False(t, l.expired(&fetch.Response{StatusCode: http.StatusOK, URL: "http://example.com/items", Cookies: sid}, nil))
+}
@@ -0,0 +1,343 @@
+package scrape
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+//pdfDocument is PDF document processed with PDF extractor. The whole document is a single block.
+type pdfDocument struct {
+	text string
+}
+
+func newPDFDocument(body []byte) (*pdfDocument, error) {
+	text, err := pdfText(body)
+	if err != nil {
+		return nil, err
+	}
+	return &pdfDocument{text: text}, nil
+}
+
+func (d *pdfDocument) split(selectors []string) ([]interface{}, []string, error) {
+	return []interface{}{d.text}, selectors, nil
+}
+
+//values returns the text of the document if selector is empty. Otherwise selector is a regular expression matched against the text. The first submatch is returned if the expression contains groups, the whole match otherwise.
+func (d *pdfDocument) values(block interface{}, selector, attr string) ([]string, error) {
+	text := block.(string)
+	if selector == "" {
+		return []string{text}, nil
+	}
+	re, err := regexp.Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	values := []string{}
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		if len(m) > 1 {
+			values = append(values, m[1])
+			continue
+		}
+		values = append(values, m[0])
+	}
+	return values, nil
+}
+
+//pdfText extracts text of PDF document page by page. Objects and streams are read through the cross-reference table honoring their /Length. Character codes are mapped to Unicode with ToUnicode CMaps of fonts, so the text of Type0 fonts emitted by Chrome, Word and LibreOffice is extracted as well as the text of simple fonts.
+func pdfText(body []byte) (text string, err error) {
+	//pdf package panics on malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("invalid PDF document: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", fmt.Errorf("invalid PDF document: %s", err)
+	}
+	pages := []string{}
+	for i := 1; i <= r.NumPage(); i++ {
+		if text := layoutText(pageGlyphs(r.Page(i))); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	return strings.Join(pages, "\n"), nil
+}
+
+//pdfGlyph is a character shown on the page. Coordinates and sizes are in points of the page with y axis pointing up.
+type pdfGlyph struct {
+	text string
+	x, y float64
+	//width is the advance of the glyph, size is the font size.
+	width, size float64
+}
+
+//pdfMatrix is a transformation matrix [a b c d e f] of PDF.
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) mul(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(x, y float64) pdfMatrix {
+	return pdfMatrix{1, 0, 0, 1, x, y}
+}
+
+func matrixOf(args []pdf.Value) pdfMatrix {
+	var m pdfMatrix
+	for i := range m {
+		m[i] = args[i].Float64()
+	}
+	return m
+}
+
+//pdfFont decodes character codes of the font and provides their widths.
+type pdfFont struct {
+	encoder pdf.TextEncoding
+	//codeBytes is 2 for Type0 fonts and 1 for simple fonts.
+	codeBytes int
+	//widths are glyph widths in thousandths of text space unit.
+	widths       map[int]float64
+	defaultWidth float64
+}
+
+//maxCIDRange limits the number of CIDs of a single range of Type0 font widths.
+const maxCIDRange = 0xffff
+
+func newPDFFont(f pdf.Font) *pdfFont {
+	font := &pdfFont{encoder: f.Encoder(), codeBytes: 1, widths: map[int]float64{}, defaultWidth: 500}
+	if f.V.Key("Subtype").Name() != "Type0" {
+		first := f.FirstChar()
+		for i, w := range f.Widths() {
+			font.widths[first+i] = w
+		}
+		return font
+	}
+	//CID of Identity-H encoded fonts is the character code itself
+	font.codeBytes = 2
+	cidFont := f.V.Key("DescendantFonts").Index(0)
+	font.defaultWidth = 1000
+	if dw := cidFont.Key("DW"); dw.Kind() != pdf.Null {
+		font.defaultWidth = dw.Float64()
+	}
+	//W array contains "c [w1 w2 ...]" and "cFirst cLast w" entries
+	w := cidFont.Key("W")
+	for i := 0; i+1 < w.Len(); {
+		first := int(w.Index(i).Int64())
+		if next := w.Index(i + 1); next.Kind() == pdf.Array {
+			for j := 0; j < next.Len(); j++ {
+				font.widths[first+j] = next.Index(j).Float64()
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= w.Len() {
+			break
+		}
+		last, width := int(w.Index(i+1).Int64()), w.Index(i+2).Float64()
+		for c := first; c <= last && c-first <= maxCIDRange; c++ {
+			font.widths[c] = width
+		}
+		i += 3
+	}
+	return font
+}
+
+func (f *pdfFont) width(code int) float64 {
+	if w, ok := f.widths[code]; ok {
+		return w
+	}
+	return f.defaultWidth
+}
+
+func (f *pdfFont) decode(code string) string {
+	if f.encoder == nil {
+		return code
+	}
+	return f.encoder.Decode(code)
+}
+
+//textState is the graphics and text state of PDF content stream affecting glyph positions.
+type textState struct {
+	ctm, tm, tlm pdfMatrix
+	saved        []pdfMatrix
+	font         *pdfFont
+	size         float64
+	charSpacing  float64
+	wordSpacing  float64
+	scale        float64
+	leading      float64
+	rise         float64
+	glyphs       []pdfGlyph
+}
+
+//show adds glyphs of the string to the page and advances text matrix.
+func (s *textState) show(str string) {
+	font := s.font
+	if font == nil {
+		font = &pdfFont{codeBytes: 1, defaultWidth: 500}
+	}
+	for i := 0; i+font.codeBytes <= len(str); i += font.codeBytes {
+		code := str[i : i+font.codeBytes]
+		c := int(code[0])
+		if font.codeBytes == 2 {
+			c = c<<8 | int(code[1])
+		}
+		advance := (font.width(c)/1000*s.size + s.charSpacing) * s.scale
+		if code == " " {
+			advance += s.wordSpacing * s.scale
+		}
+		origin := translate(0, s.rise).mul(s.tm).mul(s.ctm)
+		end := translate(advance, s.rise).mul(s.tm).mul(s.ctm)
+		s.glyphs = append(s.glyphs, pdfGlyph{
+			text:  font.decode(code),
+			x:     origin[4],
+			y:     origin[5],
+			width: math.Hypot(end[4]-origin[4], end[5]-origin[5]),
+			size:  s.size * math.Hypot(origin[2], origin[3]),
+		})
+		s.tm = translate(advance, 0).mul(s.tm)
+	}
+}
+
+//nextLine moves to the start of the next line.
+func (s *textState) nextLine(tx, ty float64) {
+	s.tlm = translate(tx, ty).mul(s.tlm)
+	s.tm = s.tlm
+}
+
+//pdfOperands is the number of operands of the operators affecting text.
+var pdfOperands = map[string]int{"cm": 6, "Tm": 6, "Td": 2, "TD": 2, "Tf": 2, "Tc": 1, "Tw": 1, "Tz": 1, "TL": 1, "Ts": 1, "Tj": 1, "TJ": 1, "'": 1, "\"": 3}
+
+//pageGlyphs returns glyphs shown by text operators of page content streams.
+func pageGlyphs(page pdf.Page) []pdfGlyph {
+	s := &textState{ctm: identityMatrix, tm: identityMatrix, tlm: identityMatrix, scale: 1}
+	fonts := map[string]*pdfFont{}
+	do := func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		//operators with missing operands are skipped
+		if len(args) < pdfOperands[op] {
+			return
+		}
+		switch op {
+		case "q":
+			s.saved = append(s.saved, s.ctm)
+		case "Q":
+			if n := len(s.saved); n > 0 {
+				s.ctm, s.saved = s.saved[n-1], s.saved[:n-1]
+			}
+		case "cm":
+			s.ctm = matrixOf(args).mul(s.ctm)
+		case "BT":
+			s.tm, s.tlm = identityMatrix, identityMatrix
+		case "Tf":
+			name := args[0].Name()
+			if _, ok := fonts[name]; !ok {
+				fonts[name] = nil
+				if f := page.Font(name); f.V.Kind() == pdf.Dict {
+					fonts[name] = newPDFFont(f)
+				}
+			}
+			s.font, s.size = fonts[name], args[1].Float64()
+		case "Tc":
+			s.charSpacing = args[0].Float64()
+		case "Tw":
+			s.wordSpacing = args[0].Float64()
+		case "Tz":
+			s.scale = args[0].Float64() / 100
+		case "TL":
+			s.leading = args[0].Float64()
+		case "Ts":
+			s.rise = args[0].Float64()
+		case "Td":
+			s.nextLine(args[0].Float64(), args[1].Float64())
+		case "TD":
+			s.leading = -args[1].Float64()
+			s.nextLine(args[0].Float64(), args[1].Float64())
+		case "Tm":
+			s.tlm = matrixOf(args)
+			s.tm = s.tlm
+		case "T*":
+			s.nextLine(0, -s.leading)
+		case "Tj":
+			s.show(args[0].RawString())
+		case "'":
+			s.nextLine(0, -s.leading)
+			s.show(args[0].RawString())
+		case "\"":
+			s.wordSpacing, s.charSpacing = args[0].Float64(), args[1].Float64()
+			s.nextLine(0, -s.leading)
+			s.show(args[2].RawString())
+		case "TJ":
+			for i := 0; i < args[0].Len(); i++ {
+				v := args[0].Index(i)
+				if v.Kind() == pdf.String {
+					s.show(v.RawString())
+					continue
+				}
+				//positive adjustments move the next glyph to the left
+				s.tm = translate(-v.Float64()/1000*s.size*s.scale, 0).mul(s.tm)
+			}
+		}
+	}
+	contents := page.V.Key("Contents")
+	if contents.Kind() == pdf.Array {
+		for i := 0; i < contents.Len(); i++ {
+			pdf.Interpret(contents.Index(i), do)
+		}
+	} else {
+		pdf.Interpret(contents, do)
+	}
+	return s.glyphs
+}
+
+//wordGap is the minimal gap between glyphs separating words relative to the font size.
+const wordGap = 0.2
+
+//layoutText joins glyphs in lines from the top of the page to the bottom. Glyphs are on the same line if their baselines differ less than a half of the font size. Words are separated by gaps wider than wordGap.
+func layoutText(glyphs []pdfGlyph) string {
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].y > glyphs[j].y })
+	lines := [][]pdfGlyph{}
+	for _, g := range glyphs {
+		n := len(lines)
+		if n > 0 && math.Abs(lines[n-1][0].y-g.y) < math.Max(lines[n-1][0].size, g.size)/2 {
+			lines[n-1] = append(lines[n-1], g)
+			continue
+		}
+		lines = append(lines, []pdfGlyph{g})
+	}
+	text := make([]string, 0, len(lines))
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].x < line[j].x })
+		var b strings.Builder
+		for i, g := range line {
+			if i > 0 {
+				prev := line[i-1]
+				if g.x-(prev.x+prev.width) > wordGap*math.Max(prev.size, g.size) &&
+					!strings.HasSuffix(prev.text, " ") && !strings.HasPrefix(g.text, " ") {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(g.text)
+		}
+		if line := strings.TrimSpace(b.String()); line != "" {
+			text = append(text, line)
+		}
+	}
+	return strings.Join(text, "\n")
+}
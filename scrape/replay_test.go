@@ -0,0 +1,108 @@
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestReplayParse records a paginated site through fetch.d and parses it again from cassettes after the site is gone.
+func TestReplayParse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Path
+		fmt.Fprintf(w, `<html><body><div id="cards"><a href="/person%s-1">Person %s-1</a><a href="/person%s-2">Person %s-2</a></div><a class="next" href="/2">Next</a></body></html>`, page, page, page, page)
+	}))
+	dir, err := ioutil.TempDir("", "replay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for key, value := range map[string]interface{}{
+		"STORAGE_TYPE":   "diskv",
+		"DISKV_BASE_DIR": filepath.Join(dir, "diskv"),
+		"RESULTS_DIR":    filepath.Join(dir, "results"),
+		"CASSETTE_DIR":   filepath.Join(dir, "cassettes"),
+	} {
+		defer viper.Set(key, viper.Get(key))
+		viper.Set(key, value)
+	}
+	defer viper.Set("REPLAY_MODE", "")
+	fetchServer := fetch.Start(fetch.Config{Host: viper.GetString("DFK_FETCH")})
+	defer fetchServer.Stop()
+
+	payload := Payload{
+		Name:    "replay",
+		Request: fetch.Request{URL: ts.URL + "/1"},
+		Fields: []Field{
+			{
+				Name:        "Names",
+				CSSSelector: "#cards a",
+				Attrs:       []string{"text", "href"},
+			},
+		},
+		Paginator: ".next",
+		Format:    "json",
+	}
+	parse := func() []byte {
+		r, err := NewTask().Parse(context.Background(), payload)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r)
+		out := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+		result, err := ioutil.ReadFile(out["Output file"].(string))
+		assert.NoError(t, err)
+		return result
+	}
+
+	viper.Set("REPLAY_MODE", "record")
+	recorded := parse()
+	assert.Contains(t, string(recorded), "Person /2-2")
+	ts.Close()
+
+	viper.Set("REPLAY_MODE", "replay")
+	assert.Equal(t, recorded, parse())
+
+	//requests of replay type need no REPLAY_MODE
+	viper.Set("REPLAY_MODE", "")
+	payload.Request.Type = "replay"
+	assert.Equal(t, recorded, parse())
+
+	//empty results of replayed pages are not parsed again with Chrome fetcher
+	payload.Fields[0].CSSSelector = "#missing a"
+	payload.Paginator = ""
+	task := NewTask()
+	_, err = task.Parse(context.Background(), payload)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), errs.ErrEmptyResults)
+	assert.Equal(t, 1, task.requestCount)
+
+	//pages parsed again with Chrome fetcher in replay mode are served from the same cassettes
+	viper.Set("REPLAY_MODE", "replay")
+	payload.Request.Type = ""
+	task = NewTask()
+	_, err = task.Parse(context.Background(), payload)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), errs.ErrEmptyResults)
+	assert.Equal(t, 2, task.requestCount)
+
+	//requests served by Chrome fetcher already are not parsed again
+	payload.Request.Wait = &fetch.Wait{}
+	task = NewTask()
+	_, err = task.Parse(context.Background(), payload)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), errs.ErrEmptyResults)
+	assert.Equal(t, 1, task.requestCount)
+}
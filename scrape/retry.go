@@ -0,0 +1,154 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//defaultRetryHTTPCodes is used when RETRY_HTTP_CODES is not set.
+var defaultRetryHTTPCodes = []int{500, 502, 503, 504, 408}
+
+//retryPolicy describes how transient fetch failures are retried.
+type retryPolicy struct {
+	//times is the maximum number of retries in addition to the first download.
+	times int
+	//codes lists HTTP status codes which are considered transient.
+	codes []int
+	//delay is the base delay used for exponential backoff.
+	delay time.Duration
+	//maxDelay caps the backoff delay.
+	maxDelay time.Duration
+}
+
+//resumeState keeps pipeline state of a page rescheduled after a transient fetch failure.
+type resumeState struct {
+	//attempt is the number of retries already made for the page.
+	attempt int
+	//page is the paginator page number of the failed request.
+	page int
+	//blockCounter and zeroPaginator are taken from the original pipeline so that the records of a retried page are stored under the same keys.
+	blockCounter  *int
+	zeroPaginator bool
+}
+
+//newRetryPolicy creates retryPolicy with retryTimes taken from Payload and other settings from RETRY_* parse.d flags.
+func newRetryPolicy(retryTimes int) retryPolicy {
+	rp := retryPolicy{
+		times:    retryTimes,
+		delay:    time.Duration(viper.GetInt("RETRY_DELAY")) * time.Millisecond,
+		maxDelay: time.Duration(viper.GetInt("RETRY_MAX_DELAY")) * time.Millisecond,
+	}
+	for _, c := range viper.GetStringSlice("RETRY_HTTP_CODES") {
+		code, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil {
+			logger.Warn("Invalid retry HTTP code", zap.String("code", c))
+			continue
+		}
+		rp.codes = append(rp.codes, code)
+	}
+	if len(rp.codes) == 0 {
+		rp.codes = defaultRetryHTTPCodes
+	}
+	if rp.delay <= 0 {
+		rp.delay = 500 * time.Millisecond
+	}
+	if rp.maxDelay < rp.delay {
+		rp.maxDelay = rp.delay
+	}
+	return rp
+}
+
+//retryable returns true if err is a network error or a status error with one of retry HTTP codes.
+func (rp retryPolicy) retryable(err error) bool {
+	var statusErr errs.StatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range rp.codes {
+			if statusErr.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+//backoff returns exponential delay for specified attempt with a random jitter. The delay ranges from 0.5 to 1.0 of the exponential value.
+func (rp retryPolicy) backoff(attempt int) time.Duration {
+	d := float64(rp.delay) * math.Pow(2, float64(attempt))
+	if d > float64(rp.maxDelay) {
+		d = float64(rp.maxDelay)
+	}
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+//retryLater puts payload for the failed request to the deferred queue. It returns false if the error is not transient or retry attempts are exhausted.
+func (task *Task) retryLater(p Payload, req fetch.Request, state resumeState, err error) bool {
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	if !task.retry.retryable(err) || state.attempt >= task.retry.times {
+		task.failedCount++
+		return false
+	}
+	state.attempt++
+	p.Request = req
+	p.resume = &state
+	task.deferred = append(task.deferred, p)
+	task.retryCount++
+	logger.Warn("Fetch failed. Rescheduled",
+		zap.String("URL", req.URL),
+		zap.Int("attempt", state.attempt),
+		zap.Error(err))
+	return true
+}
+
+//drainDeferred feeds payloads from the deferred queue back to workers once the main crawl is finished. It repeats until the queue is empty as retried pages may fail again.
+func (task *Task) drainDeferred(ctx context.Context) {
+	for {
+		task.mx.Lock()
+		deferred := task.deferred
+		task.deferred = nil
+		task.mx.Unlock()
+		if len(deferred) == 0 {
+			return
+		}
+		for _, p := range deferred {
+			task.jobDone.Add(1)
+			go func(p Payload) {
+				select {
+				case <-time.After(task.retry.backoff(p.resume.attempt - 1)):
+					select {
+					case task.payloads <- p:
+					case <-ctx.Done():
+						task.jobDone.Done()
+					}
+				case <-ctx.Done():
+					task.jobDone.Done()
+				}
+			}(p)
+		}
+		task.jobDone.Wait()
+	}
+}
+
+//pageFromKey extracts paginator page number from flow key of "uid-page" form.
+func pageFromKey(key string) int {
+	keyArr := strings.Split(key, "-")
+	page, err := strconv.Atoi(keyArr[len(keyArr)-1])
+	if err != nil {
+		return 0
+	}
+	return page
+}
@@ -0,0 +1,58 @@
+package scrape
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	viper.Set("RETRY_HTTP_CODES", []string{"503", "invalid"})
+	viper.Set("RETRY_DELAY", 100)
+	viper.Set("RETRY_MAX_DELAY", 400)
+	rp := newRetryPolicy(2)
+	assert.Equal(t, []int{503}, rp.codes)
+	assert.True(t, rp.retryable(errs.StatusError{Code: 503, Err: errors.New("Service Unavailable")}))
+	assert.True(t, rp.retryable(errs.ParseError{URL: "http://example.com", Err: errs.StatusError{Code: 503, Err: errors.New("")}}))
+	assert.False(t, rp.retryable(errs.StatusError{Code: 404, Err: errors.New("Not Found")}))
+	assert.True(t, rp.retryable(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.False(t, rp.retryable(errors.New("some error")))
+
+	for attempt, max := range []time.Duration{100, 200, 400, 400} {
+		d := rp.backoff(attempt)
+		assert.True(t, d >= max*time.Millisecond/2 && d <= max*time.Millisecond, d.String())
+	}
+
+	viper.Set("RETRY_HTTP_CODES", nil)
+	assert.Equal(t, defaultRetryHTTPCodes, newRetryPolicy(0).codes)
+}
+
+func TestRetryLater(t *testing.T) {
+	task := &Task{retry: retryPolicy{times: 1, codes: defaultRetryHTTPCodes}}
+	p := Payload{PayloadMD5: "uid"}
+	req := fetch.Request{URL: "http://example.com/page-2"}
+	state := resumeState{page: 2}
+	transient := errs.StatusError{Code: 502, Err: errors.New("Bad Gateway")}
+
+	assert.True(t, task.retryLater(p, req, state, transient))
+	assert.Equal(t, 1, len(task.deferred))
+	assert.Equal(t, req.URL, task.deferred[0].Request.URL)
+	assert.Equal(t, 2, task.deferred[0].resume.page)
+	assert.Equal(t, 1, task.deferred[0].resume.attempt)
+
+	//retry attempts are exhausted
+	assert.False(t, task.retryLater(p, req, *task.deferred[0].resume, transient))
+	//not transient error
+	assert.False(t, task.retryLater(p, req, state, errs.StatusError{Code: 404, Err: errors.New("Not Found")}))
+	assert.Equal(t, 1, task.retryCount)
+	assert.Equal(t, 2, task.failedCount)
+
+	assert.Equal(t, 3, pageFromKey("uid-3"))
+	assert.Equal(t, 0, pageFromKey(""))
+}
@@ -0,0 +1,115 @@
+package scrape
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+)
+
+//hostScheduler throttles requests to the same host. Every host has its own token bucket so requests to different hosts proceed in parallel.
+type hostScheduler struct {
+	mx      sync.Mutex
+	buckets map[string]*hostBucket
+	//delay is used for hosts without Crawl-delay or Request-rate directives in robots.txt.
+	delay     time.Duration
+	randomize bool
+	//crawlDelay returns Crawl-delay of the request's host from robots.txt.
+	crawlDelay func(ctx context.Context, req fetch.Request) time.Duration
+	disabled   bool
+}
+
+//hostBucket is a token bucket holding a single token which is refilled every interval.
+type hostBucket struct {
+	once     sync.Once
+	mx       sync.Mutex
+	interval time.Duration
+	//next is the time when the next token is available.
+	next time.Time
+}
+
+//newHostScheduler creates hostScheduler. FetchDelay and RandomizeFetchDelay are taken from Payload. FETCH_DELAY and RANDOMIZE_FETCH_DELAY settings of parse.d are used if they are omitted.
+func newHostScheduler(p Payload, crawlDelay func(ctx context.Context, req fetch.Request) time.Duration) *hostScheduler {
+	s := &hostScheduler{
+		buckets:    make(map[string]*hostBucket),
+		delay:      time.Duration(viper.GetInt("FETCH_DELAY")) * time.Millisecond,
+		randomize:  viper.GetBool("RANDOMIZE_FETCH_DELAY"),
+		crawlDelay: crawlDelay,
+		disabled:   viper.GetBool("IGNORE_FETCH_DELAY"),
+	}
+	if p.FetchDelay != nil {
+		s.delay = *p.FetchDelay
+	}
+	if p.RandomizeFetchDelay != nil {
+		s.randomize = *p.RandomizeFetchDelay
+	}
+	return s
+}
+
+//bucket returns token bucket of the host creating it if needed.
+func (s *hostScheduler) bucket(host string) *hostBucket {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	b, ok := s.buckets[host]
+	if !ok {
+		b = &hostBucket{}
+		s.buckets[host] = b
+	}
+	return b
+}
+
+//interval returns delay between consecutive requests to the request's host. Crawl-delay from robots.txt takes precedence over FetchDelay.
+func (s *hostScheduler) interval(ctx context.Context, req fetch.Request) time.Duration {
+	if s.crawlDelay != nil {
+		if d := s.crawlDelay(ctx, req); d > 0 {
+			return d
+		}
+	}
+	return s.delay
+}
+
+//wait blocks until a request to the request's host is allowed. It returns an error if ctx is done before.
+func (s *hostScheduler) wait(ctx context.Context, req fetch.Request) error {
+	if s == nil || s.disabled {
+		return nil
+	}
+	host, err := req.Host()
+	if err != nil {
+		//invalid URLs are reported by fetcher
+		return nil
+	}
+	b := s.bucket(host)
+	b.once.Do(func() {
+		b.interval = s.interval(ctx, req)
+	})
+	d := b.reserve(s.randomize)
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//reserve takes a token from the bucket and returns the time to wait until it is available. If randomize is true the next token is refilled in a random time ranging from 0.5 * interval to 1.5 * interval.
+func (b *hostBucket) reserve(randomize bool) time.Duration {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	interval := b.interval
+	if randomize {
+		interval = time.Duration(float64(interval) * (0.5 + rand.Float64()))
+	}
+	b.next = b.next.Add(interval)
+	return wait
+}
@@ -0,0 +1,45 @@
+package scrape
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostScheduler(t *testing.T) {
+	viper.Set("IGNORE_FETCH_DELAY", false)
+	defer viper.Set("IGNORE_FETCH_DELAY", true)
+	delay := 100 * time.Millisecond
+	randomize := false
+	crawlDelay := func(ctx context.Context, req fetch.Request) time.Duration {
+		if host, _ := req.Host(); host == "robots.example.com" {
+			return 200 * time.Millisecond
+		}
+		return 0
+	}
+	s := newHostScheduler(Payload{FetchDelay: &delay, RandomizeFetchDelay: &randomize}, crawlDelay)
+	ctx := context.Background()
+
+	//the first request to every host is not delayed
+	begin := time.Now()
+	assert.NoError(t, s.wait(ctx, fetch.Request{URL: "http://a.example.com/1"}))
+	assert.NoError(t, s.wait(ctx, fetch.Request{URL: "http://b.example.com/1"}))
+	assert.True(t, time.Since(begin) < delay)
+
+	//consecutive requests to the same host are delayed
+	assert.NoError(t, s.wait(ctx, fetch.Request{URL: "http://a.example.com/2"}))
+	assert.True(t, time.Since(begin) >= delay)
+
+	//Crawl-delay from robots.txt takes precedence over FetchDelay
+	assert.Equal(t, 200*time.Millisecond, s.interval(ctx, fetch.Request{URL: "http://robots.example.com"}))
+	assert.Equal(t, delay, s.interval(ctx, fetch.Request{URL: "http://c.example.com"}))
+
+	//canceled context
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, s.wait(cctx, fetch.Request{URL: "http://a.example.com/3"}))
+}
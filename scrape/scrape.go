@@ -10,7 +10,11 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +31,9 @@ import (
 
 func (f *Field) extract(content *goquery.Selection, results *map[string]interface{}, baseURL string) error {
 	for _, attr := range f.Attrs {
+		if isResponseAttr(attr) {
+			continue
+		}
 		values := []string{}
 		var err error
 		content.Find(f.CSSSelector).Each(func(index int, s *goquery.Selection) {
@@ -84,6 +91,208 @@ func (f *Field) extract(content *goquery.Selection, results *map[string]interfac
 	return nil
 }
 
+//isResponseAttr returns true for pseudo attributes extracting fetch response metadata: _url, _status, _contentType, _charset, _redirects, _header.Name and _capture.Name
+func isResponseAttr(attr string) bool {
+	return strings.HasPrefix(attr, "_")
+}
+
+//responseOnly returns true if the field extracts response metadata only. CSS selector is not required for such fields.
+func (f *Field) responseOnly() bool {
+	for _, attr := range f.Attrs {
+		if !isResponseAttr(attr) {
+			return false
+		}
+	}
+	return true
+}
+
+//extractResponse extracts values of pseudo attributes from response metadata of the page the block belongs to.
+func (f *Field) extractResponse(resp *fetch.Response, results *map[string]interface{}) {
+	if resp == nil {
+		return
+	}
+	for _, attr := range f.Attrs {
+		if !isResponseAttr(attr) {
+			continue
+		}
+		var value interface{}
+		switch {
+		case attr == "_url":
+			value = resp.URL
+		case attr == "_status":
+			value = strconv.Itoa(resp.StatusCode)
+		case attr == "_contentType":
+			value = resp.ContentType
+		case attr == "_charset":
+			value = resp.Charset
+		case attr == "_redirects":
+			if len(resp.Redirects) == 0 {
+				continue
+			}
+			value = resp.Redirects
+		case strings.HasPrefix(attr, "_capture."):
+			name := strings.TrimPrefix(attr, "_capture.")
+			for _, c := range resp.Captures {
+				if c.Name == name && c.Path != "" {
+					value = c.Path
+				}
+			}
+			if value == nil {
+				continue
+			}
+		case strings.HasPrefix(attr, "_network."):
+			values := networkValues(resp.Network, strings.TrimPrefix(attr, "_network."))
+			switch len(values) {
+			case 0:
+				continue
+			case 1:
+				value = values[0]
+			default:
+				value = values
+			}
+		case strings.HasPrefix(attr, "_header."):
+			header := resp.Header.Get(strings.TrimPrefix(attr, "_header."))
+			if header == "" {
+				continue
+			}
+			value = header
+		default:
+			continue
+		}
+		(*results)[f.Name+"_"+attr] = value
+	}
+}
+
+//networkValues extracts values from JSON bodies of network responses recorded by Chrome fetcher. Reference is the name of network filter followed by dot separated path to the value, e.g. "products.items.0.title".
+func networkValues(responses []fetch.NetworkResponse, ref string) []string {
+	parts := strings.Split(ref, ".")
+	values := []string{}
+	for _, r := range responses {
+		if r.Name != parts[0] {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(r.Body), &v); err != nil {
+			logger.Warn("Failed to decode network response", zap.String("url", r.URL), zap.Error(err))
+			continue
+		}
+		values = append(values, jsonValues(v, parts[1:])...)
+	}
+	return values
+}
+
+//jsonValues returns values found by path in decoded JSON. Path elements are object keys or array indexes. Path elements following an array without index are applied to every array element. Values are converted to strings, objects are returned as JSON.
+func jsonValues(v interface{}, path []string) []string {
+	values := []string{}
+	if arr, ok := v.([]interface{}); ok {
+		if len(path) > 0 {
+			if i, err := strconv.Atoi(path[0]); err == nil {
+				if i < 0 || i >= len(arr) {
+					return values
+				}
+				return jsonValues(arr[i], path[1:])
+			}
+		}
+		for _, e := range arr {
+			values = append(values, jsonValues(e, path)...)
+		}
+		return values
+	}
+	if len(path) > 0 {
+		if obj, ok := v.(map[string]interface{}); ok {
+			return jsonValues(obj[path[0]], path[1:])
+		}
+		return values
+	}
+	switch t := v.(type) {
+	case nil:
+	case string:
+		values = append(values, t)
+	case float64:
+		values = append(values, strconv.FormatFloat(t, 'f', -1, 64))
+	case bool:
+		values = append(values, strconv.FormatBool(t))
+	default:
+		b, _ := json.Marshal(t)
+		values = append(values, string(b))
+	}
+	return values
+}
+
+//reportJSErrors keeps JavaScript errors of the page for the task report. Pages with broken scripts often yield empty results.
+func (task *Task) reportJSErrors(url string, resp *fetch.Response) {
+	messages := []string{}
+	for _, m := range resp.Console {
+		if m.IsError() {
+			messages = append(messages, m.Text)
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+	logger.Warn("JavaScript errors", zap.String("URL", url), zap.Strings("errors", messages))
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	if task.jsErrors == nil {
+		task.jsErrors = map[string][]string{}
+	}
+	task.jsErrors[url] = append(task.jsErrors[url], messages...)
+}
+
+//saveCaptures writes screenshots and PDFs of the page to RESULTS_DIR next to the task results. Paths of the saved files replace captured data in the response so records may reference them with "_capture.Name" pseudo attribute.
+func (task *Task) saveCaptures(resp *fetch.Response) error {
+	if len(resp.Captures) == 0 {
+		return nil
+	}
+	resultPath := viper.GetString("RESULTS_DIR")
+	if err := os.MkdirAll(resultPath, 0700); err != nil {
+		return err
+	}
+	urlHash := string(utils.GenerateCRC32([]byte(resp.URL)))
+	for i, c := range resp.Captures {
+		//names are validated by fetch.d, base name guards against older fetch services
+		fileName := path.Join(resultPath, fmt.Sprintf("%s_%s_%s.%s", task.rootUID, urlHash, filepath.Base(c.Name), c.Extension()))
+		if err := ioutil.WriteFile(fileName, c.Data, 0660); err != nil {
+			return err
+		}
+		resp.Captures[i].Path = fileName
+		resp.Captures[i].Data = nil
+	}
+	return nil
+}
+
+//saveHAR writes HAR document of the page requested by Request.HAR to RESULTS_DIR for debugging.
+func (task *Task) saveHAR(resp *fetch.Response) error {
+	if resp.HAR == nil {
+		return nil
+	}
+	resultPath := viper.GetString("RESULTS_DIR")
+	if err := os.MkdirAll(resultPath, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(resp.HAR)
+	if err != nil {
+		return err
+	}
+	fileName := path.Join(resultPath, fmt.Sprintf("%s_%s.har", task.rootUID, string(utils.GenerateCRC32([]byte(resp.URL)))))
+	if err := ioutil.WriteFile(fileName, data, 0660); err != nil {
+		return err
+	}
+	logger.Info("HAR saved", zap.String("URL", resp.URL), zap.String("path", fileName))
+	resp.HAR = nil
+	return nil
+}
+
+//withBody replaces body of fetched content keeping response metadata.
+func withBody(content io.ReadCloser, body io.ReadCloser) io.ReadCloser {
+	if resp, ok := content.(*fetch.Response); ok {
+		r := *resp
+		r.Body = body
+		return &r
+	}
+	return body
+}
+
 func (f Filter) Apply(data string) (string, error) {
 	if data == "" {
 		return "", errors.New("Data source is empty")
@@ -138,6 +347,8 @@ func (p *Payload) InitUID() {
 		Fields:              p.Fields,
 		Format:              "",
 		IsPath:              p.IsPath,
+		Login:               p.Login,
+		Sitemap:             p.Sitemap,
 		Name:                p.Name,
 		PaginateResults:     p.PaginateResults,
 		Paginator:           p.Paginator,
@@ -177,6 +388,7 @@ func NewTask() *Task {
 	return &Task{
 		//Errors:       []error{},
 		Robots:       make(map[string]*robotstxt.RobotsData),
+		disallowed:   []string{},
 		requestCount: 0,
 		storage:      storage.NewStore(storageType),
 		jobDone:      sync.WaitGroup{},
@@ -192,12 +404,31 @@ func (task *Task) checkPayload(p *Payload) error {
 		if field.Name == "" {
 			return fmt.Errorf("Bad payload: Field %d has no name", i)
 		}
-		if field.CSSSelector == "" {
-			return fmt.Errorf("Bad payload: Field %d has no css selector", i)
-		}
 		if len(field.Attrs) == 0 {
 			return fmt.Errorf("Bad payload: Field %d has no attributes to extract", i)
 		}
+		if field.CSSSelector == "" && !field.responseOnly() && field.target() != PDFExtractor {
+			return fmt.Errorf("Bad payload: Field %d has no css selector", i)
+		}
+		if err := field.checkSelector(); err != nil {
+			return fmt.Errorf("Bad payload: Field %d: %s", i, err)
+		}
+	}
+	//fetchers registered in fetch.d only are validated by fetch.d
+	if _, ok := fetch.Lookup(p.Request.Type); ok {
+		if err := fetch.CheckRequest(p.Request); err != nil {
+			return fmt.Errorf("Bad payload: %s", err)
+		}
+	}
+	if p.Login != nil {
+		if err := p.Login.check(); err != nil {
+			return err
+		}
+	}
+	if p.Sitemap != nil {
+		if err := p.Sitemap.check(p.Request); err != nil {
+			return err
+		}
 	}
 	supportedOutputFormats := map[string]interface{}{"json": nil, "jsonl": nil, "xml": nil, "csv": nil}
 	if _, ok := supportedOutputFormats[strings.ToLower(p.Format)]; !ok {
@@ -220,22 +451,51 @@ func (task *Task) Parse(ctx context.Context, payload Payload) (io.ReadCloser, er
 		go task.scrapeContent(ctx)
 	}
 
+	//relative links of sitemap pages are resolved against the sitemap URL if request URL is not given
+	if payload.Sitemap != nil && payload.Request.URL == "" {
+		payload.Request.URL = payload.Sitemap.URL
+	}
 	payload.InitUID()
 	task.rootUID = payload.PayloadMD5
 	task.templateRequest = payload.Request
+	task.retry = newRetryPolicy(payload.RetryTimes)
+	task.scheduler = newHostScheduler(payload, task.crawlDelay)
+	if payload.Login != nil {
+		//login session is kept under the task's own user token if it is not given
+		if payload.Request.UserToken == "" {
+			payload.Request.UserToken = "login-" + payload.PayloadMD5
+		}
+		task.templateRequest = payload.Request
+		task.loginStep = payload.Login
+		if err := task.login(ctx, 0); err != nil {
+			return nil, err
+		}
+	}
 
-	task.jobDone.Add(1)
-	task.payloads <- payload
-	task.jobDone.Wait()
+	var startURLs []string
+	if payload.Sitemap != nil {
+		if startURLs, err = task.sitemapURLs(ctx, payload); err != nil {
+			return nil, err
+		}
+	}
 
-	if !task.isParsed && payload.Request.Type != "chrome" {
+	task.run(ctx, payload, startURLs)
+	task.drainDeferred(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	//pages rendered by scripts are parsed again with Chrome fetcher. Requests needing Chrome features are served by Chrome fetcher already. Other fetchers like replay fetcher must not fall back to the network.
+	if t := strings.ToLower(payload.Request.Type); !task.isParsed && (t == "" || t == strings.ToLower(string(fetch.Base))) && !payload.Request.NeedsChrome() {
 		payload.Request.Type = "chrome"
 		payload.InitUID()
 		task.rootUID = payload.PayloadMD5
 		task.templateRequest = payload.Request
-		task.jobDone.Add(1)
-		task.payloads <- payload
-		task.jobDone.Wait()
+		task.run(ctx, payload, startURLs)
+		task.drainDeferred(ctx)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	if !task.isParsed {
 		return nil, errs.ParseError{URL: payload.Request.URL, Err: errors.New(errs.ErrEmptyResults)}
@@ -258,9 +518,15 @@ func (task *Task) Parse(ctx context.Context, payload Payload) (io.ReadCloser, er
 		"Task ID":     payload.PayloadMD5,
 		"Requests":    task.requestCount,
 		"Responses":   task.responseCount,
+		"Retries":     task.retryCount,
+		"Failed":      task.failedCount,
+		"Disallowed":  task.disallowed,
 		"Output file": string(r),
 		"Took":        time.Since(begin).String(),
 	}
+	if len(task.jsErrors) > 0 {
+		m["JS errors"] = task.jsErrors
+	}
 	parseResults, err := json.Marshal(m)
 	if err != nil {
 		return nil, err
@@ -268,61 +534,143 @@ func (task *Task) Parse(ctx context.Context, payload Payload) (io.ReadCloser, er
 	return ioutil.NopCloser(bytes.NewReader(parseResults)), nil
 }
 
-func (task *Task) allowedByRobots(req fetch.Request, initFetchWorkers bool) error {
-	//get Robotstxt Data
+//run passes the payload to workers and waits until it is processed along with its paginated and details pages. Payload with start URLs taken from the sitemap is passed once for every URL. Remaining URLs are not passed once ctx is done.
+func (task *Task) run(ctx context.Context, payload Payload, startURLs []string) {
+	if len(startURLs) == 0 {
+		task.push(ctx, payload)
+		task.jobDone.Wait()
+		return
+	}
+	//sitemap pages share the block counter so that their records are stored under distinct keys of the same task
+	blockCounter := new(int)
+	for _, u := range startURLs {
+		p := payload
+		p.Request.URL = u
+		p.blockCounter = blockCounter
+		if !task.push(ctx, p) {
+			break
+		}
+	}
+	task.jobDone.Wait()
+}
+
+//push passes the payload to workers. It returns false if ctx is done before a worker is able to take the payload.
+func (task *Task) push(ctx context.Context, p Payload) bool {
+	task.jobDone.Add(1)
+	select {
+	case task.payloads <- p:
+		return true
+	case <-ctx.Done():
+		task.jobDone.Done()
+		return false
+	}
+}
+
+//robotsData returns robots.txt data of the request's host. Robots.txt is retrieved once per host for the task's lifetime. Nil is returned if robots.txt is not available. Server errors disallow the host temporarily, robots.txt is retrieved again by the next request to the host.
+func (task *Task) robotsData(ctx context.Context, req fetch.Request) (*robotstxt.RobotsData, error) {
 	host, err := req.Host()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, ok := task.Robots[host]; !ok {
-		robots, err := fetch.RobotstxtData(req.URL)
-		if err != nil {
-			robotsURL, err1 := fetch.AssembleRobotstxtURL(req.URL)
-			if err1 != nil {
-				return err1
-			}
-			logger.Warn(err.Error(),
-				zap.String("Robots.txt URL", robotsURL))
+	task.robotsMx.Lock()
+	robots, ok := task.Robots[host]
+	task.robotsMx.Unlock()
+	if ok {
+		return robots, nil
+	}
+	robots, err = fetch.RobotstxtData(ctx, req.URL)
+	if err != nil {
+		//robots.txt is not cached if the task is cancelled
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		robotsURL, err1 := fetch.AssembleRobotstxtURL(req.URL)
+		if err1 != nil {
+			return nil, err1
+		}
+		logger.Warn(err.Error(),
+			zap.String("Robots.txt URL", robotsURL))
+		if se, ok := err.(errs.StatusError); ok && se.Code >= http.StatusInternalServerError {
+			return robots, nil
 		}
-		task.Robots[host] = robots
 	}
+	task.robotsMx.Lock()
+	defer task.robotsMx.Unlock()
+	if cached, ok := task.Robots[host]; ok {
+		return cached, nil
+	}
+	task.Robots[host] = robots
+	return robots, nil
+}
 
-	//check if scraping of current url is not forbidden
-	if !fetch.AllowedByRobots(req.URL, task.Robots[host]) {
-		return errs.StatusError{403, errors.New(http.StatusText(http.StatusForbidden))}
+//crawlDelay returns Crawl-delay directive from robots.txt of the request's host.
+func (task *Task) crawlDelay(ctx context.Context, req fetch.Request) time.Duration {
+	if viper.GetBool("IGNORE_ROBOTSTXT") {
+		return 0
 	}
-	return nil
+	robots, err := task.robotsData(ctx, req)
+	if err != nil {
+		return 0
+	}
+	return fetch.GetCrawlDelay(robots)
+}
+
+//allowedByRobots checks if fetching of the request's URL is allowed by robots.txt of its host. It always returns true if IGNORE_ROBOTSTXT is set.
+func (task *Task) allowedByRobots(ctx context.Context, req fetch.Request) bool {
+	if viper.GetBool("IGNORE_ROBOTSTXT") {
+		return true
+	}
+	robots, err := task.robotsData(ctx, req)
+	if err != nil {
+		//invalid URLs are reported by fetcher
+		return true
+	}
+	return fetch.AllowedByRobots(req.URL, robots)
 }
 
-//response sends request to fetch service and returns fetch.FetchResponser
-func fetchContent(req fetch.Request) (io.ReadCloser, error) {
+//response sends request to fetch service and returns fetch.FetchResponser. The request is cancelled when ctx is done.
+func fetchContent(ctx context.Context, req fetch.Request) (*fetch.Response, error) {
 	svc, err := fetch.NewHTTPClient(viper.GetString("DFK_FETCH"))
 	if err != nil {
 		logger.Error(err.Error())
 	}
-	return svc.Fetch(req)
+	return svc.Fetch(ctx, req)
 }
 
 func (task *Task) scrapeContent(ctx context.Context) error {
 	for payload := range task.payloads {
 		select {
 		case <-ctx.Done():
-			return nil
+			//payloads of cancelled task are drained so that run and details pushes are not blocked
+			task.jobDone.Done()
 		default:
 			var errs []<-chan error
+			// if block counter not equal nil that means that parent payload has path
+			// so we have to zero page number in a key
+			state := resumeState{
+				blockCounter:  payload.blockCounter,
+				zeroPaginator: payload.blockCounter != nil,
+			}
+			if payload.resume != nil {
+				state = *payload.resume
+			}
+			if state.blockCounter == nil {
+				state.blockCounter = new(int)
+			}
 			fetchChannel := make(chan flow)
-			content, errc := task.fetch(ctx, fetchChannel, payload.PayloadMD5)
+			content, errc := task.fetch(ctx, fetchChannel, payload, state)
 			errs = append(errs, errc)
-			paginateContent, errc := task.paginate(ctx, content, payload.Paginator, fetchChannel)
+			paginateContent, errc := task.paginate(ctx, content, payload.Paginator, state.page, fetchChannel)
 			errs = append(errs, errc)
 			blockChannel, errc, err := task.divide(ctx, paginateContent, payload.Fields)
 			if err != nil {
+				task.jobDone.Done()
 				return err
 			}
 			errs = append(errs, errc)
-			scrapedChannel, errc := task.parse(ctx, blockChannel, payload.Fields, payload.IsPath, payload.blockCounter)
+			scrapedChannel, errc := task.parse(ctx, blockChannel, payload.Fields, payload.IsPath, state.blockCounter, state.zeroPaginator)
 			errs = append(errs, errc)
-			fetchChannel <- flow{"", "", payload.Request}
+			fetchChannel <- flow{fmt.Sprintf("%s-%d", payload.PayloadMD5, state.page), "", payload.Request}
 			errc = task.saveIntermediate(ctx, scrapedChannel)
 			errs = append(errs, errc)
 			waitForPipeline(errs...)
@@ -332,7 +680,8 @@ func (task *Task) scrapeContent(ctx context.Context) error {
 	return nil
 }
 
-func (task *Task) fetch(ctx context.Context, in <-chan flow, uid string) (<-chan flow, <-chan error) {
+func (task *Task) fetch(ctx context.Context, in <-chan flow, payload Payload, state resumeState) (<-chan flow, <-chan error) {
+	uid := payload.PayloadMD5
 	contentChannel := make(chan flow)
 	errc := make(chan error)
 
@@ -340,33 +689,81 @@ func (task *Task) fetch(ctx context.Context, in <-chan flow, uid string) (<-chan
 		defer close(contentChannel)
 		defer close(errc)
 		for data := range in {
-			var fetchDelay time.Duration
-			if !viper.GetBool("IGNORE_FETCH_DELAY") {
-				rand := utils.Random(500, 1500)
-				fetchDelay = (time.Duration(viper.GetInt("FETCH_DELAY")) + time.Duration(rand)*time.Millisecond)
+			request, ok := data.data.(fetch.Request)
+			if !ok {
+				continue
 			}
-			select {
-			case <-time.After(fetchDelay):
-				if request, ok := data.data.(fetch.Request); ok {
-					task.mx.Lock()
-					task.requestCount++
-					task.mx.Unlock()
-					request.Type = task.templateRequest.Type
-					content, err := fetchContent(request)
-					if err != nil {
-						errc <- errs.ParseError{request.URL, err}
-						return
-					} else {
-						task.mx.Lock()
-						task.responseCount++
-						task.mx.Unlock()
-						select {
-						case contentChannel <- flow{fmt.Sprintf("%s", uid), request.URL, content}:
-						case <-ctx.Done():
-							return
-						}
-					}
+			if !task.allowedByRobots(ctx, request) {
+				task.mx.Lock()
+				task.disallowed = append(task.disallowed, request.URL)
+				task.mx.Unlock()
+				logger.Warn("Disallowed by robots.txt", zap.String("URL", request.URL))
+				return
+			}
+			//wait for a free slot in the host's bucket
+			if err := task.scheduler.wait(ctx, request); err != nil {
+				return
+			}
+			task.mx.Lock()
+			task.requestCount++
+			task.mx.Unlock()
+			request.Type = task.templateRequest.Type
+			//paginator and details requests use the same headers as the initial request
+			if request.Headers == nil {
+				request.Headers = task.templateRequest.Headers
+			}
+			if request.UserAgent == "" {
+				request.UserAgent = task.templateRequest.UserAgent
+			}
+			//pages are fetched with the same device, locale and location
+			if request.Emulation == nil {
+				request.Emulation = task.templateRequest.Emulation
+			}
+			//JS driven pages are awaited in the same way
+			if request.Wait == nil {
+				request.Wait = task.templateRequest.Wait
+			}
+			if request.Timeout == 0 {
+				request.Timeout = task.templateRequest.Timeout
+			}
+			if request.Network == nil {
+				request.Network = task.templateRequest.Network
+			}
+			if request.Console == nil {
+				request.Console = task.templateRequest.Console
+			}
+			request.HAR = request.HAR || task.templateRequest.HAR
+			//all the requests share the session of the initial request. Credentials are shared with requests to the same host
+			if request.UserToken == "" {
+				request.UserToken = task.templateRequest.UserToken
+			}
+			if request.Auth == nil {
+				request.Auth = templateAuth(task.templateRequest, request)
+			}
+			content, err := task.fetchWithSession(ctx, request)
+			if err != nil {
+				//cancelled task neither retries nor reports failed fetches
+				if ctx.Err() != nil {
+					return
 				}
+				state.page = pageFromKey(data.key)
+				if !task.retryLater(payload, request, state, err) {
+					errc <- errs.ParseError{URL: request.URL, Err: err}
+				}
+				return
+			}
+			task.mx.Lock()
+			task.responseCount++
+			task.mx.Unlock()
+			task.reportJSErrors(request.URL, content)
+			if err := task.saveCaptures(content); err != nil {
+				logger.Warn("Failed to save captures", zap.String("URL", request.URL), zap.Error(err))
+			}
+			if err := task.saveHAR(content); err != nil {
+				logger.Warn("Failed to save HAR", zap.String("URL", request.URL), zap.Error(err))
+			}
+			select {
+			case contentChannel <- flow{fmt.Sprintf("%s", uid), request.URL, content}:
 			case <-ctx.Done():
 				return
 			}
@@ -375,37 +772,91 @@ func (task *Task) fetch(ctx context.Context, in <-chan flow, uid string) (<-chan
 	return contentChannel, errc
 }
 
-func (task *Task) paginate(ctx context.Context, in <-chan flow, nextPageSelector string, fetcherChannel chan flow) (<-chan flow, <-chan error) {
+//templateAuth returns Auth of the template request if the request is sent to the same host. Credentials are not sent to other hosts linked by paginators and details pages.
+func templateAuth(template, req fetch.Request) *fetch.Auth {
+	host, err := template.Host()
+	if err != nil {
+		return nil
+	}
+	reqHost, err := req.Host()
+	if err != nil || !strings.EqualFold(host, reqHost) {
+		return nil
+	}
+	return template.Auth
+}
+
+//fetchWithSession fetches the request. If the page shows that the login session has expired, login step is repeated and the page is fetched once again.
+func (task *Task) fetchWithSession(ctx context.Context, request fetch.Request) (*fetch.Response, error) {
+	if task.loginStep == nil {
+		return fetchContent(ctx, request)
+	}
+	gen := task.session()
+	content, err := fetchContent(ctx, request)
+	if ctx.Err() != nil || !task.loginStep.expired(content, err) {
+		return content, err
+	}
+	if content != nil {
+		content.Close()
+	}
+	logger.Info("Session expired", zap.String("URL", request.URL))
+	if err := task.login(ctx, gen); err != nil {
+		return nil, err
+	}
+	request.NoCache = true
+	return fetchContent(ctx, request)
+}
+
+func (task *Task) paginate(ctx context.Context, in <-chan flow, nextPageSelector string, startPageNum int, fetcherChannel chan flow) (<-chan flow, <-chan error) {
 	contentChannel := make(chan flow)
 	errc := make(chan error)
 	go func() {
 		defer close(contentChannel)
 		defer close(errc)
-		currentPageNum := 0
+		//fetcher stops when paginator returns, including cancelled tasks
+		defer close(fetcherChannel)
+		currentPageNum := startPageNum
+		send := func(data flow) bool {
+			select {
+			case contentChannel <- data:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
 		for data := range in {
 			if nextPageSelector == "" {
 				data.key = fmt.Sprintf("%s-%d", data.key, currentPageNum)
-				contentChannel <- data
-				close(fetcherChannel)
+				send(data)
 				return
 			}
 			content := data.data.(io.ReadCloser)
-			doc, err := goquery.NewDocumentFromReader(content)
-			if err != nil {
-				errc <- errs.ParseError{data.url, err}
-				continue
-			}
-			// feed parser with data
-			selectionContent, _ := goquery.OuterHtml(doc.Selection)
-			contentChannel <- flow{fmt.Sprintf("%s-%d", data.key, currentPageNum), data.url, ioutil.NopCloser(strings.NewReader(selectionContent))}
 			f := Field{CSSSelector: nextPageSelector, Attrs: []string{"href"}, Name: "paginator"}
 			paginator := make(map[string]interface{})
-			err = f.extract(doc.Selection, &paginator, task.templateRequest.URL) /* tw.scraper.Paginator.NextPage(url, doc.Selection) */
+			var err error
+			if resp, ok := content.(*fetch.Response); ok && extractorForKind(resp.Kind) != CSSExtractor {
+				//next page link of JSON, XML and PDF documents is extracted with the document extractor
+				err = task.nextDocumentPage(resp, f, &paginator)
+				if !send(flow{fmt.Sprintf("%s-%d", data.key, currentPageNum), data.url, resp}) {
+					return
+				}
+			} else {
+				var doc *goquery.Document
+				doc, err = goquery.NewDocumentFromReader(content)
+				if err != nil {
+					errc <- errs.ParseError{data.url, err}
+					continue
+				}
+				// feed parser with data
+				selectionContent, _ := goquery.OuterHtml(doc.Selection)
+				if !send(flow{fmt.Sprintf("%s-%d", data.key, currentPageNum), data.url, withBody(content, ioutil.NopCloser(strings.NewReader(selectionContent)))}) {
+					return
+				}
+				err = f.extract(doc.Selection, &paginator, task.templateRequest.URL) /* tw.scraper.Paginator.NextPage(url, doc.Selection) */
+			}
 			if err != nil {
 				if _, ok := err.(errs.NotError); !ok {
 					errc <- errs.ParseError{data.url, err}
 				}
-				close(fetcherChannel)
 				return
 			}
 			// Repeat until we don't have any more URLs, or until we hit our page limit.
@@ -436,7 +887,6 @@ func (task *Task) paginate(ctx context.Context, in <-chan flow, nextPageSelector
 				}:
 				}
 			} else {
-				close(fetcherChannel)
 				return
 			}
 		}
@@ -455,6 +905,24 @@ func (task *Task) divide(ctx context.Context, in <-chan flow, fields []Field) (<
 		defer close(errc)
 		for data := range in {
 			content := data.data.(io.ReadCloser)
+			resp, _ := content.(*fetch.Response)
+			if resp != nil {
+				if extractor := extractorForKind(resp.Kind); extractor != CSSExtractor {
+					blocks, err := splitDocument(resp, extractor, fields)
+					if err != nil {
+						errc <- errs.ParseError{URL: data.url, Err: err}
+						continue
+					}
+					for _, b := range blocks {
+						select {
+						case <-ctx.Done():
+							return
+						case blockChannel <- flow{data.key, data.url, b}:
+						}
+					}
+					continue
+				}
+			}
 			doc, err := goquery.NewDocumentFromReader(content)
 			if err != nil {
 				errc <- errs.ParseError{data.url, err}
@@ -463,6 +931,9 @@ func (task *Task) divide(ctx context.Context, in <-chan flow, fields []Field) (<
 			var selectorAncestor *goquery.Selection
 			index := -1
 			for i, field := range fields {
+				if field.CSSSelector == "" || !field.targets(CSSExtractor) {
+					continue
+				}
 				selectorAncestor = doc.Find(field.CSSSelector).First().Parent()
 				if selectorAncestor.Length() > 0 {
 					index = i
@@ -478,6 +949,9 @@ func (task *Task) divide(ctx context.Context, in <-chan flow, fields []Field) (<
 			if len(selectorsSlice) > 0 {
 				for !bFound {
 					for _, f := range selectorsSlice {
+						if f.CSSSelector == "" || !f.targets(CSSExtractor) {
+							continue
+						}
 						sel := doc.Find(f.CSSSelector).First()
 						sel = sel.ParentsUntilSelection(selectorAncestor).Last()
 						//check last node.. if it equal html its mean that first selector's parent
@@ -508,7 +982,7 @@ func (task *Task) divide(ctx context.Context, in <-chan flow, fields []Field) (<
 				select {
 				case <-ctx.Done():
 					return
-				case blockChannel <- flow{data.key, data.url, s}:
+				case blockChannel <- flow{data.key, data.url, pageBlock{selection: s, response: resp, extractor: CSSExtractor}}:
 				}
 			})
 		}
@@ -516,29 +990,33 @@ func (task *Task) divide(ctx context.Context, in <-chan flow, fields []Field) (<
 	return blockChannel, errc, nil
 }
 
-func (task *Task) parse(ctx context.Context, in <-chan flow, fields []Field, isPath bool, blockCounter *int) (<-chan storage.Record, <-chan error) {
+func (task *Task) parse(ctx context.Context, in <-chan flow, fields []Field, isPath bool, blockCounter *int, zeroPaginator bool) (<-chan storage.Record, <-chan error) {
 	result := make(chan storage.Record)
 	errc := make(chan error)
-	zeroPaginator := false
-	if blockCounter == nil {
-		blockCounter = new(int)
-	} else {
-		// if block counter not equal nil that means that parent payload has path
-		// so we have to zero page number in a key
-		zeroPaginator = true
-	}
 	go func() {
 		defer close(result)
 		defer close(errc)
 		for data := range in {
-			block := data.data.(*goquery.Selection)
+			b := data.data.(pageBlock)
 			blockResult := make(map[string]interface{})
 			for _, field := range fields {
 
 				if isPath && strings.ToLower(field.Attrs[0]) != "path" {
 					continue
 				}
-				err := field.extract(block, &blockResult, task.templateRequest.URL)
+				field.extractResponse(b.response, &blockResult)
+				var err error
+				if b.selection != nil {
+					if !field.targets(CSSExtractor) {
+						continue
+					}
+					err = field.extract(b.selection, &blockResult, task.templateRequest.URL)
+				} else {
+					if !field.targets(b.extractor) {
+						continue
+					}
+					err = field.extractDocument(b, &blockResult, task.templateRequest.URL)
+				}
 				if err != nil {
 					if _, ok := err.(errs.NotError); !ok {
 						errc <- err
@@ -556,23 +1034,23 @@ func (task *Task) parse(ctx context.Context, in <-chan flow, fields []Field, isP
 					switch detailsURL.(type) {
 					case string:
 						field.Details.Request.URL = detailsURL.(string)
-						task.jobDone.Add(1)
 						if isPath {
 							field.Details.blockCounter = blockCounter
 						} else {
 							field.Details.InitUID()
 						}
-						task.payloads <- field.Details
+						task.push(ctx, field.Details)
 					case []string:
 						for _, url := range detailsURL.([]string) {
 							field.Details.Request.URL = url
-							task.jobDone.Add(1)
 							if isPath {
 								field.Details.blockCounter = blockCounter
 							} else {
 								field.Details.InitUID()
 							}
-							task.payloads <- field.Details
+							if !task.push(ctx, field.Details) {
+								break
+							}
 						}
 					}
 					// save reference to  details uid to be able restore it from storage
@@ -6,7 +6,10 @@ import (
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -15,6 +18,7 @@ import (
 	"github.com/slotix/dataflowkit/fetch"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/temoto/robotstxt"
 )
 
 var (
@@ -33,6 +37,8 @@ func init() {
 	viper.Set("RESULTS_DIR", "results")
 	viper.Set("MAX_PAGES", 2)
 	viper.Set("IGNORE_FETCH_DELAY", true)
+	//test server disallows everything in robots.txt
+	viper.Set("IGNORE_ROBOTSTXT", true)
 	viper.Set("PAYLOAD_POOL_SIZE", 100)
 	viper.Set("PAYLOAD_WORKERS_NUM", 50)
 	//delayFetch = 500 * time.Millisecond
@@ -890,3 +896,204 @@ func TestPathParse(t *testing.T) {
 	task.storage.DeleteAll()
 	os.RemoveAll("./results")
 }
+
+func TestAllowedByRobots(t *testing.T) {
+	viper.Set("IGNORE_ROBOTSTXT", false)
+	defer viper.Set("IGNORE_ROBOTSTXT", true)
+	task := &Task{Robots: make(map[string]*robotstxt.RobotsData)}
+	robots, err := robotstxt.FromString("User-agent: *\nDisallow: /private")
+	assert.NoError(t, err)
+	//robots.txt data is cached per host
+	task.Robots["example.com"] = robots
+	assert.True(t, task.allowedByRobots(context.Background(), fetch.Request{URL: "http://example.com/public"}))
+	assert.False(t, task.allowedByRobots(context.Background(), fetch.Request{URL: "http://example.com/private/page"}))
+
+	viper.Set("IGNORE_ROBOTSTXT", true)
+	assert.True(t, task.allowedByRobots(context.Background(), fetch.Request{URL: "http://example.com/private/page"}))
+}
+
+func TestExtractResponse(t *testing.T) {
+	f := Field{Name: "page", Attrs: []string{"_url", "_status", "_header.X-Custom", "_header.Missing", "_redirects"}}
+	assert.True(t, f.responseOnly())
+	results := map[string]interface{}{}
+	f.extractResponse(&fetch.Response{
+		StatusCode: 200,
+		URL:        "http://example.com/final",
+		Redirects:  []string{"http://example.com/start"},
+		Header:     http.Header{"X-Custom": []string{"value"}},
+	}, &results)
+	assert.Equal(t, map[string]interface{}{
+		"page__url":             "http://example.com/final",
+		"page__status":          "200",
+		"page__header.X-Custom": "value",
+		"page__redirects":       []string{"http://example.com/start"},
+	}, results)
+
+	//captures are saved to RESULTS_DIR and referenced by path
+	dir, err := ioutil.TempDir("", "captures")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	viper.Set("RESULTS_DIR", dir)
+	defer viper.Set("RESULTS_DIR", "results")
+	resp := &fetch.Response{
+		URL:      "http://example.com",
+		Captures: []fetch.CaptureData{{Name: "page", Type: fetch.Screenshot, ContentType: "image/png", Data: []byte("png")}},
+	}
+	task := &Task{rootUID: "uid"}
+	assert.NoError(t, task.saveCaptures(resp))
+	assert.Nil(t, resp.Captures[0].Data)
+	data, err := ioutil.ReadFile(resp.Captures[0].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("png"), data)
+	results = map[string]interface{}{}
+	(&Field{Name: "shot", Attrs: []string{"_capture.page", "_capture.missing"}}).extractResponse(resp, &results)
+	assert.Equal(t, map[string]interface{}{"shot__capture.page": resp.Captures[0].Path}, results)
+
+	//pseudo attributes are not extracted from html
+	f.Attrs = append(f.Attrs, "text")
+	assert.False(t, f.responseOnly())
+	p := Payload{Format: "json", Fields: []Field{f}}
+	assert.Error(t, task.checkPayload(&p))
+	p.Fields[0].Attrs = []string{"_url"}
+	assert.NoError(t, task.checkPayload(&p))
+}
+
+func TestCheckPayloadFetcher(t *testing.T) {
+	task := &Task{}
+	p := Payload{
+		Format:  "json",
+		Request: fetch.Request{URL: "http://example.com", Actions: `[{"click":{"element":".more"}}]`},
+		Fields:  []Field{{Name: "title", CSSSelector: "h1", Attrs: []string{"text"}}},
+	}
+	//base requests with actions are served by Chrome fetcher
+	assert.NoError(t, task.checkPayload(&p))
+	p.Request.Type = "replay"
+	assert.EqualError(t, task.checkPayload(&p), "Bad payload: replay fetcher does not support actions")
+	p.Request.Type = "chrome"
+	assert.NoError(t, task.checkPayload(&p))
+	p.Request.Captures = []fetch.Capture{{Name: "../../etc/x", Type: fetch.PDF}}
+	assert.EqualError(t, task.checkPayload(&p), "Bad payload: invalid capture name ../../etc/x")
+	p.Request.Captures = nil
+	//fetchers unknown to parse.d are validated by fetch.d
+	p.Request.Type = "custom"
+	assert.NoError(t, task.checkPayload(&p))
+}
+
+func TestReportJSErrors(t *testing.T) {
+	task := &Task{}
+	task.reportJSErrors("http://example.com/1", &fetch.Response{Console: []fetch.ConsoleMessage{{Type: "log", Text: "loaded"}}})
+	assert.Nil(t, task.jsErrors)
+	task.reportJSErrors("http://example.com/2", &fetch.Response{Console: []fetch.ConsoleMessage{
+		{Type: "warning", Text: "deprecated"},
+		{Type: fetch.ConsoleException, Text: "Uncaught TypeError: price is undefined"},
+		{Type: "error", Text: "API failed"},
+	}})
+	assert.Equal(t, map[string][]string{"http://example.com/2": {"Uncaught TypeError: price is undefined", "API failed"}}, task.jsErrors)
+}
+
+func TestNetworkValues(t *testing.T) {
+	responses := []fetch.NetworkResponse{
+		{Name: "api", Body: `{"items":[{"title":"A","price":10.5,"tags":["x","y"]},{"title":"B","price":20,"stock":true}]}`},
+		{Name: "api", Body: `{"items":[{"title":"C","price":1e6}]}`},
+		{Name: "other", Body: `{"items":[{"title":"D"}]}`},
+		{Name: "api", Body: `not json`},
+	}
+	assert.Equal(t, []string{"A", "B", "C"}, networkValues(responses, "api.items.title"))
+	assert.Equal(t, []string{"10.5", "20", "1000000"}, networkValues(responses, "api.items.price"))
+	assert.Equal(t, []string{"B"}, networkValues(responses[:1], "api.items.1.title"))
+	assert.Equal(t, []string{"x", "y"}, networkValues(responses, "api.items.tags"))
+	assert.Equal(t, []string{"true"}, networkValues(responses, "api.items.stock"))
+	assert.Equal(t, []string{`{"title":"D"}`}, networkValues(responses, "other.items"))
+	assert.Empty(t, networkValues(responses, "api.items.5.title"))
+	assert.Empty(t, networkValues(responses, "missing.items"))
+
+	results := map[string]interface{}{}
+	(&Field{Name: "product", Attrs: []string{"_network.api.items.title", "_network.other.items.0.title"}}).extractResponse(&fetch.Response{Network: responses}, &results)
+	assert.Equal(t, map[string]interface{}{
+		"product__network.api.items.title":     []string{"A", "B", "C"},
+		"product__network.other.items.0.title": "D",
+	}, results)
+}
+
+//TestParseCancel cancels the task while details pages are queued. Parse must return instead of waiting for payloads nobody takes.
+func TestParseCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list" {
+			fmt.Fprint(w, `<html><body><div id="cards">`)
+			for i := 0; i < 50; i++ {
+				fmt.Fprintf(w, `<a href="/person/%d">Person %d</a>`, i, i)
+			}
+			fmt.Fprint(w, `</div></body></html>`)
+			return
+		}
+		cancel()
+		fmt.Fprint(w, `<html><body><h1>Person</h1></body></html>`)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "cancel")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for key, value := range map[string]interface{}{
+		"STORAGE_TYPE":        "diskv",
+		"DISKV_BASE_DIR":      filepath.Join(dir, "diskv"),
+		"RESULTS_DIR":         filepath.Join(dir, "results"),
+		"PAYLOAD_POOL_SIZE":   1,
+		"PAYLOAD_WORKERS_NUM": 2,
+	} {
+		defer viper.Set(key, viper.Get(key))
+		viper.Set(key, value)
+	}
+	//connections to fetch.d stopped by previous tests are not reused
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	fetchServer := fetch.Start(fetch.Config{Host: viper.GetString("DFK_FETCH")})
+	defer fetchServer.Stop()
+
+	payload := Payload{
+		Name:    "cancel",
+		Request: fetch.Request{URL: ts.URL + "/list"},
+		Fields: []Field{
+			{
+				Name:        "Names",
+				CSSSelector: "#cards a",
+				Attrs:       []string{"text", "href"},
+				Details: Payload{
+					Fields: []Field{{Name: "Name", CSSSelector: "h1", Attrs: []string{"text"}}},
+				},
+			},
+		},
+		Format: "json",
+	}
+	done := make(chan error)
+	go func() {
+		_, err := NewTask().Parse(ctx, payload)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Parse did not return after the task was cancelled")
+	}
+}
+
+func TestRobotsServerError(t *testing.T) {
+	defer viper.Set("IGNORE_ROBOTSTXT", viper.Get("IGNORE_ROBOTSTXT"))
+	viper.Set("IGNORE_ROBOTSTXT", false)
+	status := http.StatusServiceUnavailable
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	}))
+	defer ts.Close()
+	task := &Task{Robots: map[string]*robotstxt.RobotsData{}}
+	req := fetch.Request{URL: ts.URL + "/page"}
+	//server errors disallow the host until robots.txt is available
+	assert.False(t, task.allowedByRobots(context.Background(), req))
+	assert.Empty(t, task.Robots)
+	status = http.StatusOK
+	assert.True(t, task.allowedByRobots(context.Background(), req))
+	assert.False(t, task.allowedByRobots(context.Background(), fetch.Request{URL: ts.URL + "/private"}))
+	assert.Equal(t, 1, len(task.Robots))
+}
@@ -0,0 +1,147 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slotix/dataflowkit/errs"
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+//Sitemap makes the payload scrape pages listed in the sitemap of the web site instead of the single Request.URL. Every page is processed with the same fields, paginator and request settings.
+type Sitemap struct {
+	//URL of the sitemap or sitemap index. If omitted, sitemaps listed in robots.txt of Request.URL host are used, falling back to /sitemap.xml.
+	URL string `json:"url,omitempty"`
+	//Include lists regular expressions. Only pages with URLs matching any of them are scraped.
+	Include []string `json:"include,omitempty"`
+	//Exclude lists regular expressions. Pages with URLs matching any of them are skipped.
+	Exclude []string `json:"exclude,omitempty"`
+	//ChangeFreq lists accepted changefreq values, e.g. ["daily","weekly"]. Pages without changefreq are skipped if it is set.
+	ChangeFreq []string `json:"changefreq,omitempty"`
+	//ModifiedSince skips pages with lastmod before the date given in RFC 3339 or YYYY-MM-DD format. Pages without lastmod are skipped if it is set.
+	ModifiedSince string `json:"modifiedSince,omitempty"`
+	//Limit is the maximum number of pages taken from the sitemap. If omitted, the value of MAX_SITEMAP_URLS of parse.d service is used.
+	Limit int `json:"limit,omitempty"`
+}
+
+//sitemapFilter accepts sitemap pages according to Sitemap filters.
+type sitemapFilter struct {
+	include, exclude []*regexp.Regexp
+	changeFreq       map[string]bool
+	modifiedSince    time.Time
+}
+
+//filter compiles Sitemap filters.
+func (s *Sitemap) filter() (*sitemapFilter, error) {
+	f := &sitemapFilter{}
+	for _, expr := range s.Include {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern: %s", err)
+		}
+		f.include = append(f.include, re)
+	}
+	for _, expr := range s.Exclude {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %s", err)
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	if len(s.ChangeFreq) > 0 {
+		f.changeFreq = map[string]bool{}
+		for _, freq := range s.ChangeFreq {
+			f.changeFreq[strings.ToLower(strings.TrimSpace(freq))] = true
+		}
+	}
+	if s.ModifiedSince != "" {
+		since, ok := fetch.SitemapURL{LastMod: s.ModifiedSince}.Modified()
+		if !ok {
+			return nil, fmt.Errorf("invalid modifiedSince date %s", s.ModifiedSince)
+		}
+		f.modifiedSince = since
+	}
+	return f, nil
+}
+
+//accept returns true if the sitemap page passes the filters.
+func (f *sitemapFilter) accept(u fetch.SitemapURL) bool {
+	if len(f.include) > 0 && !matchAny(f.include, u.Loc) {
+		return false
+	}
+	if matchAny(f.exclude, u.Loc) {
+		return false
+	}
+	if f.changeFreq != nil && !f.changeFreq[strings.ToLower(strings.TrimSpace(u.ChangeFreq))] {
+		return false
+	}
+	if !f.modifiedSince.IsZero() {
+		modified, ok := u.Modified()
+		if !ok || modified.Before(f.modifiedSince) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+//check validates sitemap settings of the payload.
+func (s *Sitemap) check(req fetch.Request) error {
+	if s.URL == "" && req.URL == "" {
+		return errors.New("Bad payload: Sitemap requires either sitemap URL or request URL")
+	}
+	if _, err := s.filter(); err != nil {
+		return fmt.Errorf("Bad payload: Sitemap: %s", err)
+	}
+	return nil
+}
+
+//sitemapURLs returns start URLs of the payload taken from the sitemap. Sitemaps are discovered from robots.txt if sitemap URL is not given.
+func (task *Task) sitemapURLs(ctx context.Context, payload Payload) ([]string, error) {
+	s := payload.Sitemap
+	filter, err := s.filter()
+	if err != nil {
+		return nil, err
+	}
+	sitemaps := []string{s.URL}
+	if s.URL == "" {
+		robots, err := task.robotsData(ctx, payload.Request)
+		if err != nil {
+			return nil, err
+		}
+		if sitemaps, err = fetch.Sitemaps(payload.Request.URL, robots); err != nil {
+			return nil, err
+		}
+	}
+	limit := s.Limit
+	if limit <= 0 {
+		limit = viper.GetInt("MAX_SITEMAP_URLS")
+	}
+	pages, err := fetch.SitemapURLs(ctx, sitemaps, filter.accept, limit)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, len(pages))
+	for i, p := range pages {
+		urls[i] = p.Loc
+	}
+	if len(urls) == 0 {
+		return nil, errs.ParseError{URL: strings.Join(sitemaps, ", "), Err: errors.New("no pages found in sitemap")}
+	}
+	logger.Info("Sitemap pages", zap.Strings("sitemaps", sitemaps), zap.Int("pages", len(urls)))
+	return urls, nil
+}
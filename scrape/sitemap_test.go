@@ -0,0 +1,31 @@
+package scrape
+
+import (
+	"testing"
+
+	"github.com/slotix/dataflowkit/fetch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapFilter(t *testing.T) {
+	s := &Sitemap{
+		Include:       []string{`/products/`},
+		Exclude:       []string{`\?sort=`},
+		ChangeFreq:    []string{"Daily", "weekly"},
+		ModifiedSince: "2019-04-01",
+	}
+	assert.NoError(t, s.check(fetch.Request{URL: "http://example.com"}))
+	f, err := s.filter()
+	assert.NoError(t, err)
+	assert.True(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/products/1", LastMod: "2019-04-02", ChangeFreq: "daily"}))
+	assert.False(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/about", LastMod: "2019-04-02", ChangeFreq: "daily"}))
+	assert.False(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/products/?sort=price", LastMod: "2019-04-02", ChangeFreq: "daily"}))
+	assert.False(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/products/1", LastMod: "2019-04-02", ChangeFreq: "monthly"}))
+	assert.False(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/products/1", LastMod: "2019-03-31", ChangeFreq: "daily"}))
+	assert.False(t, f.accept(fetch.SitemapURL{Loc: "http://example.com/products/1", ChangeFreq: "daily"}))
+
+	assert.NoError(t, (&Sitemap{}).check(fetch.Request{URL: "http://example.com"}))
+	assert.Error(t, (&Sitemap{}).check(fetch.Request{}))
+	assert.Error(t, (&Sitemap{URL: "http://example.com/sitemap.xml", Include: []string{"("}}).check(fetch.Request{}))
+	assert.Error(t, (&Sitemap{URL: "http://example.com/sitemap.xml", ModifiedSince: "yesterday"}).check(fetch.Request{}))
+}
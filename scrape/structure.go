@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/slotix/dataflowkit/fetch"
 	"github.com/slotix/dataflowkit/storage"
 	"github.com/temoto/robotstxt"
@@ -14,8 +15,14 @@ type Field struct {
 	//Name is a name of fields. It is required, and will be used to aggregate results.
 	Name string `json:"name"`
 	//Selector is a CSS selector within the given block to process.  Pass in "." to use the root block's selector.
+	//
+	//Selectors of fields applied to JSON documents are JSONPath expressions, e.g. "$.items[*].title", to XML documents are XPath expressions, e.g. "//item/title", to PDF documents are regular expressions matched against the document text. Selector of PDF field may be omitted to extract the whole text.
 	CSSSelector string `json:"selector"`
+	//Target declares the extractor the field is applied with: "css" (HTML), "jsonpath" (JSON), "xpath" (XML) or "pdf". Extractor is chosen by the kind of fetched document, so fields of the payload processing different kinds of documents, e.g. HTML pages and JSON details, should declare their targets. Field without Target is applied to any document.
+	Target string `json:"target,omitempty"`
 	//Attrs specify attributes which will be extracted from element
+	//
+	//Pseudo attributes starting with underscore extract metadata of the page response: "_url" (final URL), "_status", "_contentType", "_charset", "_redirects", "_header.Name", "_capture.Name" (path of the screenshot or PDF requested by Request.Captures and saved to RESULTS_DIR) and "_network.Name.path" (values from JSON bodies of XHR and fetch responses recorded by Request.Network filter Name, e.g. "_network.api.items.title"). Selector may be omitted for fields containing pseudo attributes only.
 	Attrs []string `json:"attrs"`
 	//Details is an optional field strictly for Link extractor type. It guides scraper to parse additional pages following the links according to the set of fields specified inside "details"
 	Details Payload `json:"details"`
@@ -36,6 +43,10 @@ type Payload struct {
 	//Request struct represents HTTP request to be sent to a server. It combines parameters for passing for downloading html pages by Fetch Endpoint.
 	//Request.URL field is required. All other fields including Params, Cookies, Func are optional.
 	Request fetch.Request `json:"request"`
+	//Sitemap makes the payload take start URLs from the sitemap of the web site instead of Request.URL.
+	Sitemap *Sitemap `json:"sitemap,omitempty"`
+	//Login is an optional step performed before the first request to sign in to the web site. The session it opens is reused by all page, paginator and details requests and renewed automatically when it expires.
+	Login *Login `json:"login,omitempty"`
 	//Fields is a set of fields used to extract data from a web page.
 	Fields []Field `json:"fields"`
 	//PayloadMD5 encodes payload content to MD5. It is used for generating file name to be stored.
@@ -60,28 +71,48 @@ type Payload struct {
 	// Combined list of results is always returned for CSV format.
 	PaginateResults *bool `json:"paginateResults"`
 	//FetchDelay should be used for a scraper to throttle the crawling speed to avoid hitting the web servers too frequently.
-	//FetchDelay specifies sleep time for multiple requests for the same domain. It is equal to FetchDelay * random value between 500 and 1500 msec
+	//FetchDelay specifies sleep time for multiple requests for the same domain. Crawl-delay or Request-rate directives of robots.txt take precedence over it.
+	//If FetchDelay is omitted the value of FETCH_DELAY of parse.d service is used.
+	//Requests to different domains are not delayed by each other.
 	FetchDelay *time.Duration
 	//Some web sites track  statistically significant similarities in the time between requests to them. RandomizeCrawlDelay setting decreases the chance of a crawler being blocked by such sites. This way a random delay ranging from 0.5  CrawlDelay to 1.5  CrawlDelay seconds is used between consecutive requests to the same domain. If CrawlDelay is zero (default) this option has no effect.
+	//If RandomizeFetchDelay is omitted the value of RANDOMIZE_FETCH_DELAY of parse.d service is used.
 	RandomizeFetchDelay *bool
 	//Maximum number of times to retry, in addition to the first download.
+	//Requests failed with network errors or with one of RETRY_HTTP_CODES are retried.
 	//RETRY_HTTP_CODES
 	//Default: [500, 502, 503, 504, 408]
-	//Failed pages should be rescheduled for download at the end. once the spider has finished crawling all other (non failed) pages.
+	//Failed pages are rescheduled for download at the end, once the spider has finished crawling all other (non failed) pages.
 	RetryTimes int `json:"retryTimes"`
 	// ContainPath means that one of the field just a path and we have to ignore all other fields (if present)
 	// that are not a path
 	IsPath       bool `json:"path"`
 	blockCounter *int
+	// resume is set for payloads rescheduled after a transient fetch failure.
+	resume *resumeState
 }
 
 // Task keeps Results of Task generated from Payload along with other auxiliary information
 type Task struct {
-	Robots map[string]*robotstxt.RobotsData
+	Robots   map[string]*robotstxt.RobotsData
+	robotsMx sync.Mutex
+	// scheduler throttles requests to the same host.
+	scheduler *hostScheduler
 	// storage using to write result into corresponding storage type
 	storage       storage.Store
 	requestCount  int
 	responseCount int
+	retryCount    int
+	failedCount   int
+	// disallowed keeps URLs which were not fetched as they are disallowed by robots.txt.
+	disallowed []string
+	// jsErrors keeps uncaught JavaScript errors and console errors of the pages fetched with Request.Console.
+	jsErrors map[string][]string
+
+	// retry keeps retry/backoff settings for transient fetch failures.
+	retry retryPolicy
+	// deferred holds payloads rescheduled after transient fetch failures.
+	deferred []Payload
 
 	jobDone  sync.WaitGroup
 	payloads chan Payload
@@ -91,6 +122,12 @@ type Task struct {
 	mx              sync.Mutex
 	templateRequest fetch.Request
 
+	// loginStep is the login step of the payload. Pages are fetched without login if it is nil.
+	loginStep *Login
+	loginMx   sync.Mutex
+	// loginGen counts successful logins. It is compared by the pages fetched with the expired session to avoid concurrent re-logins.
+	loginGen int
+
 	isParsed bool
 }
 
@@ -102,6 +139,17 @@ type encodeInfo struct {
 	fieldNames    []string
 }
 
+//pageBlock is a block of the page passed to parser along with the page response metadata. Blocks of JSON, XML and PDF documents are nodes of doc instead of selection.
+type pageBlock struct {
+	selection *goquery.Selection
+	response  *fetch.Response
+	extractor string
+	doc       document
+	node      interface{}
+	//selectors maps field selectors to selectors relative to the node.
+	selectors map[string]string
+}
+
 type flow struct {
 	key  string
 	url  string
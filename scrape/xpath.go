@@ -0,0 +1,395 @@
+package scrape
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html/charset"
+)
+
+//xmlNode is an element or a text node of XML document.
+type xmlNode struct {
+	//name is the local name of the element and prefix is its namespace prefix as written in the document. Name is empty for text nodes.
+	name     string
+	prefix   string
+	attrs    []xmlAttr
+	data     string
+	parent   *xmlNode
+	children []*xmlNode
+	//index is the position of the node among children of its parent.
+	index int
+}
+
+type xmlAttr struct {
+	name, prefix, value string
+}
+
+//parseXML builds the tree of XML document. The root node is the document node containing the root element. Namespace declarations are not kept as attributes.
+func parseXML(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charset.NewReaderLabel
+	root := &xmlNode{}
+	current := root
+	//prefixes maps namespace URLs resolved by decoder back to prefixes of the document
+	prefixes := map[string]string{}
+	prefix := func(space string) string {
+		if p, ok := prefixes[space]; ok {
+			return p
+		}
+		return space
+	}
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := []xmlAttr{}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					prefixes[a.Value] = a.Name.Local
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					prefixes[a.Value] = ""
+				default:
+					attrs = append(attrs, xmlAttr{name: a.Name.Local, prefix: a.Name.Space, value: a.Value})
+				}
+			}
+			for i := range attrs {
+				attrs[i].prefix = prefix(attrs[i].prefix)
+			}
+			n := &xmlNode{name: t.Name.Local, prefix: prefix(t.Name.Space), attrs: attrs}
+			current.add(n)
+			current = n
+		case xml.EndElement:
+			if current.parent != nil {
+				current = current.parent
+			}
+		case xml.CharData:
+			//CDATA sections and text around them make a single text node
+			if last := len(current.children) - 1; last >= 0 && current.children[last].name == "" {
+				current.children[last].data += string(t)
+				continue
+			}
+			current.add(&xmlNode{data: string(t)})
+		}
+	}
+	return root, nil
+}
+
+func (n *xmlNode) add(child *xmlNode) {
+	child.parent = n
+	child.index = len(n.children)
+	n.children = append(n.children, child)
+}
+
+//text returns text content of the node and its descendants.
+func (n *xmlNode) text() string {
+	if n.name == "" && n.parent != nil {
+		return n.data
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.text())
+	}
+	return b.String()
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.name == name {
+			return a.value, true
+		}
+	}
+	return "", false
+}
+
+//xmlNavigator implements xpath.NodeNavigator over the tree of XML document.
+type xmlNavigator struct {
+	root, curr *xmlNode
+	//attr is the index of the current attribute of curr or -1.
+	attr int
+}
+
+func newXMLNavigator(n *xmlNode) *xmlNavigator {
+	root := n
+	for root.parent != nil {
+		root = root.parent
+	}
+	return &xmlNavigator{root: root, curr: n, attr: -1}
+}
+
+func (x *xmlNavigator) NodeType() xpath.NodeType {
+	switch {
+	case x.attr >= 0:
+		return xpath.AttributeNode
+	case x.curr == x.root:
+		return xpath.RootNode
+	case x.curr.name == "":
+		return xpath.TextNode
+	}
+	return xpath.ElementNode
+}
+
+func (x *xmlNavigator) LocalName() string {
+	if x.attr >= 0 {
+		return x.curr.attrs[x.attr].name
+	}
+	return x.curr.name
+}
+
+func (x *xmlNavigator) Prefix() string {
+	if x.attr >= 0 {
+		return x.curr.attrs[x.attr].prefix
+	}
+	return x.curr.prefix
+}
+
+func (x *xmlNavigator) Value() string {
+	if x.attr >= 0 {
+		return x.curr.attrs[x.attr].value
+	}
+	return x.curr.text()
+}
+
+func (x *xmlNavigator) Copy() xpath.NodeNavigator {
+	c := *x
+	return &c
+}
+
+func (x *xmlNavigator) MoveToRoot() {
+	x.curr, x.attr = x.root, -1
+}
+
+func (x *xmlNavigator) MoveToParent() bool {
+	if x.attr >= 0 {
+		x.attr = -1
+		return true
+	}
+	if x.curr.parent == nil {
+		return false
+	}
+	x.curr = x.curr.parent
+	return true
+}
+
+func (x *xmlNavigator) MoveToNextAttribute() bool {
+	if x.attr+1 >= len(x.curr.attrs) {
+		return false
+	}
+	x.attr++
+	return true
+}
+
+func (x *xmlNavigator) MoveToChild() bool {
+	if x.attr >= 0 || len(x.curr.children) == 0 {
+		return false
+	}
+	x.curr = x.curr.children[0]
+	return true
+}
+
+//sibling moves to the sibling at index i of the current node.
+func (x *xmlNavigator) sibling(i int) bool {
+	if x.attr >= 0 || x.curr.parent == nil || i < 0 || i >= len(x.curr.parent.children) {
+		return false
+	}
+	x.curr = x.curr.parent.children[i]
+	return true
+}
+
+func (x *xmlNavigator) MoveToFirst() bool {
+	return x.sibling(0)
+}
+
+func (x *xmlNavigator) MoveToNext() bool {
+	return x.sibling(x.curr.index + 1)
+}
+
+func (x *xmlNavigator) MoveToPrevious() bool {
+	return x.sibling(x.curr.index - 1)
+}
+
+func (x *xmlNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	o, ok := other.(*xmlNavigator)
+	if !ok || o.root != x.root {
+		return false
+	}
+	x.curr, x.attr = o.curr, o.attr
+	return true
+}
+
+//compileXPath compiles XPath 1.0 expression. Syntax errors and unsupported functions are reported when the payload is checked.
+func compileXPath(expr string) (*xpath.Expr, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, errors.New("empty XPath")
+	}
+	e, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %s: %s", expr, err)
+	}
+	return e, nil
+}
+
+//evalXPath returns elements and values selected by the expression from the context node. Attributes and text nodes are returned as values along with results of expressions returning strings, numbers or booleans, e.g. "count(//item)".
+func evalXPath(expr *xpath.Expr, context *xmlNode) (nodes []*xmlNode, values []string, err error) {
+	//xpath package panics on type errors of the expression found while it is evaluated
+	defer func() {
+		if r := recover(); r != nil {
+			nodes, values, err = nil, nil, fmt.Errorf("XPath %s: %v", expr, r)
+		}
+	}()
+	switch result := expr.Evaluate(newXMLNavigator(context)).(type) {
+	case *xpath.NodeIterator:
+		for result.MoveNext() {
+			x := result.Current().(*xmlNavigator)
+			switch x.NodeType() {
+			case xpath.AttributeNode:
+				values = append(values, x.Value())
+			case xpath.TextNode:
+				if strings.TrimSpace(x.Value()) != "" {
+					values = append(values, x.Value())
+				}
+			default:
+				nodes = append(nodes, x.curr)
+			}
+		}
+	case string:
+		values = append(values, result)
+	case float64:
+		values = append(values, strconv.FormatFloat(result, 'f', -1, 64))
+	case bool:
+		values = append(values, strconv.FormatBool(result))
+	}
+	return nodes, values, nil
+}
+
+//xpathSteps splits absolute location path into steps keeping their leading "/" or "//", e.g. "/rss/channel//item[@a='x/y']" is split into "/rss", "/channel" and "//item[@a='x/y']". Nil is returned for relative paths and for expressions other than a single location path.
+func xpathSteps(expr string) []string {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "/") {
+		return nil
+	}
+	steps := []string{}
+	start, depth, quote := 0, 0, byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth > 0:
+		case c == '/':
+			//"//" belongs to the following step
+			if i > start && s[i-1] != '/' {
+				steps = append(steps, s[start:i])
+				start = i
+			}
+		case strings.IndexByte(" \t\n|=<>!+,", c) >= 0:
+			//unions and other expressions are applied to the whole document
+			return nil
+		}
+	}
+	return append(steps, s[start:])
+}
+
+//xmlDocument is XML document processed with XPath extractor.
+type xmlDocument struct {
+	root *xmlNode
+}
+
+func newXMLDocument(body []byte) (*xmlDocument, error) {
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+	return &xmlDocument{root: root}, nil
+}
+
+//split selects blocks with the common part of selectors excluding the last step, e.g. "//item" for "//item/title" and "//item/link/@href".
+func (d *xmlDocument) split(selectors []string) ([]interface{}, []string, error) {
+	var common []string
+	for i, sel := range selectors {
+		if _, err := compileXPath(sel); err != nil {
+			return nil, nil, err
+		}
+		steps := xpathSteps(sel)
+		if len(steps) == 0 {
+			common = nil
+			break
+		}
+		steps = steps[:len(steps)-1]
+		if i == 0 {
+			common = steps
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(steps) && common[n] == steps[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return []interface{}{d.root}, selectors, nil
+	}
+	relative := make([]string, len(selectors))
+	for i, sel := range selectors {
+		relative[i] = "." + strings.Join(xpathSteps(sel)[len(common):], "")
+	}
+	expr, err := compileXPath(strings.Join(common, ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes, _, err := evalXPath(expr, d.root)
+	if err != nil {
+		return nil, nil, err
+	}
+	blocks := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		blocks[i] = n
+	}
+	return blocks, relative, nil
+}
+
+//values returns values selected within the block. "text" attribute returns trimmed text content of selected elements, other attributes return values of element attributes. Elements without "href" attribute return their text content for "href", e.g. <link> of RSS feeds.
+func (d *xmlDocument) values(block interface{}, selector, attr string) ([]string, error) {
+	expr, err := compileXPath(selector)
+	if err != nil {
+		return nil, err
+	}
+	nodes, values, err := evalXPath(expr, block.(*xmlNode))
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		switch attr {
+		case "text":
+			values = append(values, strings.TrimSpace(n.text()))
+		default:
+			if v, ok := n.attr(attr); ok {
+				values = append(values, v)
+			} else if attr == "href" {
+				values = append(values, strings.TrimSpace(n.text()))
+			}
+		}
+	}
+	return values, nil
+}